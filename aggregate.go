@@ -0,0 +1,194 @@
+package terra
+
+import (
+	"math"
+	"sort"
+
+	"github.com/saleswise/errors/errors"
+)
+
+// AggregateFunction is a statistic computed over the features in a polygon.
+type AggregateFunction int
+
+const (
+	// AggregateCount counts the features.
+	AggregateCount AggregateFunction = iota
+	// AggregateSum adds up a numeric property.
+	AggregateSum
+	// AggregateMean averages a numeric property.
+	AggregateMean
+	// AggregateMin finds the smallest value of a numeric property.
+	AggregateMin
+	// AggregateMax finds the largest value of a numeric property.
+	AggregateMax
+)
+
+// Aggregate is a statistic Geostore.Aggregate writes onto each polygon, and
+// Bin onto each grid cell.
+type Aggregate struct {
+	Function AggregateFunction
+	// Property is the path of the numeric property aggregated. Features
+	// without a number there are left out, though still counted. It is unused
+	// by AggregateCount.
+	Property string
+	// As names the polygon property the statistic is written to.
+	As string
+}
+
+// aggregateStats accumulates the values of one property over the features in
+// one polygon.
+type aggregateStats struct {
+	count    int
+	values   int
+	sum      float64
+	min, max float64
+}
+
+func (s *aggregateStats) add(value float64) {
+	if s.values == 0 || value < s.min {
+		s.min = value
+	}
+	if s.values == 0 || value > s.max {
+		s.max = value
+	}
+	s.values++
+	s.sum += value
+}
+
+// result returns the statistic, or nil where there are no values for it.
+func (s *aggregateStats) result(function AggregateFunction) interface{} {
+
+	switch function {
+	case AggregateCount:
+		return s.count
+	case AggregateSum:
+		return s.sum
+	}
+	if s.values == 0 {
+		return nil
+	}
+	switch function {
+	case AggregateMean:
+		return s.sum / float64(s.values)
+	case AggregateMin:
+		return s.min
+	}
+	return s.max
+}
+
+func checkAggregates(aggregates []Aggregate) error {
+
+	if len(aggregates) == 0 {
+		return errors.New("There are no aggregates to compute.")
+	}
+	for _, aggregate := range aggregates {
+		if aggregate.As == "" {
+			return errors.New("Unable to write an aggregate without a property name.")
+		}
+		if aggregate.Function < AggregateCount || aggregate.Function > AggregateMax {
+			return errors.Newf("The aggregate function %d is unknown.", aggregate.Function)
+		}
+		if aggregate.Function != AggregateCount && aggregate.Property == "" {
+			return errors.Newf("Unable to compute %s without a property to aggregate.", aggregate.As)
+		}
+	}
+
+	return nil
+}
+
+// accumulate adds the feature to the statistics of each aggregate.
+func accumulate(stats []aggregateStats, aggregates []Aggregate, feat *Feature) {
+	for i, aggregate := range aggregates {
+		stats[i].count++
+		if aggregate.Function == AggregateCount {
+			continue
+		}
+		if value, err := feat.PropertyFloat(aggregate.Property); err == nil && !math.IsNaN(value) {
+			stats[i].add(value)
+		}
+	}
+}
+
+// writeAggregates sets the result of each aggregate on the properties.
+func writeAggregates(properties map[string]interface{}, stats []aggregateStats, aggregates []Aggregate) {
+	for i, aggregate := range aggregates {
+		properties[aggregate.As] = stats[i].result(aggregate.Function)
+	}
+}
+
+// Aggregate computes the statistics over the features intersecting each
+// stored polygon and writes them as properties onto the polygon, returning
+// the updated polygons ordered by key. Stored polygons may nest, so a feature
+// counts in every polygon it falls in: a point in each covering it, edge
+// included, and other features in each they intersect. Every polygon is
+// updated: those with no features have a count and sum of zero, and a nil
+// mean, min and max.
+func (g *Geostore) Aggregate(features FeatureSource, aggregates ...Aggregate) ([]*Feature, error) {
+
+	if err := checkAggregates(aggregates); err != nil {
+		return nil, err
+	}
+
+	list, err := features.sourceFeatures()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string][]aggregateStats)
+	for key, entries := range g.entries {
+		if isPolygonal(entries[0].feature) {
+			stats[key] = make([]aggregateStats, len(aggregates))
+		}
+	}
+
+	for _, feat := range list {
+		if feat.Geometry == nil {
+			continue
+		}
+		rects, err := feat.Rects()
+		if err != nil {
+			return nil, err
+		}
+		for key := range g.searchKeys(rects) {
+			if stats[key] == nil {
+				continue
+			}
+			prepared := g.prepared.get(key, g.entries[key][0].feature)
+			var ok bool
+			if feat.Type == "Point" {
+				ok, err = prepared.Covers(feat.Geometry)
+			} else {
+				ok, err = prepared.Intersects(feat.Geometry)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not test whether %s intersects the feature", key)
+			}
+			if !ok {
+				continue
+			}
+			accumulate(stats[key], aggregates, feat)
+		}
+	}
+
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	updated := make([]*Feature, 0, len(keys))
+	for _, key := range keys {
+		polygon := *g.entries[key][0].feature
+		polygon.Properties = copyProperties(polygon.Properties)
+		if polygon.Properties == nil {
+			polygon.Properties = make(map[string]interface{}, len(aggregates))
+		}
+		writeAggregates(polygon.Properties, stats[key], aggregates)
+		if err := g.Update([]byte(key), &polygon); err != nil {
+			return nil, err
+		}
+		updated = append(updated, g.entries[key][0].feature)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,121 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAggregate(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should accumulate statistics", t, func() {
+		stats := &aggregateStats{}
+		So(stats.result(AggregateCount), ShouldEqual, 0)
+		So(stats.result(AggregateMean), ShouldBeNil)
+		So(stats.result(AggregateMax), ShouldBeNil)
+
+		for _, value := range []float64{4, -2, 10} {
+			stats.count++
+			stats.add(value)
+		}
+		stats.count++
+		So(stats.result(AggregateCount), ShouldEqual, 4)
+		So(stats.result(AggregateSum), ShouldEqual, 12)
+		So(stats.result(AggregateMean), ShouldEqual, 4)
+		So(stats.result(AggregateMin), ShouldEqual, -2)
+		So(stats.result(AggregateMax), ShouldEqual, 10)
+	})
+
+	Convey("given a store of polygons", t, func() {
+
+		store, err := OpenGeostore("./geostore-aggregate")
+		So(err, ShouldBeNil)
+
+		for _, area := range []struct {
+			id                       string
+			west, south, east, north float64
+		}{
+			{"west", -10, -10, 0, 10},
+			{"east", 0, -10, 10, 10},
+			{"empty", 20, -10, 30, 10},
+		} {
+			polygon := rectangle(area.id, area.west, area.south, area.east, area.north)
+			polygon.Properties = map[string]interface{}{"name": area.id}
+			_, err = store.Add(polygon)
+			So(err, ShouldBeNil)
+		}
+
+		places := FeatureCollection{}
+		for _, place := range []struct {
+			lat, lng float64
+			value    interface{}
+		}{
+			{1, -5, 3.0},
+			{2, -5, 7.0},
+			{3, -5, "unknown"},
+			{1, 5, "12"},
+		} {
+			point, err := NewPoint(place.lat, place.lng)
+			So(err, ShouldBeNil)
+			point.Properties = map[string]interface{}{"value": place.value}
+			places = append(places, point)
+		}
+
+		Convey("should write counts and statistics onto each polygon", func() {
+			updated, err := store.Aggregate(places,
+				Aggregate{Function: AggregateCount, As: "count"},
+				Aggregate{Function: AggregateSum, Property: "value", As: "total"},
+				Aggregate{Function: AggregateMean, Property: "value", As: "mean"},
+				Aggregate{Function: AggregateMin, Property: "value", As: "min"},
+				Aggregate{Function: AggregateMax, Property: "value", As: "max"},
+			)
+			So(err, ShouldBeNil)
+			So(len(updated), ShouldEqual, 3)
+			So(updated[0].ID, ShouldEqual, "east")
+			So(updated[0].Properties["count"], ShouldEqual, 1)
+			So(updated[0].Properties["total"], ShouldEqual, 12)
+			So(updated[1].ID, ShouldEqual, "empty")
+			So(updated[1].Properties["count"], ShouldEqual, 0)
+			So(updated[1].Properties["mean"], ShouldBeNil)
+
+			feat, err := store.Get([]byte("west"))
+			So(err, ShouldBeNil)
+			So(feat.Properties["name"], ShouldEqual, "west")
+			So(feat.Properties["count"], ShouldEqual, 3)
+			So(feat.Properties["total"], ShouldEqual, 10)
+			So(feat.Properties["mean"], ShouldEqual, 5)
+			So(feat.Properties["min"], ShouldEqual, 3)
+			So(feat.Properties["max"], ShouldEqual, 7)
+		})
+
+		Convey("should count a point in each polygon nested around it", func() {
+			_, err := store.Add(rectangle("park", -6, 0, -4, 4))
+			So(err, ShouldBeNil)
+			point, err := NewPoint(2, -5)
+			So(err, ShouldBeNil)
+			updated, err := store.Aggregate(FeatureCollection{point}, Aggregate{Function: AggregateCount, As: "count"})
+			So(err, ShouldBeNil)
+			So(len(updated), ShouldEqual, 4)
+			So(updated[2].ID, ShouldEqual, "park")
+			So(updated[2].Properties["count"], ShouldEqual, 1)
+			So(updated[3].ID, ShouldEqual, "west")
+			So(updated[3].Properties["count"], ShouldEqual, 1)
+		})
+
+		Convey("should reject incomplete aggregates", func() {
+			_, err := store.Aggregate(places)
+			So(err, ShouldNotBeNil)
+			_, err = store.Aggregate(places, Aggregate{Function: AggregateSum, As: "total"})
+			So(err, ShouldNotBeNil)
+			_, err = store.Aggregate(places, Aggregate{Function: AggregateCount})
+			So(err, ShouldNotBeNil)
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
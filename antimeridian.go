@@ -0,0 +1,332 @@
+package terra
+
+import (
+	"math"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// CrossesAntimeridian reports whether any line or ring of the feature has an
+// edge spanning more than 180 degrees of longitude, which is how an uncut
+// geometry crossing the antimeridian is written.
+func (feat *Feature) CrossesAntimeridian() (bool, error) {
+
+	lines, err := featureLines(feat)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range lines {
+		if crossesAntimeridian(lines[i]) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// featureLines returns every linestring and ring of the feature geometry.
+func featureLines(feat *Feature) ([][]geos.Coord, error) {
+
+	if feat.Geometry == nil {
+		return nil, nil
+	}
+
+	parts, err := geometryParts(feat.Geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines [][]geos.Coord
+	for _, part := range parts {
+		typer, err := part.Type()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get geometry type")
+		}
+		switch typer {
+		case geos.POLYGON:
+			rings, err := polygonRings(part)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, rings...)
+		case geos.LINESTRING, geos.LINEARRING:
+			coords, err := part.Coords()
+			if err != nil {
+				return nil, errors.Wrap(err, "could not get geometry coords")
+			}
+			lines = append(lines, coords)
+		}
+	}
+
+	return lines, nil
+}
+
+// CutAntimeridian returns a copy of the feature with its geometry cut in two
+// where it crosses the antimeridian, as RFC 7946 section 3.1.9 recommends:
+// lines become MultiLineStrings and polygons MultiPolygons, with the pieces
+// meeting at longitudes 180 and -180. A geometry written beyond 180 or -180
+// is cut there too, the pieces outside wrapped back into range. A feature
+// that does neither is returned unchanged.
+func (feat *Feature) CutAntimeridian() (*Feature, error) {
+
+	lines, err := featureLines(feat)
+	if err != nil {
+		return nil, err
+	}
+	crosses := false
+	for i := range lines {
+		if crossesAntimeridian(lines[i]) || beyondAntimeridian(lines[i]) {
+			crosses = true
+			break
+		}
+	}
+	if !crosses {
+		return feat, nil
+	}
+
+	parts, err := geometryParts(feat.Geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		typer  string
+		pieces []*geos.Geometry
+	)
+
+	switch feat.Type {
+	case "LineString", "MultiLineString":
+		typer = "MultiLineString"
+		for _, part := range parts {
+			coords, err := part.Coords()
+			if err != nil {
+				return nil, errors.Wrap(err, "could not get geometry coords")
+			}
+			// Wrapping the positions beyond the antimeridian leaves the line
+			// jumping it wherever it crosses.
+			for i := range coords {
+				coords[i].X = wrapLongitude(coords[i].X)
+			}
+			for _, line := range cutLine(coords) {
+				piece, err := geos.NewLineString(line...)
+				if err != nil {
+					return nil, errors.Wrap(err, "could not create line string")
+				}
+				pieces = append(pieces, piece)
+			}
+		}
+	case "Polygon", "MultiPolygon":
+		typer = "MultiPolygon"
+		for _, part := range parts {
+			cut, err := cutPolygon(part)
+			if err != nil {
+				return nil, err
+			}
+			pieces = append(pieces, cut...)
+		}
+	default:
+		return nil, errors.Newf("Unable to cut a %s at the antimeridian.", feat.Type)
+	}
+
+	var geometry *geos.Geometry
+	switch {
+	case len(pieces) == 1:
+		typer = typer[len("Multi"):]
+		geometry = pieces[0]
+	case typer == "MultiLineString":
+		geometry, err = geos.NewCollection(geos.MULTILINESTRING, pieces...)
+	default:
+		geometry, err = geos.NewCollection(geos.MULTIPOLYGON, pieces...)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create new collection")
+	}
+
+	cut := &Feature{
+		ID:             feat.ID,
+		Properties:     feat.Properties,
+		ForeignMembers: feat.ForeignMembers,
+		CRS:            feat.CRS,
+		inheritedCRS:   feat.inheritedCRS,
+		sourceID:       feat.sourceID,
+		generatedID:    feat.generatedID,
+	}
+	if err := cut.SetGeometry(typer, geometry); err != nil {
+		return nil, err
+	}
+
+	return cut, nil
+}
+
+// beyondAntimeridian reports whether any position has a longitude beyond 180
+// or -180.
+func beyondAntimeridian(coords []geos.Coord) bool {
+	for _, c := range coords {
+		if c.X < -180 || c.X > 180 {
+			return true
+		}
+	}
+	return false
+}
+
+// cutLine splits a line at each edge that jumps the antimeridian, adding the
+// interpolated crossing point to both sides.
+func cutLine(coords []geos.Coord) [][]geos.Coord {
+
+	var lines [][]geos.Coord
+	current := []geos.Coord{coords[0]}
+
+	for i := 1; i < len(coords); i++ {
+		a, b := coords[i-1], coords[i]
+		if math.Abs(b.X-a.X) <= 180 {
+			current = append(current, b)
+			continue
+		}
+
+		// Place b on the same side as a, then find where the edge meets the
+		// meridian between them.
+		bx, meridian := b.X-360, -180.0
+		if b.X < a.X {
+			bx, meridian = b.X+360, 180.0
+		}
+		y := a.Y + (meridian-a.X)/(bx-a.X)*(b.Y-a.Y)
+
+		lines = append(lines, append(current, geos.NewCoord(meridian, y)))
+		current = []geos.Coord{geos.NewCoord(-meridian, y), b}
+	}
+
+	return append(lines, current)
+}
+
+// unwrapRing shifts longitudes so that no edge jumps more than 180 degrees,
+// letting the ring extend beyond the longitude domain.
+func unwrapRing(ring []geos.Coord) ([]geos.Coord, error) {
+
+	unwrapped := make([]geos.Coord, len(ring))
+	unwrapped[0] = ring[0]
+
+	var offset float64
+	for i := 1; i < len(ring); i++ {
+		switch dx := ring[i].X - ring[i-1].X; {
+		case dx > 180:
+			offset -= 360
+		case dx < -180:
+			offset += 360
+		}
+		unwrapped[i] = geos.NewCoord(ring[i].X+offset, ring[i].Y)
+	}
+
+	if offset != 0 {
+		return nil, errors.New("Unable to cut a ring encircling a pole at the antimeridian.")
+	}
+
+	return unwrapped, nil
+}
+
+// cutPolygon unwraps a polygon crossing the antimeridian and clips it into
+// pieces within the longitude domain.
+func cutPolygon(polygon *geos.Geometry) ([]*geos.Geometry, error) {
+
+	rings, err := polygonRings(polygon)
+	if err != nil {
+		return nil, err
+	}
+
+	shell, err := unwrapRing(rings[0])
+	if err != nil {
+		return nil, err
+	}
+	var center float64
+	for _, c := range shell {
+		center += c.X
+	}
+	center /= float64(len(shell))
+
+	var holes [][]geos.Coord
+	for _, ring := range rings[1:] {
+		hole, err := unwrapRing(ring)
+		if err != nil {
+			return nil, err
+		}
+		// Keep each hole in the same frame as the shell.
+		switch {
+		case hole[0].X-center > 180:
+			hole = translateCoords(hole, -360, 0)
+		case center-hole[0].X > 180:
+			hole = translateCoords(hole, 360, 0)
+		}
+		holes = append(holes, hole)
+	}
+
+	unwrapped, err := geos.NewPolygon(shell, holes...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create unwrapped polygon")
+	}
+
+	var pieces []*geos.Geometry
+	for _, shift := range []float64{-360, 0, 360} {
+		frame, err := geos.NewPolygon([]geos.Coord{
+			geos.NewCoord(-180+shift, -90),
+			geos.NewCoord(180+shift, -90),
+			geos.NewCoord(180+shift, 90),
+			geos.NewCoord(-180+shift, 90),
+			geos.NewCoord(-180+shift, -90),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create frame")
+		}
+		clipped, err := unwrapped.Intersection(frame)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not clip polygon at the antimeridian")
+		}
+		parts, err := geometryParts(clipped)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range parts {
+			typer, err := part.Type()
+			if err != nil {
+				return nil, errors.Wrap(err, "could not get geometry type")
+			}
+			if typer != geos.POLYGON {
+				continue
+			}
+			empty, err := part.IsEmpty()
+			if err != nil {
+				return nil, errors.Wrap(err, "could not check empty geometry")
+			}
+			if empty {
+				continue
+			}
+			piece, err := translatePolygon(part, -shift, 0)
+			if err != nil {
+				return nil, err
+			}
+			pieces = append(pieces, piece)
+		}
+	}
+
+	return pieces, nil
+}
+
+// translatePolygon returns a copy of the polygon shifted by dx, dy.
+func translatePolygon(polygon *geos.Geometry, dx, dy float64) (*geos.Geometry, error) {
+
+	rings, err := polygonRings(polygon)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rings {
+		rings[i] = translateCoords(rings[i], dx, dy)
+	}
+
+	translated, err := geos.NewPolygon(rings[0], rings[1:]...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create translated polygon")
+	}
+
+	return translated, nil
+}
@@ -0,0 +1,108 @@
+package terra
+
+import (
+	"testing"
+
+	"github.com/paulsmith/gogeos/geos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLongitudeExtent(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should find the narrowest longitude extent", t, func() {
+
+		west, east := longitudeExtent([][2]float64{{-10, 10}, {20, 30}})
+		So(west, ShouldEqual, -10)
+		So(east, ShouldEqual, 30)
+
+		// Fiji, in pieces either side of the antimeridian.
+		west, east = longitudeExtent([][2]float64{{177, 180}, {-180, -178.5}})
+		So(west, ShouldEqual, 177)
+		So(east, ShouldEqual, -178.5)
+
+		west, east = longitudeExtent([][2]float64{{170, -170}, {-175, -160}})
+		So(west, ShouldEqual, 170)
+		So(east, ShouldEqual, -160)
+	})
+
+	Convey("should cover bounding boxes across the antimeridian", t, func() {
+		So(bboxCovers([]float64{170, -20, -170, -10}, []float64{175, -18, 179, -12}), ShouldBeTrue)
+		So(bboxCovers([]float64{170, -20, -170, -10}, []float64{178, -18, -175, -12}), ShouldBeTrue)
+		So(bboxCovers([]float64{170, -20, -170, -10}, []float64{-175, -18, -160, -12}), ShouldBeFalse)
+	})
+
+	Convey("should cut a line at the antimeridian", t, func() {
+		lines := cutLine([]geos.Coord{geos.NewCoord(179, 0), geos.NewCoord(-179, 2)})
+		So(len(lines), ShouldEqual, 2)
+		So(lines[0], ShouldResemble, []geos.Coord{geos.NewCoord(179, 0), geos.NewCoord(180, 1)})
+		So(lines[1], ShouldResemble, []geos.Coord{geos.NewCoord(-180, 1), geos.NewCoord(-179, 2)})
+	})
+}
+
+func TestAntimeridianStore(t *testing.T) {
+
+	Convey("given a polygon crossing the antimeridian", t, func() {
+
+		store, err := OpenGeostore("./geostore-antimeridian")
+		So(err, ShouldBeNil)
+
+		taveuni, err := NewPolygon([][][]float64{
+			[][]float64{
+				{179.7, -17.0},
+				{-179.8, -17.0},
+				{-179.8, -16.6},
+				{179.7, -16.6},
+				{179.7, -17.0},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		crosses, err := taveuni.CrossesAntimeridian()
+		So(err, ShouldBeNil)
+		So(crosses, ShouldBeTrue)
+
+		Convey("should cut it in two", func() {
+			cut, err := taveuni.CutAntimeridian()
+			So(err, ShouldBeNil)
+			So(cut.Type, ShouldEqual, "MultiPolygon")
+
+			bbox, err := cut.BoundingBox()
+			So(err, ShouldBeNil)
+			So(bbox, ShouldResemble, []float64{179.7, -17.0, -179.8, -16.6})
+
+			rects, err := cut.Rects()
+			So(err, ShouldBeNil)
+			So(len(rects), ShouldEqual, 2)
+		})
+
+		Convey("should only return it for points it contains", func() {
+			_, err := store.Add(taveuni)
+			So(err, ShouldBeNil)
+
+			east, err := NewPoint(-16.8, -179.9)
+			So(err, ShouldBeNil)
+			contains, err := store.Contains(east)
+			So(err, ShouldBeNil)
+			So(len(contains), ShouldEqual, 1)
+
+			west, err := NewPoint(-16.8, 179.9)
+			So(err, ShouldBeNil)
+			contains, err = store.Contains(west)
+			So(err, ShouldBeNil)
+			So(len(contains), ShouldEqual, 1)
+
+			greenwich, err := NewPoint(-16.8, 0)
+			So(err, ShouldBeNil)
+			contains, err = store.Contains(greenwich)
+			So(err, ShouldBeNil)
+			So(len(contains), ShouldEqual, 0)
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,289 @@
+package terra
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// preparedCache holds a gogeos prepared geometry for each stored feature that
+// Aggregate, or Contains through the cell index, has tested, built on first
+// use. Entries are dropped as features are updated or removed.
+type preparedCache struct {
+	sync.Mutex
+	geometries map[string]*geos.PGeometry
+}
+
+func (c *preparedCache) get(key string, feat *Feature) *geos.PGeometry {
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.geometries == nil {
+		c.geometries = make(map[string]*geos.PGeometry)
+	}
+	prepared, ok := c.geometries[key]
+	if !ok {
+		prepared = feat.Geometry.Prepare()
+		c.geometries[key] = prepared
+	}
+
+	return prepared
+}
+
+func (c *preparedCache) forget(key string) {
+	c.Lock()
+	delete(c.geometries, key)
+	c.Unlock()
+}
+
+func (c *preparedCache) reset() {
+	c.Lock()
+	c.geometries = nil
+	c.Unlock()
+}
+
+// geosContexts keeps the GEOS contexts ContainsStream workers run in between
+// streams, so that the geometries prepared in each are kept for the next.
+// A stream takes idle contexts and creates more as it needs them, and the
+// store destroys them all on Close.
+type geosContexts struct {
+	sync.Mutex
+	all  []*geosContext
+	idle []*geosContext
+}
+
+func (p *geosContexts) acquire() (*geosContext, error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if n := len(p.idle); n > 0 {
+		c := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		return c, nil
+	}
+	c, err := newGEOSContext()
+	if err != nil {
+		return nil, err
+	}
+	p.all = append(p.all, c)
+
+	return c, nil
+}
+
+func (p *geosContexts) release(c *geosContext) {
+	p.Lock()
+	p.idle = append(p.idle, c)
+	p.Unlock()
+}
+
+// forget drops the keyed feature from every context, waiting on any worker
+// using one.
+func (p *geosContexts) forget(key string) {
+	p.Lock()
+	defer p.Unlock()
+	for _, c := range p.all {
+		c.Lock()
+		c.forget(key)
+		c.Unlock()
+	}
+}
+
+func (p *geosContexts) reset() {
+	p.Lock()
+	defer p.Unlock()
+	for _, c := range p.all {
+		c.Lock()
+		c.forgetAll()
+		c.Unlock()
+	}
+}
+
+func (p *geosContexts) destroy() {
+	p.Lock()
+	defer p.Unlock()
+	for _, c := range p.all {
+		c.destroy()
+	}
+	p.all, p.idle = nil, nil
+}
+
+// containsTest reports whether the stored feature under the key contains the
+// feature being looked up.
+type containsTest func(key string, candidate *Feature) (bool, error)
+
+// preparedContains tests candidates with the gogeos prepared geometries cached
+// on the store.
+func (g *Geostore) preparedContains(feat *Feature) containsTest {
+	return func(key string, candidate *Feature) (bool, error) {
+		return g.prepared.get(key, candidate).Contains(feat.Geometry)
+	}
+}
+
+// containsPrepared returns the stored features containing the feature, ordered
+// by key, using the cell index for points if there is one and otherwise
+// checking each candidate with the test.
+func (g *Geostore) containsPrepared(feat *Feature, test containsTest) ([]*Feature, error) {
+
+	if list, ok, err := g.containsByCells(feat, test); ok || err != nil {
+		return list, err
+	}
+
+	rects, err := feat.Rects()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := g.searchKeys(rects)
+	keys := make([]string, 0, len(candidates))
+	for key := range candidates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := []*Feature{}
+	for _, key := range keys {
+		candidate := g.entries[key][0].feature
+		ok, err := test(key, candidate)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not test whether %s contains the point", key)
+		}
+		if ok {
+			list = append(list, candidate)
+		}
+	}
+
+	return list, nil
+}
+
+// ContainsResult holds the stored features containing one of the points given
+// to ContainsStream, or the error finding them.
+type ContainsResult struct {
+	Point    *Feature
+	Features []*Feature
+	Err      error
+}
+
+// ContainsStream finds the stored features containing each point read from
+// the channel, using as many workers as given or, if fewer than one, as there
+// are CPUs. Results are sent in the order the points were read, and the
+// channel is closed once the points channel is closed and every result sent,
+// so it must be drained. The store must not change while a stream runs.
+//
+// Each worker tests stored features with GEOS prepared geometries of its own,
+// in a GEOS context of its own, so the predicates run in parallel rather than
+// one at a time on the handle gogeos shares. The contexts are kept on the
+// store between streams, each preparing a candidate the first time it meets
+// it, until the feature is updated or removed.
+func (g *Geostore) ContainsStream(points <-chan *Feature, workers int) <-chan ContainsResult {
+
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	type job struct {
+		i     int
+		point *Feature
+	}
+	type done struct {
+		i      int
+		result ContainsResult
+	}
+
+	var (
+		jobs     = make(chan job, workers)
+		finished = make(chan done, workers)
+		results  = make(chan ContainsResult, workers)
+		// window bounds the results held waiting for an earlier one.
+		window = make(chan struct{}, workers*64)
+		wg     sync.WaitGroup
+	)
+
+	go func() {
+		i := 0
+		for point := range points {
+			window <- struct{}{}
+			jobs <- job{i: i, point: point}
+			i++
+		}
+		close(jobs)
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := g.contexts.acquire()
+			if err == nil {
+				defer g.contexts.release(c)
+			}
+			for j := range jobs {
+				result := ContainsResult{Point: j.point, Err: err}
+				if err == nil {
+					c.Lock()
+					result.Features, result.Err = g.containsInContext(c, j.point)
+					c.Unlock()
+				}
+				finished <- done{i: j.i, result: result}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	go func() {
+		pending := make(map[int]ContainsResult)
+		next := 0
+		for d := range finished {
+			pending[d.i] = d.result
+			for {
+				result, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				results <- result
+				<-window
+				next++
+			}
+		}
+		close(results)
+	}()
+
+	return results
+}
+
+// ContainsMany finds the stored features containing each of the points, as
+// ContainsStream does, returning them in the order of the points, or the
+// first error any point met.
+func (g *Geostore) ContainsMany(points []*Feature, workers int) ([][]*Feature, error) {
+
+	in := make(chan *Feature)
+	go func() {
+		for _, point := range points {
+			in <- point
+		}
+		close(in)
+	}()
+
+	list := make([][]*Feature, 0, len(points))
+	var err error
+	for result := range g.ContainsStream(in, workers) {
+		if err == nil && result.Err != nil {
+			err = result.Err
+		}
+		list = append(list, result.Features)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
@@ -0,0 +1,103 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestContainsMany(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("given a store of polygons", t, func() {
+
+		store, err := OpenGeostore("./geostore-batch")
+		So(err, ShouldBeNil)
+
+		for _, area := range []struct {
+			id                       string
+			west, south, east, north float64
+		}{
+			{"west", -10, -10, 0, 10},
+			{"east", 0, -10, 10, 10},
+			{"north", -10, 0, 10, 10},
+		} {
+			_, err = store.Add(rectangle(area.id, area.west, area.south, area.east, area.north))
+			So(err, ShouldBeNil)
+		}
+
+		var points []*Feature
+		for i := 0; i < 200; i++ {
+			// Sweep west to east, beyond both edges, north and south.
+			lng := -12 + float64(i%50)*0.5
+			lat := 5.0
+			if i >= 100 {
+				lat = -5
+			}
+			point, err := NewPoint(lat, lng)
+			So(err, ShouldBeNil)
+			points = append(points, point)
+		}
+
+		Convey("should match Contains, in the order of the points", func() {
+			results, err := store.ContainsMany(points, 8)
+			So(err, ShouldBeNil)
+			So(len(results), ShouldEqual, len(points))
+			for i, point := range points {
+				expected, err := store.Contains(point)
+				So(err, ShouldBeNil)
+				So(len(results[i]), ShouldEqual, len(expected))
+			}
+
+			// A point in the northwest lies in the west and north polygons.
+			So(len(results[20]), ShouldEqual, 2)
+			So(results[20][0].ID, ShouldEqual, "north")
+			So(results[20][1].ID, ShouldEqual, "west")
+			So(results[0], ShouldBeEmpty)
+		})
+
+		Convey("should stream results in order", func() {
+			in := make(chan *Feature)
+			go func() {
+				for _, point := range points {
+					in <- point
+				}
+				close(in)
+			}()
+			i := 0
+			for result := range store.ContainsStream(in, 0) {
+				So(result.Err, ShouldBeNil)
+				So(result.Point, ShouldEqual, points[i])
+				i++
+			}
+			So(i, ShouldEqual, len(points))
+		})
+
+		Convey("should forget prepared geometries of changed features", func() {
+			results, err := store.ContainsMany(points[:50], 2)
+			So(err, ShouldBeNil)
+			So(len(results[20]), ShouldEqual, 2)
+
+			// Cut north down to a triangle over the same bounds, so the rtree
+			// still offers it for the northwest point, which it no longer holds.
+			triangle, err := NewPolygon([][][]float64{{{-10, 0}, {10, 0}, {10, 10}, {-10, 0}}})
+			So(err, ShouldBeNil)
+			triangle.ID = "north"
+			So(store.Update([]byte("north"), triangle), ShouldBeNil)
+
+			results, err = store.ContainsMany(points[:50], 2)
+			So(err, ShouldBeNil)
+			So(len(results[20]), ShouldEqual, 1)
+			So(results[20][0].ID, ShouldEqual, "west")
+			So(len(results[36]), ShouldEqual, 2)
+			So(results[36][0].ID, ShouldEqual, "east")
+			So(results[36][1].ID, ShouldEqual, "north")
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,276 @@
+package terra
+
+import (
+	"math"
+	"sort"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// minimumRectLength keeps points and axis-aligned lines indexable, as the
+// rtree refuses rectangles without area.
+const minimumRectLength = 0.00001
+
+// geometryBBox computes the [west, south, east, north] extent of a geometry.
+// Each part of a multi-geometry is treated separately, so parts on either side
+// of the antimeridian produce a bbox whose west exceeds its east, as RFC 7946
+// section 5.2 describes, rather than one spanning the planet.
+func geometryBBox(geometry *geos.Geometry) ([]float64, error) {
+
+	parts, err := geometryParts(geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	south, north := math.Inf(1), math.Inf(-1)
+	var intervals [][2]float64
+	for _, part := range parts {
+		coords, err := geometryCoords(part)
+		if err != nil {
+			return nil, err
+		}
+		if len(coords) == 0 {
+			continue
+		}
+		for _, c := range coords {
+			south = math.Min(south, c.Y)
+			north = math.Max(north, c.Y)
+		}
+		intervals = append(intervals, longitudeInterval(coords))
+	}
+
+	if len(intervals) == 0 {
+		return nil, errors.New("Unable to compute a bounding box for an empty geometry.")
+	}
+
+	west, east := longitudeExtent(intervals)
+
+	return []float64{west, south, east, north}, nil
+}
+
+// longitudeInterval returns the [west, east] longitudes covered by one part.
+// A part with an edge jumping more than 180 degrees crosses the antimeridian,
+// and its interval wraps with west greater than east.
+func longitudeInterval(coords []geos.Coord) [2]float64 {
+
+	west, east := math.Inf(1), math.Inf(-1)
+	for _, c := range coords {
+		west = math.Min(west, c.X)
+		east = math.Max(east, c.X)
+	}
+
+	if !crossesAntimeridian(coords) {
+		return [2]float64{west, east}
+	}
+
+	// Measure in a frame where the part is continuous across the antimeridian.
+	west, east = math.Inf(1), math.Inf(-1)
+	for _, c := range coords {
+		x := c.X
+		if x < 0 {
+			x += 360
+		}
+		west = math.Min(west, x)
+		east = math.Max(east, x)
+	}
+
+	return [2]float64{west, east - 360}
+}
+
+// longitudeExtent finds the narrowest [west, east] range covering every
+// interval, allowing the range to wrap across the antimeridian.
+func longitudeExtent(intervals [][2]float64) (float64, float64) {
+
+	var split [][2]float64
+	for _, in := range intervals {
+		if in[0] < -180 || in[1] > 180 {
+			// Outside of the longitude domain, so leave the plane flat.
+			return planarExtent(intervals)
+		}
+		if in[0] > in[1] {
+			split = append(split, [2]float64{in[0], 180}, [2]float64{-180, in[1]})
+			continue
+		}
+		split = append(split, in)
+	}
+
+	sort.Slice(split, func(i, j int) bool {
+		return split[i][0] < split[j][0]
+	})
+
+	merged := [][2]float64{split[0]}
+	for _, in := range split[1:] {
+		last := &merged[len(merged)-1]
+		if in[0] <= last[1] {
+			last[1] = math.Max(last[1], in[1])
+			continue
+		}
+		merged = append(merged, in)
+	}
+
+	// The largest stretch of longitude without any geometry lies outside the
+	// bbox. By default that is the stretch across the antimeridian.
+	west, east := merged[0][0], merged[len(merged)-1][1]
+	gap := merged[0][0] + 360 - merged[len(merged)-1][1]
+	for i := 0; i+1 < len(merged); i++ {
+		if g := merged[i+1][0] - merged[i][1]; g > gap {
+			gap = g
+			west, east = merged[i+1][0], merged[i][1]
+		}
+	}
+
+	return west, east
+}
+
+func planarExtent(intervals [][2]float64) (float64, float64) {
+	west, east := math.Inf(1), math.Inf(-1)
+	for _, in := range intervals {
+		west = math.Min(west, math.Min(in[0], in[1]))
+		east = math.Max(east, math.Max(in[0], in[1]))
+	}
+	return west, east
+}
+
+// crossesAntimeridian reports whether consecutive coordinates jump more than
+// 180 degrees of longitude, which only happens along an edge that is meant to
+// cross the antimeridian.
+func crossesAntimeridian(coords []geos.Coord) bool {
+	for i := 0; i+1 < len(coords); i++ {
+		if math.Abs(coords[i+1].X-coords[i].X) > 180 {
+			return true
+		}
+	}
+	return false
+}
+
+// bboxToRect converts a GeoJSON bbox into a single rectangle, which extends
+// east beyond 180 degrees when the bbox crosses the antimeridian.
+func bboxToRect(bbox []float64) (*rtreego.Rect, error) {
+
+	dimensions := len(bbox) / 2
+	west, south, east, north := bbox[0], bbox[1], bbox[dimensions], bbox[dimensions+1]
+	if west > east {
+		east += 360
+	}
+
+	return newRect(west, south, east, north)
+}
+
+// bboxToRects converts a two or three dimensional GeoJSON bbox into two
+// dimensional rtree rectangles: one, or two when the bbox crosses the
+// antimeridian.
+func bboxToRects(bbox []float64) ([]*rtreego.Rect, error) {
+
+	dimensions := len(bbox) / 2
+	if dimensions < 2 {
+		return nil, errors.Newf("A bounding box requires at least four values, found %d.", len(bbox))
+	}
+
+	west, south := bbox[0], bbox[1]
+	east, north := bbox[dimensions], bbox[dimensions+1]
+
+	if west <= east {
+		rect, err := newRect(west, south, east, north)
+		if err != nil {
+			return nil, err
+		}
+		return []*rtreego.Rect{rect}, nil
+	}
+
+	western, err := newRect(west, south, 180, north)
+	if err != nil {
+		return nil, err
+	}
+	eastern, err := newRect(-180, south, east, north)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*rtreego.Rect{western, eastern}, nil
+}
+
+func newRect(west, south, east, north float64) (*rtreego.Rect, error) {
+
+	rect, err := rtreego.NewRect(
+		rtreego.Point{west, south},
+		[]float64{math.Max(east-west, minimumRectLength), math.Max(north-south, minimumRectLength)},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create rectangle")
+	}
+
+	return rect, nil
+}
+
+// mergeBBox returns the smallest two dimensional bbox that covers both,
+// crossing the antimeridian if that is narrower.
+func mergeBBox(a, b []float64) []float64 {
+	b = []float64{b[0], b[1], b[len(b)/2], b[len(b)/2+1]}
+	if a == nil {
+		return b
+	}
+	west, east := longitudeExtent([][2]float64{{a[0], a[2]}, {b[0], b[2]}})
+	return []float64{west, math.Min(a[1], b[1]), east, math.Max(a[3], b[3])}
+}
+
+// bboxCovers reports whether the outer bbox contains the inner one, either of
+// which may cross the antimeridian.
+func bboxCovers(outer, inner []float64) bool {
+
+	od, id := len(outer)/2, len(inner)/2
+	if inner[1] < outer[1] || inner[id+1] > outer[od+1] {
+		return false
+	}
+
+	wrap := func(x float64) float64 {
+		if x < 0 {
+			return x + 360
+		}
+		return x
+	}
+
+	outerWidth := outer[od] - outer[0]
+	if outerWidth < 0 {
+		outerWidth += 360
+	}
+	innerWidth := inner[id] - inner[0]
+	if innerWidth < 0 {
+		innerWidth += 360
+	}
+	offset := math.Mod(wrap(inner[0]-outer[0]), 360)
+
+	return offset+innerWidth <= outerWidth
+}
+
+// decodeBBox reads and validates a GeoJSON bbox member: an array of 2*n
+// numbers, all the minimums followed by all the maximums.
+func decodeBBox(value interface{}) ([]float64, error) {
+
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.Newf("The bbox member should be an array of numbers: %v.", value)
+	}
+
+	if len(values) < 4 || len(values)%2 != 0 {
+		return nil, errors.Newf("The bbox member should have 2*n values for n dimensions, found %d.", len(values))
+	}
+
+	bbox := make([]float64, len(values))
+	for i := range values {
+		if bbox[i], ok = values[i].(float64); !ok {
+			return nil, errors.Newf("The bbox member should only contain numbers: %v.", values[i])
+		}
+	}
+
+	// Only longitude may wrap, across the antimeridian.
+	dimensions := len(bbox) / 2
+	for i := 1; i < dimensions; i++ {
+		if bbox[i] > bbox[i+dimensions] {
+			return nil, errors.Newf("The bbox minimum %f should not exceed its maximum %f.", bbox[i], bbox[i+dimensions])
+		}
+	}
+
+	return bbox, nil
+}
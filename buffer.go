@@ -0,0 +1,95 @@
+package terra
+
+import (
+	"math"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// geodesicBufferOpts approximates each quarter circle of a metric buffer with
+// enough segments that the chords stay within a few hundredths of a percent of
+// the buffer distance.
+var geodesicBufferOpts = geos.BufferOpts{
+	QuadSegs:   32,
+	CapStyle:   geos.CapRound,
+	JoinStyle:  geos.JoinRound,
+	MitreLimit: 5,
+}
+
+// azimuthalProjection is the ellipsoidal azimuthal equidistant projection
+// about a center, in meters. Distances and azimuths from the center are
+// exact, and others are distorted by roughly the square of their distance
+// from it over the Earth's radius.
+type azimuthalProjection struct {
+	center geos.Coord
+}
+
+func (p azimuthalProjection) forward(coord geos.Coord) geos.Coord {
+	distance, azimuth := geodesicInverse(p.center, coord)
+	sin, cos := math.Sincos(radians(azimuth))
+	return geos.NewCoord(distance*sin, distance*cos)
+}
+
+func (p azimuthalProjection) inverse(coord geos.Coord) geos.Coord {
+	distance := math.Hypot(coord.X, coord.Y)
+	azimuth := degrees(math.Atan2(coord.X, coord.Y))
+	return geodesicDirect(p.center, azimuth, distance)
+}
+
+// GeodesicBuffer returns the area within the given number of meters of a
+// lon/lat feature, measured on the WGS84 ellipsoid. The feature is buffered in
+// an azimuthal equidistant projection about its center, so a point becomes a
+// true geodesic circle, and lines and polygons spanning up to a few hundred
+// kilometers are buffered to well within a tenth of a percent. A negative
+// distance shrinks polygons. Results crossing the antimeridian are cut there;
+// those enclosing a pole return an error. Features in other coordinate
+// reference systems are buffered in WGS84.
+func (feat *Feature) GeodesicBuffer(meters float64, rules ...PropertyRule) (*Feature, error) {
+
+	if feat.Geometry == nil {
+		return nil, errors.New("Unable to compute the geodesic buffer of a feature without geometry.")
+	}
+
+	if !isWGS84(feat.crs()) {
+		var err error
+		if feat, err = feat.Reproject(WGS84); err != nil {
+			return nil, err
+		}
+	}
+
+	bbox, err := feat.BoundingBox()
+	if err != nil {
+		return nil, err
+	}
+	dimensions := len(bbox) / 2
+	west, south, east, north := bbox[0], bbox[1], bbox[dimensions], bbox[dimensions+1]
+	if west > east {
+		east += 360
+	}
+	projection := azimuthalProjection{
+		center: normalizeCoord(geos.NewCoord((west+east)/2, (south+north)/2)),
+	}
+
+	projected, err := transformGeometry(feat.Geometry, projection.forward)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered, err := projected.BufferWithOpts(meters, geodesicBufferOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not compute geodesic buffer")
+	}
+
+	geometry, err := transformGeometry(buffered, projection.inverse)
+	if err != nil {
+		return nil, err
+	}
+
+	derived, err := feat.derive(geometry, nil, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return derived.CutAntimeridian()
+}
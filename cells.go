@@ -0,0 +1,375 @@
+package terra
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// The cell index covers each stored polygon with Web Mercator quadkey cells,
+// those entirely inside it and those on its boundary, down to a finest
+// level. Interior cells may be coarser than that level. It is kept in the
+// index database as well, and loaded into memory when the store opens:
+//
+//	q\x00                                 the cell index is kept, and its level
+//	g\x00<quadkey>\x00<store key>         a cell of a polygon, 1 if interior
+//	k\x00<store key>\x00<quadkey>         the cell, for removing it later
+var (
+	cellDeclaration   = []byte("q\x00")
+	cellPrefix        = []byte("g\x00")
+	cellReversePrefix = []byte("k\x00")
+)
+
+// cellIndex maps quadkeys to the polygons with a cell there, and whether the
+// cell lies inside them.
+type cellIndex struct {
+	level int
+	cells map[string]map[string]bool
+	// uncovered holds the keys of stored features which are not polygons, and
+	// so have no cells.
+	uncovered map[string]bool
+}
+
+func (c *cellIndex) add(quadkey, key string, interior bool) {
+	if c.cells[quadkey] == nil {
+		c.cells[quadkey] = make(map[string]bool)
+	}
+	c.cells[quadkey][key] = interior
+}
+
+// tileXY returns the Web Mercator tile at the zoom level holding the point.
+func tileXY(lon, lat float64, zoom int) (int, int) {
+
+	n := math.Exp2(float64(zoom))
+	sin := math.Sin(lat * math.Pi / 180)
+	x := int(math.Floor((lon + 180) / 360 * n))
+	y := int(math.Floor((0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)) * n))
+
+	// Points on the east and south edges of the map fall in the last tile.
+	max := int(n) - 1
+	if x > max {
+		x = max
+	}
+	if y > max {
+		y = max
+	}
+	return x, y
+}
+
+// tileBounds returns the [west, south, east, north] extent of a tile.
+func tileBounds(x, y, zoom int) []float64 {
+
+	n := math.Exp2(float64(zoom))
+	lat := func(y int) float64 {
+		return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180 / math.Pi
+	}
+
+	return []float64{float64(x)/n*360 - 180, lat(y + 1), float64(x+1)/n*360 - 180, lat(y)}
+}
+
+// quadkey returns the Bing Maps quadkey of a tile.
+func quadkey(x, y, zoom int) string {
+
+	digits := make([]byte, zoom)
+	for i := zoom; i > 0; i-- {
+		digit := byte('0')
+		mask := 1 << uint(i-1)
+		if x&mask != 0 {
+			digit++
+		}
+		if y&mask != 0 {
+			digit += 2
+		}
+		digits[zoom-i] = digit
+	}
+
+	return string(digits)
+}
+
+func bboxPolygon(bbox []float64) (*geos.Geometry, error) {
+
+	polygon, err := geos.NewPolygon([]geos.Coord{
+		geos.NewCoord(bbox[0], bbox[1]),
+		geos.NewCoord(bbox[2], bbox[1]),
+		geos.NewCoord(bbox[2], bbox[3]),
+		geos.NewCoord(bbox[0], bbox[3]),
+		geos.NewCoord(bbox[0], bbox[1]),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create bbox polygon")
+	}
+
+	return polygon, nil
+}
+
+// cover calls fn with the cells covering the polygon down to the level,
+// starting from the tile at x, y and zoom.
+func cover(prepared *geos.PGeometry, rects []*rtreego.Rect, x, y, zoom, level int, fn func(quadkey string, interior bool)) error {
+
+	bounds := tileBounds(x, y, zoom)
+	overlaps := false
+	for _, rect := range rects {
+		west, south := rect.PointCoord(0), rect.PointCoord(1)
+		east, north := west+rect.LengthsCoord(0), south+rect.LengthsCoord(1)
+		if bounds[0] <= east && west <= bounds[2] && bounds[1] <= north && south <= bounds[3] {
+			overlaps = true
+			break
+		}
+	}
+	if !overlaps {
+		return nil
+	}
+
+	box, err := bboxPolygon(bounds)
+	if err != nil {
+		return err
+	}
+	intersects, err := prepared.Intersects(box)
+	if err != nil {
+		return errors.Wrap(err, "could not test tile against polygon")
+	}
+	if !intersects {
+		return nil
+	}
+	inside, err := prepared.ContainsP(box)
+	if err != nil {
+		return errors.Wrap(err, "could not test tile against polygon")
+	}
+	if inside || zoom == level {
+		fn(quadkey(x, y, zoom), inside)
+		return nil
+	}
+
+	for _, child := range [][2]int{{2 * x, 2 * y}, {2*x + 1, 2 * y}, {2 * x, 2*y + 1}, {2*x + 1, 2*y + 1}} {
+		if err := cover(prepared, rects, child[0], child[1], zoom+1, level, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateCellIndex has the store cover each polygon with quadkey cells down to
+// the level, between 1 and 23, so that Contains and ContainsMany can place
+// points inside a polygon with a hash lookup, testing the geometry only for
+// points in cells on its boundary. Higher levels mean fewer tests but more
+// cells: level 12 cells are about ten kilometers across at the equator.
+func (g *Geostore) CreateCellIndex(level int) error {
+
+	if level < 1 || level > 23 {
+		return errors.Newf("The cell level %d is not between 1 and 23.", level)
+	}
+	if g.cells != nil && g.cells.level == level {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(cellDeclaration, []byte(strconv.Itoa(level)))
+	if err := g.deletePrefixes(batch, cellPrefix, cellReversePrefix); err != nil {
+		return err
+	}
+
+	cells := &cellIndex{level: level, cells: make(map[string]map[string]bool), uncovered: make(map[string]bool)}
+	for key, entries := range g.entries {
+		if err := g.coverFeature(batch, cells, key, entries[0].feature); err != nil {
+			return err
+		}
+	}
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrap(err, "could not build cell index")
+	}
+
+	g.cells = cells
+
+	return nil
+}
+
+// DropCellIndex removes the cell index.
+func (g *Geostore) DropCellIndex() error {
+
+	batch := new(leveldb.Batch)
+	batch.Delete(cellDeclaration)
+	if err := g.deletePrefixes(batch, cellPrefix, cellReversePrefix); err != nil {
+		return err
+	}
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrap(err, "could not drop cell index")
+	}
+
+	g.cells = nil
+
+	return nil
+}
+
+// coverFeature adds the cells of the keyed feature to the index and batch.
+func (g *Geostore) coverFeature(batch *leveldb.Batch, cells *cellIndex, key string, feature *Feature) error {
+
+	if !isPolygonal(feature) {
+		cells.uncovered[key] = true
+		return nil
+	}
+
+	rects, err := feature.Rects()
+	if err != nil {
+		return err
+	}
+
+	return cover(feature.Geometry.Prepare(), rects, 0, 0, 0, cells.level, func(q string, interior bool) {
+		value := []byte{0}
+		if interior {
+			value[0] = 1
+		}
+		batch.Put(edgeKey(cellPrefix, q, key), value)
+		batch.Put(edgeKey(cellReversePrefix, key, q), nil)
+		cells.add(q, key, interior)
+	})
+}
+
+// loadCells reads the cell index into memory, if the store keeps one.
+func (g *Geostore) loadCells() error {
+
+	level, err := g.indexDB.Get(cellDeclaration, nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not read cell index declaration")
+	}
+
+	cells := &cellIndex{cells: make(map[string]map[string]bool), uncovered: make(map[string]bool)}
+	if cells.level, err = strconv.Atoi(string(level)); err != nil {
+		return errors.Wrap(err, "could not read cell index level")
+	}
+
+	iter := g.indexDB.NewIterator(util.BytesPrefix(cellPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		rest := iter.Key()[len(cellPrefix):]
+		for i := range rest {
+			if rest[i] == 0 {
+				cells.add(string(rest[:i]), string(rest[i+1:]), iter.Value()[0] == 1)
+				break
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return errors.Wrap(err, "could not read cell index")
+	}
+
+	for key, entries := range g.entries {
+		if !isPolygonal(entries[0].feature) {
+			cells.uncovered[key] = true
+		}
+	}
+
+	g.cells = cells
+
+	return nil
+}
+
+// updateCells replaces the cells of the keyed feature, removing them if the
+// feature is nil.
+func (g *Geostore) updateCells(key string, feature *Feature) error {
+
+	if g.cells == nil {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	quadkeys, err := g.related(cellReversePrefix, key)
+	if err != nil {
+		return err
+	}
+	for _, q := range quadkeys {
+		batch.Delete(edgeKey(cellReversePrefix, key, q))
+		batch.Delete(edgeKey(cellPrefix, q, key))
+		delete(g.cells.cells[q], key)
+		if len(g.cells.cells[q]) == 0 {
+			delete(g.cells.cells, q)
+		}
+	}
+	delete(g.cells.uncovered, key)
+
+	if feature != nil {
+		if err := g.coverFeature(batch, g.cells, key, feature); err != nil {
+			return err
+		}
+	}
+
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrapf(err, "could not update cell index for %s", key)
+	}
+
+	return nil
+}
+
+// containsByCells returns the stored features containing a point using the
+// cell index, ordered by key. It reports false, finding nothing, if there is no
+// cell index, the feature is not a point or it lies beyond the latitudes Web
+// Mercator covers. Candidates on a cell boundary are checked with the test, or
+// with the prepared geometries cached on the store if it is nil.
+func (g *Geostore) containsByCells(feat *Feature, test containsTest) ([]*Feature, bool, error) {
+
+	if g.cells == nil || feat.Type != "Point" || feat.Geometry == nil {
+		return nil, false, nil
+	}
+	if test == nil {
+		test = g.preparedContains(feat)
+	}
+
+	// The bounds of a point are cached, where its coordinates would be read
+	// from GEOS each time.
+	bbox, err := feat.wgs84BoundingBox()
+	if err != nil {
+		return nil, false, err
+	}
+	lon, lat := bbox[0], bbox[1]
+	if math.Abs(lat) >= webMercatorMaxLatitude {
+		return nil, false, nil
+	}
+
+	x, y := tileXY(lon, lat, g.cells.level)
+	finest := quadkey(x, y, g.cells.level)
+
+	candidates := make(map[string]bool)
+	for zoom := 0; zoom <= g.cells.level; zoom++ {
+		for key, interior := range g.cells.cells[finest[:zoom]] {
+			candidates[key] = candidates[key] || interior
+		}
+	}
+	if len(g.cells.uncovered) > 0 {
+		rects, err := feat.Rects()
+		if err != nil {
+			return nil, false, err
+		}
+		for key := range g.searchKeys(rects) {
+			if g.cells.uncovered[key] {
+				candidates[key] = false
+			}
+		}
+	}
+
+	keys := make(map[string]bool, len(candidates))
+	for key, interior := range candidates {
+		if len(g.entries[key]) == 0 {
+			continue
+		}
+		if !interior {
+			ok, err := test(key, g.entries[key][0].feature)
+			if err != nil {
+				return nil, false, errors.Wrapf(err, "could not test whether %s contains the point", key)
+			}
+			if !ok {
+				continue
+			}
+		}
+		keys[key] = true
+	}
+
+	return g.features(keys), true, nil
+}
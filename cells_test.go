@@ -0,0 +1,109 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCellIndex(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should name and bound tiles", t, func() {
+		So(quadkey(3, 5, 3), ShouldEqual, "213")
+		So(quadkey(0, 0, 0), ShouldEqual, "")
+
+		x, y := tileXY(-119.538329, 37.865101, 12)
+		So(x, ShouldEqual, 687)
+		So(y, ShouldEqual, 1581)
+		x, y = tileXY(180, -webMercatorMaxLatitude, 2)
+		So(x, ShouldEqual, 3)
+		So(y, ShouldEqual, 3)
+
+		bounds := tileBounds(1, 1, 1)
+		So(bounds[0], ShouldEqual, 0)
+		So(bounds[1], ShouldAlmostEqual, -webMercatorMaxLatitude, 0.0000001)
+		So(bounds[2], ShouldEqual, 180)
+		So(bounds[3], ShouldAlmostEqual, 0, 0.0000001)
+	})
+
+	Convey("given a store with a cell index", t, func() {
+
+		store, err := OpenGeostore("./geostore-cells")
+		So(err, ShouldBeNil)
+
+		add := func(id string, ring [][]float64) {
+			polygon, err := NewPolygon([][][]float64{ring})
+			So(err, ShouldBeNil)
+			polygon.ID = id
+			_, err = store.Add(polygon)
+			So(err, ShouldBeNil)
+		}
+		add("triangle", [][]float64{{-10, -10}, {10, -10}, {0, 10}, {-10, -10}})
+		So(store.CreateCellIndex(8), ShouldBeNil)
+		add("square", [][]float64{{0, 0}, {20, 0}, {20, 20}, {0, 20}, {0, 0}})
+
+		contains := func(lat, lng float64) []string {
+			point, err := NewPoint(lat, lng)
+			So(err, ShouldBeNil)
+			found, ok, err := store.containsByCells(point, nil)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			expected, err := store.Contains(point)
+			So(err, ShouldBeNil)
+			So(len(found), ShouldEqual, len(expected))
+			ids := []string{}
+			for _, feat := range found {
+				ids = append(ids, feat.ID)
+			}
+			return ids
+		}
+
+		Convey("should cover polygons with interior and boundary cells", func() {
+			interior, boundary := 0, 0
+			for _, features := range store.cells.cells {
+				if inside, ok := features["triangle"]; ok {
+					if inside {
+						interior++
+					} else {
+						boundary++
+					}
+				}
+			}
+			So(interior, ShouldBeGreaterThan, 0)
+			So(boundary, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("should agree with exact tests", func() {
+			So(contains(0, 0), ShouldResemble, []string{"triangle"})
+			So(contains(5, 5), ShouldResemble, []string{"square", "triangle"})
+			So(contains(5.01, 4.99), ShouldResemble, []string{"square", "triangle"})
+			So(contains(15, 15), ShouldResemble, []string{"square"})
+			So(contains(-15, 0), ShouldBeEmpty)
+		})
+
+		Convey("should follow removals and persist", func() {
+			So(store.Remove([]byte("triangle")), ShouldBeNil)
+			So(contains(0, 0), ShouldBeEmpty)
+
+			So(store.Close(), ShouldBeNil)
+			store, err = OpenGeostore("./geostore-cells")
+			So(err, ShouldBeNil)
+			So(store.cells.level, ShouldEqual, 8)
+			So(contains(15, 15), ShouldResemble, []string{"square"})
+
+			point, err := NewPoint(89, 0)
+			So(err, ShouldBeNil)
+			_, ok, err := store.containsByCells(point, nil)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.DropCellIndex(), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
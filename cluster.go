@@ -0,0 +1,531 @@
+package terra
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+const (
+	// ClusterProperty holds the cluster a point was assigned to, and the ID
+	// of a cluster feature.
+	ClusterProperty = "cluster"
+	// ClusterCountProperty holds the number of points in a cluster feature.
+	ClusterCountProperty = "point_count"
+)
+
+// Clustering is the result of clustering points.
+type Clustering struct {
+	// Points are copies of the points, in their original order, with the
+	// cluster each was assigned to, from 0, in ClusterProperty. DBSCAN
+	// assigns noise to -1.
+	Points FeatureCollection
+	// Clusters holds a point at the centroid of each cluster, with its number
+	// and the count of its points.
+	Clusters FeatureCollection
+}
+
+// sourcePoints reads the points of a source and their coordinates, failing
+// on any other feature.
+func sourcePoints(source FeatureSource) ([]*Feature, []geos.Coord, error) {
+
+	points, err := source.sourceFeatures()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coords := make([]geos.Coord, len(points))
+	for i, point := range points {
+		if point.Geometry == nil {
+			return nil, nil, errors.Newf("The feature %s has no geometry.", point.ID)
+		}
+		if point.Type != "Point" {
+			return nil, nil, errors.Newf("The %s feature %s is not a point.", point.Type, point.ID)
+		}
+		x, y, err := point.PointCoords()
+		if err != nil {
+			return nil, nil, err
+		}
+		coords[i] = geos.NewCoord(x, y)
+	}
+
+	return points, coords, nil
+}
+
+// unitVector returns the position on the unit sphere of a lon/lat coordinate.
+func unitVector(coord geos.Coord) [3]float64 {
+
+	sinLat, cosLat := math.Sincos(radians(coord.Y))
+	sinLon, cosLon := math.Sincos(radians(coord.X))
+
+	return [3]float64{cosLat * cosLon, cosLat * sinLon, sinLat}
+}
+
+// normalize scales a vector to the unit sphere, reporting false if it is too
+// short to have a direction.
+func normalize(v [3]float64) ([3]float64, bool) {
+
+	length := math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+	if length < 1e-12 {
+		return v, false
+	}
+
+	return [3]float64{v[0] / length, v[1] / length, v[2] / length}, true
+}
+
+// sphericalCentroid returns the mean position of the coordinates on the
+// sphere, which unlike a mean of longitudes holds across the antimeridian.
+func sphericalCentroid(coords []geos.Coord) geos.Coord {
+
+	var sum [3]float64
+	for _, coord := range coords {
+		v := unitVector(coord)
+		sum[0], sum[1], sum[2] = sum[0]+v[0], sum[1]+v[1], sum[2]+v[2]
+	}
+
+	v, ok := normalize(sum)
+	if !ok {
+		return coords[0]
+	}
+
+	return geos.NewCoord(degrees(math.Atan2(v[1], v[0])), degrees(math.Asin(math.Max(-1, math.Min(1, v[2])))))
+}
+
+// newClustering copies the points with their assignments, and builds a
+// centroid feature for each cluster with points.
+func newClustering(points []*Feature, coords []geos.Coord, assignments []int, clusters int) (*Clustering, error) {
+
+	members := make([][]geos.Coord, clusters)
+	result := &Clustering{
+		Points:   make(FeatureCollection, len(points)),
+		Clusters: FeatureCollection{},
+	}
+
+	for i, point := range points {
+		copied := *point
+		copied.Properties = copyProperties(point.Properties)
+		if copied.Properties == nil {
+			copied.Properties = make(map[string]interface{}, 1)
+		}
+		copied.Properties[ClusterProperty] = assignments[i]
+		result.Points[i] = &copied
+		if assignments[i] >= 0 {
+			members[assignments[i]] = append(members[assignments[i]], coords[i])
+		}
+	}
+
+	for c, coords := range members {
+		if len(coords) == 0 {
+			continue
+		}
+		centroid := sphericalCentroid(coords)
+		feat, err := NewPoint(centroid.Y, centroid.X)
+		if err != nil {
+			return nil, err
+		}
+		feat.ID = strconv.Itoa(c)
+		feat.Properties = map[string]interface{}{ClusterProperty: c, ClusterCountProperty: len(coords)}
+		result.Clusters = append(result.Clusters, feat)
+	}
+
+	return result, nil
+}
+
+// DBSCAN clusters points with at least minPoints points, themselves included,
+// within eps meters on the WGS84 ellipsoid, along with the points within eps
+// of those. Other points are noise. Clusters are numbered in the order their
+// first point appears.
+func DBSCAN(source FeatureSource, eps float64, minPoints int) (*Clustering, error) {
+
+	if eps <= 0 {
+		return nil, errors.Newf("The DBSCAN distance %g is not positive.", eps)
+	}
+	if minPoints < 1 {
+		return nil, errors.Newf("The DBSCAN minimum of %d points is less than one.", minPoints)
+	}
+
+	points, coords, err := sourcePoints(source)
+	if err != nil {
+		return nil, err
+	}
+	index, err := newJoinIndex(points)
+	if err != nil {
+		return nil, err
+	}
+
+	region := func(i int) ([]int, error) {
+		c := coords[i]
+		rects, err := bboxToRects(expandBBox([]float64{c.X, c.Y, c.X, c.Y}, eps))
+		if err != nil {
+			return nil, err
+		}
+		neighbors := []int{}
+		for _, j := range index.search(rects) {
+			if distance, _ := geodesicInverse(c, coords[j]); distance <= eps {
+				neighbors = append(neighbors, j)
+			}
+		}
+		return neighbors, nil
+	}
+
+	const (
+		unvisited = -2
+		noise     = -1
+	)
+	assignments := make([]int, len(points))
+	for i := range assignments {
+		assignments[i] = unvisited
+	}
+
+	clusters := 0
+	for i := range points {
+		if assignments[i] != unvisited {
+			continue
+		}
+		neighbors, err := region(i)
+		if err != nil {
+			return nil, err
+		}
+		if len(neighbors) < minPoints {
+			assignments[i] = noise
+			continue
+		}
+
+		c := clusters
+		clusters++
+		assignments[i] = c
+		for queue := neighbors; len(queue) > 0; queue = queue[1:] {
+			j := queue[0]
+			if assignments[j] == noise {
+				assignments[j] = c
+			}
+			if assignments[j] != unvisited {
+				continue
+			}
+			assignments[j] = c
+			expanded, err := region(j)
+			if err != nil {
+				return nil, err
+			}
+			if len(expanded) >= minPoints {
+				queue = append(queue, expanded...)
+			}
+		}
+	}
+
+	return newClustering(points, coords, assignments, clusters)
+}
+
+// KMeans clusters points into k groups around centers on the sphere, moving
+// each center to the centroid of its points until no point changes group or
+// the iterations, 100 if fewer than one, run out. Centers start at the first
+// point and then, in turn, the point furthest from any center, so results do
+// not vary between runs. There are no more groups than points.
+func KMeans(source FeatureSource, k int, iterations int) (*Clustering, error) {
+
+	if k < 1 {
+		return nil, errors.Newf("Unable to make %d k-means clusters.", k)
+	}
+	if iterations < 1 {
+		iterations = 100
+	}
+
+	points, coords, err := sourcePoints(source)
+	if err != nil {
+		return nil, err
+	}
+	if k > len(points) {
+		k = len(points)
+	}
+
+	vectors := make([][3]float64, len(points))
+	for i, coord := range coords {
+		vectors[i] = unitVector(coord)
+	}
+	dot := func(a, b [3]float64) float64 {
+		return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+	}
+
+	// The nearest center on the sphere is the one with the largest dot product.
+	centers := make([][3]float64, 0, k)
+	nearest := make([]float64, len(points))
+	for i := range nearest {
+		nearest[i] = math.Inf(-1)
+	}
+	for next := 0; len(centers) < k; {
+		centers = append(centers, vectors[next])
+		for i, v := range vectors {
+			nearest[i] = math.Max(nearest[i], dot(v, vectors[next]))
+		}
+		for i := range vectors {
+			if nearest[i] < nearest[next] {
+				next = i
+			}
+		}
+	}
+
+	assignments := make([]int, len(points))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+	for iteration := 0; iteration < iterations; iteration++ {
+		changed := false
+		for i, v := range vectors {
+			best := 0
+			for c := 1; c < k; c++ {
+				if dot(v, centers[c]) > dot(v, centers[best]) {
+					best = c
+				}
+			}
+			if assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([][3]float64, k)
+		for i, v := range vectors {
+			s := &sums[assignments[i]]
+			s[0], s[1], s[2] = s[0]+v[0], s[1]+v[1], s[2]+v[2]
+		}
+		for c, sum := range sums {
+			// A center left without points keeps its place.
+			if center, ok := normalize(sum); ok {
+				centers[c] = center
+			}
+		}
+	}
+
+	return newClustering(points, coords, assignments, k)
+}
+
+// clusterNode is a point or cluster at one zoom level of a ZoomClusterer.
+type clusterNode struct {
+	// x and y are the Web Mercator position, from 0 to 1.
+	x, y     float64
+	lon, lat float64
+	count    int
+	// point is the index of the point a node of a single point stands for,
+	// and -1 for clusters.
+	point int
+	// children are the indexes of the nodes merged into this one at the next
+	// zoom level.
+	children []int
+}
+
+// ZoomClusterer groups points into clusters for each zoom level of a Web
+// Mercator map, as the supercluster library does, merging at each level the
+// points and clusters of the next level within a radius in pixels of each
+// other. Clusters are numbered across levels, so that Leaves can find their
+// points.
+type ZoomClusterer struct {
+	points  []*Feature
+	minZoom int
+	maxZoom int
+	// levels holds the nodes of each zoom level, from minZoom to maxZoom and
+	// then the points themselves.
+	levels [][]clusterNode
+}
+
+// The tile size, in pixels, against which the radius is measured.
+const clusterExtent = 512
+
+func mercatorXY(lon, lat float64) (float64, float64) {
+
+	sin := math.Sin(radians(lat))
+	y := 0.5 - 0.25*math.Log((1+sin)/(1-sin))/math.Pi
+
+	return lon/360 + 0.5, math.Max(0, math.Min(1, y))
+}
+
+func mercatorLonLat(x, y float64) (float64, float64) {
+	return (x - 0.5) * 360, degrees(math.Atan(math.Sinh(math.Pi * (1 - 2*y))))
+}
+
+// NewZoomClusterer clusters the points for every zoom level from minZoom to
+// maxZoom, at most 30, merging points within the radius in pixels of each
+// other on 512 pixel tiles. At zooms beyond maxZoom the points are no longer
+// clustered.
+func NewZoomClusterer(source FeatureSource, radius float64, minZoom, maxZoom int) (*ZoomClusterer, error) {
+
+	if radius <= 0 {
+		return nil, errors.Newf("The cluster radius %g is not positive.", radius)
+	}
+	if minZoom < 0 || maxZoom > 30 || minZoom > maxZoom {
+		return nil, errors.Newf("The zoom levels %d to %d are not between 0 and 30.", minZoom, maxZoom)
+	}
+
+	points, coords, err := sourcePoints(source)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make([]clusterNode, len(points))
+	for i, coord := range coords {
+		x, y := mercatorXY(coord.X, coord.Y)
+		leaves[i] = clusterNode{x: x, y: y, lon: coord.X, lat: coord.Y, count: 1, point: i}
+	}
+
+	c := &ZoomClusterer{
+		points:  points,
+		minZoom: minZoom,
+		maxZoom: maxZoom,
+		levels:  make([][]clusterNode, maxZoom-minZoom+2),
+	}
+	c.levels[len(c.levels)-1] = leaves
+	for zoom := maxZoom; zoom >= minZoom; zoom-- {
+		c.levels[zoom-minZoom] = mergeNodes(c.levels[zoom-minZoom+1], radius/(clusterExtent*math.Exp2(float64(zoom))))
+	}
+
+	return c, nil
+}
+
+// mergeNodes clusters the nodes of a level within r of each other, in Web
+// Mercator units, into the nodes of the next coarser one. Each node in turn
+// takes in the nodes around it not yet taken.
+func mergeNodes(nodes []clusterNode, r float64) []clusterNode {
+
+	type cell struct{ x, y int }
+	grid := make(map[cell][]int)
+	cellOf := func(n clusterNode) cell {
+		return cell{int(math.Floor(n.x / r)), int(math.Floor(n.y / r))}
+	}
+	for i, n := range nodes {
+		grid[cellOf(n)] = append(grid[cellOf(n)], i)
+	}
+
+	taken := make([]bool, len(nodes))
+	merged := []clusterNode{}
+	for i, n := range nodes {
+		if taken[i] {
+			continue
+		}
+		taken[i] = true
+
+		children := []int{i}
+		home := cellOf(n)
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for _, j := range grid[cell{home.x + dx, home.y + dy}] {
+					m := nodes[j]
+					if !taken[j] && (m.x-n.x)*(m.x-n.x)+(m.y-n.y)*(m.y-n.y) <= r*r {
+						taken[j] = true
+						children = append(children, j)
+					}
+				}
+			}
+		}
+		sort.Ints(children)
+
+		if len(children) == 1 {
+			n.children = children
+			merged = append(merged, n)
+			continue
+		}
+
+		node := clusterNode{point: -1, children: children}
+		for _, j := range children {
+			node.x += nodes[j].x * float64(nodes[j].count)
+			node.y += nodes[j].y * float64(nodes[j].count)
+			node.count += nodes[j].count
+		}
+		node.x /= float64(node.count)
+		node.y /= float64(node.count)
+		node.lon, node.lat = mercatorLonLat(node.x, node.y)
+		merged = append(merged, node)
+	}
+
+	return merged
+}
+
+// clusterID numbers a node of a zoom level.
+func clusterID(index, zoom int) int {
+	return index<<5 | zoom
+}
+
+// Clusters returns the clusters and single points at the zoom level whose
+// position lies within the [west, south, east, north] extent, which may cross
+// the antimeridian. Clusters are points with their ID, also held in
+// ClusterProperty, and their count of points in ClusterCountProperty. Single
+// points are returned as given.
+func (c *ZoomClusterer) Clusters(bbox []float64, zoom int) (FeatureCollection, error) {
+
+	if len(bbox) != 4 {
+		return nil, errors.New("A cluster extent must be a [west, south, east, north] bounding box.")
+	}
+	if zoom < c.minZoom {
+		zoom = c.minZoom
+	}
+	if zoom > c.maxZoom+1 {
+		zoom = c.maxZoom + 1
+	}
+
+	within := func(lon, lat float64) bool {
+		if lat < bbox[1] || lat > bbox[3] {
+			return false
+		}
+		if bbox[0] <= bbox[2] {
+			return lon >= bbox[0] && lon <= bbox[2]
+		}
+		return lon >= bbox[0] || lon <= bbox[2]
+	}
+
+	coll := FeatureCollection{}
+	for i, node := range c.levels[zoom-c.minZoom] {
+		if !within(node.lon, node.lat) {
+			continue
+		}
+		if node.point >= 0 {
+			coll = append(coll, c.points[node.point])
+			continue
+		}
+		feat, err := NewPoint(node.lat, node.lon)
+		if err != nil {
+			return nil, err
+		}
+		id := clusterID(i, zoom)
+		feat.ID = strconv.Itoa(id)
+		feat.Properties = map[string]interface{}{ClusterProperty: id, ClusterCountProperty: node.count}
+		coll = append(coll, feat)
+	}
+
+	return coll, nil
+}
+
+// Leaves returns the points of the cluster with the ID, in their original
+// order.
+func (c *ZoomClusterer) Leaves(id int) ([]*Feature, error) {
+
+	index, zoom := id>>5, id&31
+	if zoom < c.minZoom || zoom > c.maxZoom || index < 0 || index >= len(c.levels[zoom-c.minZoom]) {
+		return nil, errors.Newf("There is no cluster %d.", id)
+	}
+
+	indexes := []int{}
+	var collect func(level, i int)
+	collect = func(level, i int) {
+		node := c.levels[level][i]
+		if level == len(c.levels)-1 {
+			indexes = append(indexes, node.point)
+			return
+		}
+		for _, child := range node.children {
+			collect(level+1, child)
+		}
+	}
+	collect(zoom-c.minZoom, index)
+	sort.Ints(indexes)
+
+	leaves := make([]*Feature, len(indexes))
+	for i, point := range indexes {
+		leaves[i] = c.points[point]
+	}
+
+	return leaves, nil
+}
@@ -0,0 +1,123 @@
+package terra
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulsmith/gogeos/geos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCluster(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should average positions on the sphere", t, func() {
+		centroid := sphericalCentroid([]geos.Coord{geos.NewCoord(179, 0), geos.NewCoord(-179, 0)})
+		So(math.Abs(centroid.X), ShouldAlmostEqual, 180, 0.0000001)
+		So(centroid.Y, ShouldAlmostEqual, 0, 0.0000001)
+
+		centroid = sphericalCentroid([]geos.Coord{geos.NewCoord(10, 10)})
+		So(centroid.X, ShouldAlmostEqual, 10, 0.0000001)
+		So(centroid.Y, ShouldAlmostEqual, 10, 0.0000001)
+
+		x, y := mercatorXY(-120, 45)
+		lon, lat := mercatorLonLat(x, y)
+		So(lon, ShouldAlmostEqual, -120, 0.0000001)
+		So(lat, ShouldAlmostEqual, 45, 0.0000001)
+	})
+
+	Convey("should merge nearby nodes by weight", t, func() {
+		merged := mergeNodes([]clusterNode{
+			{x: 0.1, y: 0.1, count: 1, point: 0},
+			{x: 0.5, y: 0.5, count: 1, point: 1},
+			{x: 0.1004, y: 0.1, count: 3, point: -1},
+		}, 0.001)
+		So(len(merged), ShouldEqual, 2)
+		So(merged[0].count, ShouldEqual, 4)
+		So(merged[0].point, ShouldEqual, -1)
+		So(merged[0].children, ShouldResemble, []int{0, 2})
+		So(merged[0].x, ShouldAlmostEqual, 0.1003, 0.0000001)
+		So(merged[1].point, ShouldEqual, 1)
+		So(merged[1].children, ShouldResemble, []int{1})
+	})
+
+	Convey("given two groups of points and a stray", t, func() {
+
+		places := FeatureCollection{}
+		for _, position := range [][2]float64{
+			{0, 0}, {0, 0.001}, {0.001, 0},
+			{10, 10}, {10, 10.001}, {10.001, 10},
+			{-10, -10},
+		} {
+			point, err := NewPoint(position[0], position[1])
+			So(err, ShouldBeNil)
+			places = append(places, point)
+		}
+
+		assignments := func(clustering *Clustering) []int {
+			list := []int{}
+			for _, point := range clustering.Points {
+				list = append(list, point.Properties[ClusterProperty].(int))
+			}
+			return list
+		}
+
+		Convey("DBSCAN should find the groups and leave the stray as noise", func() {
+			clustering, err := DBSCAN(places, 500, 3)
+			So(err, ShouldBeNil)
+			So(assignments(clustering), ShouldResemble, []int{0, 0, 0, 1, 1, 1, -1})
+			So(len(clustering.Clusters), ShouldEqual, 2)
+			So(clustering.Clusters[1].Properties[ClusterCountProperty], ShouldEqual, 3)
+			lng, lat, err := clustering.Clusters[1].PointCoords()
+			So(err, ShouldBeNil)
+			So(lng, ShouldAlmostEqual, 10.00033, 0.0001)
+			So(lat, ShouldAlmostEqual, 10.00033, 0.0001)
+			So(places[0].Properties, ShouldBeNil)
+
+			clustering, err = DBSCAN(places, 50, 3)
+			So(err, ShouldBeNil)
+			So(clustering.Clusters, ShouldBeEmpty)
+		})
+
+		Convey("k-means should split the points", func() {
+			clustering, err := KMeans(places, 3, 0)
+			So(err, ShouldBeNil)
+			So(assignments(clustering), ShouldResemble, []int{0, 0, 0, 1, 1, 1, 2})
+			So(len(clustering.Clusters), ShouldEqual, 3)
+			So(clustering.Clusters[2].Properties[ClusterCountProperty], ShouldEqual, 1)
+
+			clustering, err = KMeans(places, 20, 0)
+			So(err, ShouldBeNil)
+			So(len(clustering.Clusters), ShouldEqual, 7)
+		})
+
+		Convey("zoom clustering should merge points as the map zooms out", func() {
+			clusterer, err := NewZoomClusterer(places, 40, 0, 16)
+			So(err, ShouldBeNil)
+			world := []float64{-180, -90, 180, 90}
+
+			coll, err := clusterer.Clusters(world, 0)
+			So(err, ShouldBeNil)
+			So(len(coll), ShouldEqual, 1)
+			So(coll[0].Properties[ClusterCountProperty], ShouldEqual, 7)
+
+			coll, err = clusterer.Clusters(world, 10)
+			So(err, ShouldBeNil)
+			So(len(coll), ShouldEqual, 3)
+			So(coll[2], ShouldEqual, places[6])
+			So(coll[0].Properties[ClusterCountProperty], ShouldEqual, 3)
+
+			leaves, err := clusterer.Leaves(coll[0].Properties[ClusterProperty].(int))
+			So(err, ShouldBeNil)
+			So(leaves, ShouldResemble, places[:3])
+
+			coll, err = clusterer.Clusters([]float64{5, 5, 15, 15}, 17)
+			So(err, ShouldBeNil)
+			So(coll, ShouldResemble, places[3:6])
+
+			_, err = clusterer.Leaves(12345)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,300 @@
+package terra
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// Bounds returns a rectangle covering every feature of the collection in
+// WGS84, or nil if it is empty. As for a feature, the rectangle extends east
+// beyond 180 degrees when the collection crosses the antimeridian.
+func (coll FeatureCollection) Bounds() *rtreego.Rect {
+
+	var bbox []float64
+	for _, feat := range coll {
+		b, err := feat.wgs84BoundingBox()
+		if err != nil {
+			return nil
+		}
+		bbox = mergeBBox(bbox, b)
+	}
+	if bbox == nil {
+		return nil
+	}
+
+	rect, err := bboxToRect(bbox)
+	if err != nil {
+		return nil
+	}
+
+	return rect
+}
+
+// Filter returns a new collection of the features for which the predicate is
+// true, in their original order.
+func (coll FeatureCollection) Filter(predicate func(*Feature) bool) FeatureCollection {
+
+	features := FeatureCollection{}
+	for _, feat := range coll {
+		if predicate(feat) {
+			features = append(features, feat)
+		}
+	}
+
+	return features
+}
+
+// FilterByProperty returns a new collection of the features whose property
+// equals the value. Numbers of any type compare by value, so 3 matches a
+// decoded 3.0.
+func (coll FeatureCollection) FilterByProperty(name string, value interface{}) FeatureCollection {
+	return coll.Filter(func(feat *Feature) bool {
+		return propertiesEqual(feat.Property(name), value)
+	})
+}
+
+// SortByProperty returns a new collection sorted by the property, keeping the
+// order of features with equal values. Numbers sort before strings, strings
+// before booleans and those before other values, and features lacking the
+// property always come last.
+func (coll FeatureCollection) SortByProperty(name string, descending bool) FeatureCollection {
+
+	features := append(FeatureCollection{}, coll...)
+
+	sort.SliceStable(features, func(i, j int) bool {
+		a, b := features[i].Property(name), features[j].Property(name)
+		switch {
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		case descending:
+			return compareProperties(a, b) > 0
+		}
+		return compareProperties(a, b) < 0
+	})
+
+	return features
+}
+
+// SortByDistance returns a new collection sorted by geodesic distance from the
+// feature, nearest first.
+func (coll FeatureCollection) SortByDistance(from *Feature) (FeatureCollection, error) {
+
+	distances := make(map[*Feature]float64, len(coll))
+	for _, feat := range coll {
+		distance, err := feat.Distance(from)
+		if err != nil {
+			return nil, err
+		}
+		distances[feat] = distance
+	}
+
+	features := append(FeatureCollection{}, coll...)
+	sort.SliceStable(features, func(i, j int) bool {
+		return distances[features[i]] < distances[features[j]]
+	})
+
+	return features, nil
+}
+
+// GroupBy partitions the collection by a key computed for each feature,
+// keeping the original order within each group.
+func (coll FeatureCollection) GroupBy(key func(*Feature) string) map[string]FeatureCollection {
+
+	groups := make(map[string]FeatureCollection)
+	for _, feat := range coll {
+		k := key(feat)
+		groups[k] = append(groups[k], feat)
+	}
+
+	return groups
+}
+
+// GroupByProperty partitions the collection by the value of a property,
+// written as with fmt.Print. Features lacking it are grouped under "".
+func (coll FeatureCollection) GroupByProperty(name string) map[string]FeatureCollection {
+	return coll.GroupBy(func(feat *Feature) string {
+		return propertyKey(feat.Property(name))
+	})
+}
+
+func propertyKey(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// Dissolve unions the geometries of the features sharing a value of the
+// property, returning a new collection with one feature per value in order of
+// first appearance. Each dissolved feature gets a new ID and keeps those
+// properties on which all of its features agree, the dissolving property
+// among them.
+func (coll FeatureCollection) Dissolve(name string) (FeatureCollection, error) {
+
+	var (
+		keys   []string
+		groups = make(map[string][]*Feature)
+	)
+	for _, feat := range coll {
+		if feat.Geometry == nil {
+			return nil, errors.Newf("Unable to dissolve feature %s, which has no geometry.", feat.ID)
+		}
+		k := propertyKey(feat.Property(name))
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], feat)
+	}
+
+	features := FeatureCollection{}
+	for _, k := range keys {
+		group := groups[k]
+
+		geometries := make([]*geos.Geometry, len(group))
+		for i := range group {
+			geometries[i] = group[i].Geometry
+		}
+		geometry, err := unionAll(geometries)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not dissolve features with %s %s", name, k)
+		}
+		typer, err := geometryTypeName(geometry)
+		if err != nil {
+			return nil, err
+		}
+
+		dissolved := NewFeature()
+		dissolved.Properties = commonProperties(group)
+		dissolved.CRS, dissolved.inheritedCRS = group[0].CRS, group[0].inheritedCRS
+		if err := dissolved.SetGeometry(typer, geometry); err != nil {
+			return nil, err
+		}
+		features = append(features, dissolved)
+	}
+
+	return features, nil
+}
+
+// unionAll unions the geometries pairwise, which keeps the intermediate
+// geometries smaller than adding each in turn.
+func unionAll(geometries []*geos.Geometry) (*geos.Geometry, error) {
+
+	for len(geometries) > 1 {
+		var merged []*geos.Geometry
+		for i := 0; i < len(geometries); i += 2 {
+			if i+1 == len(geometries) {
+				merged = append(merged, geometries[i])
+				continue
+			}
+			union, err := geometries[i].Union(geometries[i+1])
+			if err != nil {
+				return nil, errors.Wrap(err, "could not union geometries")
+			}
+			merged = append(merged, union)
+		}
+		geometries = merged
+	}
+
+	if len(geometries) == 1 {
+		// Geometries are never mutated in place, so a lone one is shared as is.
+		return geometries[0], nil
+	}
+
+	return nil, errors.New("There are no geometries to union.")
+}
+
+// commonProperties returns the properties with the same value on every
+// feature.
+func commonProperties(features []*Feature) map[string]interface{} {
+
+	properties := copyProperties(features[0].Properties)
+	for _, feat := range features[1:] {
+		for key, value := range properties {
+			other, ok := feat.Properties[key]
+			if !ok || !propertiesEqual(value, other) {
+				delete(properties, key)
+			}
+		}
+	}
+
+	return properties
+}
+
+// propertyNumber returns the value of a numeric property as a float64.
+func propertyNumber(value interface{}) (float64, bool) {
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	}
+	return 0, false
+}
+
+func propertiesEqual(a, b interface{}) bool {
+	x, ok := propertyNumber(a)
+	y, ook := propertyNumber(b)
+	if ok && ook {
+		return x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// propertyRank orders values of different kinds for sorting.
+func propertyRank(value interface{}) int {
+	if _, ok := propertyNumber(value); ok {
+		return 0
+	}
+	switch value.(type) {
+	case string:
+		return 1
+	case bool:
+		return 2
+	}
+	return 3
+}
+
+// compareProperties returns a negative number if a sorts before b, a positive
+// one if after and zero if they are equal.
+func compareProperties(a, b interface{}) int {
+
+	if ra, rb := propertyRank(a), propertyRank(b); ra != rb {
+		return ra - rb
+	}
+
+	switch x := a.(type) {
+	case string:
+		return strings.Compare(x, b.(string))
+	case bool:
+		switch {
+		case x == b.(bool):
+			return 0
+		case x:
+			return 1
+		}
+		return -1
+	}
+
+	if x, ok := propertyNumber(a); ok {
+		y, _ := propertyNumber(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
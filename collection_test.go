@@ -0,0 +1,87 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollectionToolkit(t *testing.T) {
+
+	t.Parallel()
+
+	coll := FeatureCollection{
+		{ID: "a", Properties: map[string]interface{}{"state": "OR", "population": float64(650000)}},
+		{ID: "b", Properties: map[string]interface{}{"state": "WA", "population": float64(740000)}},
+		{ID: "c", Properties: map[string]interface{}{"state": "OR"}},
+		{ID: "d", Properties: map[string]interface{}{"state": "OR", "population": float64(170000)}},
+	}
+
+	ids := func(c FeatureCollection) []string {
+		list := []string{}
+		for _, feat := range c {
+			list = append(list, feat.ID)
+		}
+		return list
+	}
+
+	Convey("should filter by property, comparing numbers by value", t, func() {
+		So(ids(coll.FilterByProperty("state", "OR")), ShouldResemble, []string{"a", "c", "d"})
+		So(ids(coll.FilterByProperty("population", 740000)), ShouldResemble, []string{"b"})
+		So(len(coll), ShouldEqual, 4)
+	})
+
+	Convey("should filter by predicate", t, func() {
+		filtered := coll.Filter(func(feat *Feature) bool {
+			return feat.Property("population") == nil
+		})
+		So(ids(filtered), ShouldResemble, []string{"c"})
+	})
+
+	Convey("should sort by property with missing values last", t, func() {
+		So(ids(coll.SortByProperty("population", false)), ShouldResemble, []string{"d", "a", "b", "c"})
+		So(ids(coll.SortByProperty("population", true)), ShouldResemble, []string{"b", "a", "d", "c"})
+		So(ids(coll.SortByProperty("state", false)), ShouldResemble, []string{"a", "c", "d", "b"})
+		So(ids(coll), ShouldResemble, []string{"a", "b", "c", "d"})
+	})
+
+	Convey("should group by property", t, func() {
+		groups := coll.GroupByProperty("state")
+		So(len(groups), ShouldEqual, 2)
+		So(ids(groups["OR"]), ShouldResemble, []string{"a", "c", "d"})
+		So(ids(groups["WA"]), ShouldResemble, []string{"b"})
+		So(ids(coll.GroupByProperty("population")[""]), ShouldResemble, []string{"c"})
+	})
+
+	Convey("should keep properties shared by a group", t, func() {
+		properties := commonProperties([]*Feature{coll[0], coll[2]})
+		So(properties, ShouldResemble, map[string]interface{}{"state": "OR"})
+	})
+
+	Convey("should order values of mixed kinds", t, func() {
+		So(compareProperties(2, "1"), ShouldBeLessThan, 0)
+		So(compareProperties("b", "a"), ShouldBeGreaterThan, 0)
+		So(compareProperties(true, false), ShouldBeGreaterThan, 0)
+		So(compareProperties(int64(3), 3.0), ShouldEqual, 0)
+	})
+
+	Convey("should dissolve features by property", t, func() {
+		west, err := NewPolygon([][][]float64{{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}})
+		So(err, ShouldBeNil)
+		west.Properties = map[string]interface{}{"region": "north", "color": "red"}
+		east, err := NewPolygon([][][]float64{{{2, 0}, {4, 0}, {4, 2}, {2, 2}, {2, 0}}})
+		So(err, ShouldBeNil)
+		east.Properties = map[string]interface{}{"region": "north", "color": "blue"}
+		south, err := NewPolygon([][][]float64{{{0, -2}, {2, -2}, {2, -1}, {0, -1}, {0, -2}}})
+		So(err, ShouldBeNil)
+		south.Properties = map[string]interface{}{"region": "south"}
+
+		dissolved, err := FeatureCollection{west, south, east}.Dissolve("region")
+		So(err, ShouldBeNil)
+		So(len(dissolved), ShouldEqual, 2)
+		So(dissolved[0].Type, ShouldEqual, "Polygon")
+		So(dissolved[0].Properties, ShouldResemble, map[string]interface{}{"region": "north"})
+		So(dissolved[1].Property("region"), ShouldEqual, "south")
+		So(dissolved[0].ID, ShouldNotEqual, west.ID)
+	})
+}
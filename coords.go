@@ -0,0 +1,233 @@
+package terra
+
+import (
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// geometryCoords flattens the vertices of a geometry. Polygon holes are skipped
+// because they always lie within the shell.
+func geometryCoords(geometry *geos.Geometry) ([]geos.Coord, error) {
+
+	typer, err := geometry.Type()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get geometry type")
+	}
+
+	switch typer {
+	case geos.POINT, geos.LINESTRING, geos.LINEARRING:
+		var coords []geos.Coord
+		if coords, err = geometry.Coords(); err != nil {
+			return nil, errors.Wrap(err, "could not get geometry coords")
+		}
+		return coords, nil
+	case geos.POLYGON:
+		shell, err := geometry.Shell()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get shell")
+		}
+		coords, err := shell.Coords()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get shell coords")
+		}
+		return coords, nil
+	}
+
+	count, err := geometry.NGeometry()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get geometry count")
+	}
+	var coords []geos.Coord
+	for i := 0; i < count; i++ {
+		g, err := geometry.Geometry(i)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get collection geometry")
+		}
+		c, err := geometryCoords(g)
+		if err != nil {
+			return nil, err
+		}
+		coords = append(coords, c...)
+	}
+
+	return coords, nil
+}
+
+// polygonRings returns the shell of a polygon followed by its holes.
+func polygonRings(polygon *geos.Geometry) ([][]geos.Coord, error) {
+
+	shell, err := polygon.Shell()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get shell")
+	}
+
+	holes, err := polygon.Holes()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get geometry holes")
+	}
+
+	var rings [][]geos.Coord
+	for _, ring := range append([]*geos.Geometry{shell}, holes...) {
+		coords, err := ring.Coords()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get ring coords")
+		}
+		rings = append(rings, coords)
+	}
+
+	return rings, nil
+}
+
+// ringArea is the signed planar area of a closed ring, positive when the ring
+// winds counterclockwise.
+func ringArea(ring []geos.Coord) float64 {
+	var area float64
+	for i := 0; i+1 < len(ring); i++ {
+		area += ring[i].X*ring[i+1].Y - ring[i+1].X*ring[i].Y
+	}
+	return area / 2
+}
+
+// reverseCoords returns a copy of the coordinates in reverse order.
+func reverseCoords(coords []geos.Coord) []geos.Coord {
+	reversed := make([]geos.Coord, len(coords))
+	for i := range coords {
+		reversed[len(coords)-1-i] = coords[i]
+	}
+	return reversed
+}
+
+// geometryParts returns the members of a multi-geometry or collection, or the
+// geometry itself.
+func geometryParts(geometry *geos.Geometry) ([]*geos.Geometry, error) {
+
+	typer, err := geometry.Type()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get geometry type")
+	}
+
+	switch typer {
+	case geos.MULTIPOINT, geos.MULTILINESTRING, geos.MULTIPOLYGON, geos.GEOMETRYCOLLECTION:
+	default:
+		return []*geos.Geometry{geometry}, nil
+	}
+
+	count, err := geometry.NGeometry()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get geometry count")
+	}
+
+	parts := make([]*geos.Geometry, 0, count)
+	for i := 0; i < count; i++ {
+		part, err := geometry.Geometry(i)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get collection geometry")
+		}
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+// translateCoords returns a copy of the coordinates shifted by dx, dy.
+func translateCoords(coords []geos.Coord, dx, dy float64) []geos.Coord {
+	translated := make([]geos.Coord, len(coords))
+	for i := range coords {
+		translated[i] = geos.NewCoord(coords[i].X+dx, coords[i].Y+dy)
+	}
+	return translated
+}
+
+// geometryTypeName returns the GeoJSON type of a geometry.
+func geometryTypeName(geometry *geos.Geometry) (string, error) {
+
+	typer, err := geometry.Type()
+	if err != nil {
+		return "", errors.Wrap(err, "could not get geometry type")
+	}
+
+	switch typer {
+	case geos.POINT:
+		return "Point", nil
+	case geos.LINESTRING, geos.LINEARRING:
+		return "LineString", nil
+	case geos.POLYGON:
+		return "Polygon", nil
+	case geos.MULTIPOINT:
+		return "MultiPoint", nil
+	case geos.MULTILINESTRING:
+		return "MultiLineString", nil
+	case geos.MULTIPOLYGON:
+		return "MultiPolygon", nil
+	}
+
+	return "GeometryCollection", nil
+}
+
+// transformGeometry rebuilds a geometry of any type with each coordinate
+// passed through fn.
+func transformGeometry(geometry *geos.Geometry, fn func(geos.Coord) geos.Coord) (*geos.Geometry, error) {
+
+	typer, err := geometry.Type()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get geometry type")
+	}
+
+	empty, err := geometry.IsEmpty()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not check empty geometry")
+	}
+	if empty {
+		return geometry, nil
+	}
+
+	mapped := func(coords []geos.Coord) []geos.Coord {
+		transformed := make([]geos.Coord, len(coords))
+		for i := range coords {
+			transformed[i] = fn(coords[i])
+		}
+		return transformed
+	}
+
+	var response *geos.Geometry
+	switch typer {
+	case geos.POINT, geos.LINESTRING, geos.LINEARRING:
+		var coords []geos.Coord
+		if coords, err = geometry.Coords(); err != nil {
+			return nil, errors.Wrap(err, "could not get geometry coords")
+		}
+		switch typer {
+		case geos.POINT:
+			response, err = geos.NewPoint(mapped(coords)...)
+		case geos.LINESTRING:
+			response, err = geos.NewLineString(mapped(coords)...)
+		default:
+			response, err = geos.NewLinearRing(mapped(coords)...)
+		}
+	case geos.POLYGON:
+		var rings [][]geos.Coord
+		if rings, err = polygonRings(geometry); err != nil {
+			return nil, err
+		}
+		for i := range rings {
+			rings[i] = mapped(rings[i])
+		}
+		response, err = geos.NewPolygon(rings[0], rings[1:]...)
+	default:
+		var parts []*geos.Geometry
+		if parts, err = geometryParts(geometry); err != nil {
+			return nil, err
+		}
+		for i := range parts {
+			if parts[i], err = transformGeometry(parts[i], fn); err != nil {
+				return nil, err
+			}
+		}
+		response, err = geos.NewCollection(typer, parts...)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create transformed geometry")
+	}
+
+	return response, nil
+}
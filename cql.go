@@ -0,0 +1,970 @@
+package terra
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// Filter is a parsed CQL2 text expression, such as
+//
+//	continent = 'North America' AND pop_est > 100000
+//	  AND S_INTERSECTS(geometry, BBOX(-80, 20, -60, 30))
+//
+// The subset supported is comparisons (=, <>, <, <=, >, >=), LIKE with % and
+// _ wildcards, IN, BETWEEN, IS NULL, each but the comparisons optionally
+// negated with NOT, combined with AND, OR, NOT and parentheses. Operands are
+// property paths, optionally double quoted, 'strings', numbers, TRUE, FALSE,
+// DATE('2006-01-02') and TIMESTAMP('2006-01-02T15:04:05Z'). The spatial
+// functions S_INTERSECTS, S_DISJOINT, S_CONTAINS, S_WITHIN, S_EQUALS,
+// S_TOUCHES, S_CROSSES and S_OVERLAPS compare the feature geometry, named by
+// any property, with BBOX(west, south, east, north) or a WKT literal such as
+// POINT(-70 12).
+//
+// As in SQL, a comparison with a missing or null property is neither true nor
+// false, so that neither it nor its negation matches.
+type Filter struct {
+	text string
+	root filterNode
+}
+
+// ParseFilter parses a CQL2 text expression.
+func ParseFilter(text string) (*Filter, error) {
+
+	tokens, err := lexFilter(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{text: text, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, p.unexpected(t, "the end of the filter")
+	}
+
+	return &Filter{text: text, root: root}, nil
+}
+
+func (f *Filter) String() string {
+	return f.text
+}
+
+// Match reports whether the feature satisfies the filter.
+func (f *Filter) Match(feat *Feature) (bool, error) {
+	t, err := f.root.eval(feat)
+	return t == truthTrue, err
+}
+
+// Where returns a new collection of the features matching the filter.
+func (coll FeatureCollection) Where(filter *Filter) (FeatureCollection, error) {
+
+	features := FeatureCollection{}
+	for _, feat := range coll {
+		ok, err := filter.Match(feat)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			features = append(features, feat)
+		}
+	}
+
+	return features, nil
+}
+
+// Where returns the stored features matching the filter, ordered by key.
+// Comparisons and BETWEEN on indexed properties, and spatial functions
+// against a literal, narrow the candidates when they are joined to the rest of
+// the filter by AND.
+func (g *Geostore) Where(filter *Filter) ([]*Feature, error) {
+
+	candidates, err := g.Query(g.plan(filter.root))
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*Feature{}
+	for _, feat := range candidates {
+		ok, err := filter.Match(feat)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			list = append(list, feat)
+		}
+	}
+
+	return list, nil
+}
+
+// plan returns a query whose results include every feature the filter
+// matches.
+func (g *Geostore) plan(root filterNode) Query {
+
+	var q Query
+	for _, node := range conjuncts(root) {
+		switch n := node.(type) {
+		case *comparisonNode:
+			path, value, op, ok := n.indexable()
+			if !ok || !g.indexed[path] {
+				continue
+			}
+			if _, ok := encodeIndexValue(value); !ok {
+				continue
+			}
+			switch op {
+			case "=":
+				q.Conditions = append(q.Conditions, Equal(path, value))
+			case "<", "<=":
+				q.Conditions = append(q.Conditions, Between(path, nil, value))
+			case ">", ">=":
+				q.Conditions = append(q.Conditions, Between(path, value, nil))
+			}
+		case *betweenNode:
+			property, ok := n.value.(propertyOperand)
+			low, lok := n.low.(literalOperand)
+			high, hok := n.high.(literalOperand)
+			if n.not || !ok || !lok || !hok || !g.indexed[string(property)] {
+				continue
+			}
+			if _, err := Between(string(property), low.literal, high.literal).bounds(); err != nil {
+				continue
+			}
+			q.Conditions = append(q.Conditions, Between(string(property), low.literal, high.literal))
+		case *spatialNode:
+			if n.op == "S_DISJOINT" || q.Intersects != nil {
+				continue
+			}
+			switch {
+			case n.left.literal != nil && n.right.literal == nil:
+				q.Intersects = n.left.literal
+			case n.right.literal != nil && n.left.literal == nil:
+				q.Intersects = n.right.literal
+			}
+		}
+	}
+
+	return q
+}
+
+// conjuncts returns the expressions joined by AND at the top of the filter.
+func conjuncts(node filterNode) []filterNode {
+	if n, ok := node.(*andNode); ok {
+		return append(conjuncts(n.left), conjuncts(n.right)...)
+	}
+	return []filterNode{node}
+}
+
+// truth is a value of three-valued logic, where a comparison with null is
+// unknown.
+type truth int
+
+const (
+	truthFalse truth = iota
+	truthUnknown
+	truthTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+func (t truth) not() truth {
+	return truthTrue - t
+}
+
+type filterNode interface {
+	eval(feat *Feature) (truth, error)
+}
+
+type andNode struct{ left, right filterNode }
+
+func (n *andNode) eval(feat *Feature) (truth, error) {
+
+	left, err := n.left.eval(feat)
+	if err != nil || left == truthFalse {
+		return left, err
+	}
+	right, err := n.right.eval(feat)
+	if err != nil {
+		return 0, err
+	}
+	if right < left {
+		return right, nil
+	}
+	return left, nil
+}
+
+type orNode struct{ left, right filterNode }
+
+func (n *orNode) eval(feat *Feature) (truth, error) {
+
+	left, err := n.left.eval(feat)
+	if err != nil || left == truthTrue {
+		return left, err
+	}
+	right, err := n.right.eval(feat)
+	if err != nil {
+		return 0, err
+	}
+	if right > left {
+		return right, nil
+	}
+	return left, nil
+}
+
+type notNode struct{ operand filterNode }
+
+func (n *notNode) eval(feat *Feature) (truth, error) {
+	t, err := n.operand.eval(feat)
+	return t.not(), err
+}
+
+type literalNode bool
+
+func (n literalNode) eval(*Feature) (truth, error) {
+	return truthOf(bool(n)), nil
+}
+
+// operand is a scalar value in a filter.
+type operand interface {
+	value(feat *Feature) interface{}
+}
+
+type propertyOperand string
+
+func (o propertyOperand) value(feat *Feature) interface{} {
+	return feat.Property(string(o))
+}
+
+type literalOperand struct{ literal interface{} }
+
+func (o literalOperand) value(*Feature) interface{} {
+	return o.literal
+}
+
+// compareOperands compares two non-null values of the same kind, reporting
+// false if they cannot be compared. Times compare with strings holding one.
+func compareOperands(a, b interface{}) (int, bool) {
+
+	x, xok := a.(time.Time)
+	y, yok := b.(time.Time)
+	if xok || yok {
+		var ok bool
+		if !xok {
+			if _, isString := a.(string); !isString {
+				return 0, false
+			}
+			if x, ok = coerceTime(a); !ok {
+				return 0, false
+			}
+		}
+		if !yok {
+			if _, isString := b.(string); !isString {
+				return 0, false
+			}
+			if y, ok = coerceTime(b); !ok {
+				return 0, false
+			}
+		}
+		switch {
+		case x.Before(y):
+			return -1, true
+		case x.After(y):
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if propertyRank(a) != propertyRank(b) || propertyRank(a) > 2 {
+		return 0, false
+	}
+
+	return compareProperties(a, b), true
+}
+
+type comparisonNode struct {
+	op          string
+	left, right operand
+}
+
+func (n *comparisonNode) eval(feat *Feature) (truth, error) {
+
+	a, b := n.left.value(feat), n.right.value(feat)
+	if a == nil || b == nil {
+		return truthUnknown, nil
+	}
+
+	c, ok := compareOperands(a, b)
+	if !ok {
+		return truthOf(n.op == "<>"), nil
+	}
+
+	switch n.op {
+	case "=":
+		return truthOf(c == 0), nil
+	case "<>":
+		return truthOf(c != 0), nil
+	case "<":
+		return truthOf(c < 0), nil
+	case "<=":
+		return truthOf(c <= 0), nil
+	case ">":
+		return truthOf(c > 0), nil
+	}
+	return truthOf(c >= 0), nil
+}
+
+// indexable returns the comparison as property op value, if it compares a
+// property with a literal.
+func (n *comparisonNode) indexable() (string, interface{}, string, bool) {
+
+	if property, ok := n.left.(propertyOperand); ok {
+		if literal, ok := n.right.(literalOperand); ok {
+			return string(property), literal.literal, n.op, true
+		}
+	}
+
+	if property, ok := n.right.(propertyOperand); ok {
+		if literal, ok := n.left.(literalOperand); ok {
+			flipped := map[string]string{"=": "=", "<>": "<>", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+			return string(property), literal.literal, flipped[n.op], true
+		}
+	}
+
+	return "", nil, "", false
+}
+
+type likeNode struct {
+	operand operand
+	pattern *regexp.Regexp
+	not     bool
+}
+
+func (n *likeNode) eval(feat *Feature) (truth, error) {
+
+	value := n.operand.value(feat)
+	if value == nil {
+		return truthUnknown, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return truthFalse, nil
+	}
+
+	t := truthOf(n.pattern.MatchString(s))
+	if n.not {
+		return t.not(), nil
+	}
+	return t, nil
+}
+
+// likePattern compiles a LIKE pattern, in which % matches any run of
+// characters, _ any one and a backslash escapes either.
+func likePattern(pattern string) (*regexp.Regexp, error) {
+
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		return nil, errors.Newf("The LIKE pattern %q ends with an escape.", pattern)
+	}
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
+
+type betweenNode struct {
+	value, low, high operand
+	not              bool
+}
+
+func (n *betweenNode) eval(feat *Feature) (truth, error) {
+
+	value, low, high := n.value.value(feat), n.low.value(feat), n.high.value(feat)
+	if value == nil || low == nil || high == nil {
+		return truthUnknown, nil
+	}
+
+	above, ok := compareOperands(value, low)
+	below, ook := compareOperands(value, high)
+	t := truthOf(ok && ook && above >= 0 && below <= 0)
+	if n.not {
+		return t.not(), nil
+	}
+	return t, nil
+}
+
+type inNode struct {
+	value operand
+	list  []operand
+	not   bool
+}
+
+func (n *inNode) eval(feat *Feature) (truth, error) {
+
+	value := n.value.value(feat)
+	if value == nil {
+		return truthUnknown, nil
+	}
+
+	t := truthFalse
+	for _, item := range n.list {
+		other := item.value(feat)
+		if other == nil {
+			t = truthUnknown
+			continue
+		}
+		if c, ok := compareOperands(value, other); ok && c == 0 {
+			t = truthTrue
+			break
+		}
+	}
+	if n.not {
+		return t.not(), nil
+	}
+	return t, nil
+}
+
+type nullNode struct {
+	value operand
+	not   bool
+}
+
+func (n *nullNode) eval(feat *Feature) (truth, error) {
+	return truthOf((n.value.value(feat) == nil) != n.not), nil
+}
+
+// spatialOperand is the feature geometry, or a literal when one is set.
+type spatialOperand struct {
+	literal *Feature
+}
+
+func (o spatialOperand) geometry(feat *Feature) *geos.Geometry {
+	if o.literal != nil {
+		return o.literal.Geometry
+	}
+	return feat.Geometry
+}
+
+type spatialNode struct {
+	op          string
+	left, right spatialOperand
+}
+
+var spatialFunctions = map[string]func(a, b *geos.Geometry) (bool, error){
+	"S_INTERSECTS": (*geos.Geometry).Intersects,
+	"S_DISJOINT":   (*geos.Geometry).Disjoint,
+	"S_CONTAINS":   (*geos.Geometry).Contains,
+	"S_WITHIN":     (*geos.Geometry).Within,
+	"S_EQUALS":     (*geos.Geometry).Equals,
+	"S_TOUCHES":    (*geos.Geometry).Touches,
+	"S_CROSSES":    (*geos.Geometry).Crosses,
+	"S_OVERLAPS":   (*geos.Geometry).Overlaps,
+}
+
+func (n *spatialNode) eval(feat *Feature) (truth, error) {
+
+	a, b := n.left.geometry(feat), n.right.geometry(feat)
+	if a == nil || b == nil {
+		return truthUnknown, nil
+	}
+
+	ok, err := spatialFunctions[n.op](a, b)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not evaluate %s on feature %s", n.op, feat.ID)
+	}
+
+	return truthOf(ok), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenIdent
+	tokenQuoted
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEnd:
+		return "the end of the filter"
+	case tokenString:
+		return fmt.Sprintf("'%s'", t.text)
+	case tokenQuoted:
+		return fmt.Sprintf("%q", t.text)
+	}
+	return t.text
+}
+
+// is reports whether the token is the symbol or case-insensitive keyword.
+func (t token) is(word string) bool {
+	return (t.kind == tokenIdent || t.kind == tokenSymbol) && strings.EqualFold(t.text, word)
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '.' || r == '[' || r == ']' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func lexFilter(text string) ([]token, error) {
+
+	var tokens []token
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '\'' || r == '"':
+			var b strings.Builder
+			for i++; ; i++ {
+				if i == len(runes) {
+					return nil, errors.Newf("The filter has an unterminated quote at position %d.", start)
+				}
+				if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						b.WriteRune(r)
+						i++
+						continue
+					}
+					break
+				}
+				b.WriteRune(runes[i])
+			}
+			i++
+			kind := tokenString
+			if r == '"' {
+				kind = tokenQuoted
+			}
+			tokens = append(tokens, token{kind: kind, text: b.String(), pos: start})
+		case unicode.IsDigit(r) || ((r == '-' || r == '+' || r == '.') && i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.')):
+			for i++; i < len(runes) && (unicode.IsDigit(runes[i]) || strings.ContainsRune(".eE", runes[i]) ||
+				((runes[i] == '-' || runes[i] == '+') && (runes[i-1] == 'e' || runes[i-1] == 'E'))); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			for i++; i < len(runes) && isIdentRune(runes[i]); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+		case r == '<' || r == '>':
+			i++
+			if i < len(runes) && (runes[i] == '=' || (r == '<' && runes[i] == '>')) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(runes[start:i]), pos: start})
+		case strings.ContainsRune("(),=", r):
+			i++
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(r), pos: start})
+		default:
+			return nil, errors.Newf("The filter has an unexpected character %q at position %d.", r, start)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEnd, pos: len(runes)}), nil
+}
+
+type filterParser struct {
+	text   string
+	tokens []token
+	i      int
+}
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *filterParser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEnd {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the symbol or keyword.
+func (p *filterParser) accept(word string) bool {
+	if p.peek().is(word) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) expect(word string) error {
+	if t := p.next(); !t.is(word) {
+		return p.unexpected(t, word)
+	}
+	return nil
+}
+
+func (p *filterParser) unexpected(t token, expected string) error {
+	return errors.Newf("Unable to parse the filter at position %d: expected %s, found %s.", t.pos, expected, t)
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (filterNode, error) {
+
+	if p.accept("NOT") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand}, nil
+	}
+
+	return p.parsePredicate()
+}
+
+func (p *filterParser) parsePredicate() (filterNode, error) {
+
+	t := p.peek()
+
+	if t.is("(") {
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	if t.kind == tokenIdent {
+		if _, ok := spatialFunctions[strings.ToUpper(t.text)]; ok {
+			return p.parseSpatial()
+		}
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	// A lone boolean literal is a predicate in itself.
+	if literal, ok := left.(literalOperand); ok {
+		if b, ok := literal.literal.(bool); ok {
+			if next := p.peek(); next.kind == tokenEnd || next.is(")") || next.is("AND") || next.is("OR") {
+				return literalNode(b), nil
+			}
+		}
+	}
+
+	t = p.next()
+	switch {
+	case t.kind == tokenSymbol && comparisonOperators[t.text]:
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &comparisonNode{op: t.text, left: left, right: right}, nil
+	case t.is("IS"):
+		not := p.accept("NOT")
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return &nullNode{value: left, not: not}, nil
+	}
+
+	not := false
+	if t.is("NOT") {
+		not, t = true, p.next()
+	}
+
+	switch {
+	case t.is("LIKE"):
+		pattern := p.next()
+		if pattern.kind != tokenString {
+			return nil, p.unexpected(pattern, "a pattern")
+		}
+		compiled, err := likePattern(pattern.text)
+		if err != nil {
+			return nil, err
+		}
+		return &likeNode{operand: left, pattern: compiled, not: not}, nil
+	case t.is("BETWEEN"):
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenNode{value: left, low: low, high: high, not: not}, nil
+	case t.is("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		node := &inNode{value: left, not: not}
+		for {
+			item, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			node.list = append(node.list, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	return nil, p.unexpected(t, "a comparison, LIKE, BETWEEN, IN or IS")
+}
+
+var comparisonOperators = map[string]bool{"=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+var filterKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "BETWEEN": true, "IN": true, "IS": true, "NULL": true,
+}
+
+func (p *filterParser) parseOperand() (operand, error) {
+
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return literalOperand{t.text}, nil
+	case tokenNumber:
+		number, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.unexpected(t, "a number")
+		}
+		return literalOperand{number}, nil
+	case tokenQuoted:
+		return propertyOperand(t.text), nil
+	case tokenIdent:
+		switch word := strings.ToUpper(t.text); {
+		case word == "TRUE" || word == "FALSE":
+			return literalOperand{word == "TRUE"}, nil
+		case (word == "DATE" || word == "TIMESTAMP") && p.peek().is("("):
+			p.next()
+			s := p.next()
+			if s.kind != tokenString {
+				return nil, p.unexpected(s, "a quoted "+strings.ToLower(word))
+			}
+			value, ok := coerceTime(s.text)
+			if !ok {
+				return nil, errors.Newf("Unable to parse the filter at position %d: %s is not a valid %s.", s.pos, s, strings.ToLower(word))
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return literalOperand{value}, nil
+		case filterKeywords[word]:
+			return nil, p.unexpected(t, "a property or value")
+		}
+		if _, err := parsePropertyPath(t.text); err != nil {
+			return nil, err
+		}
+		return propertyOperand(t.text), nil
+	}
+
+	return nil, p.unexpected(t, "a property or value")
+}
+
+func (p *filterParser) parseSpatial() (filterNode, error) {
+
+	op := strings.ToUpper(p.next().text)
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	left, err := p.parseSpatialOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	right, err := p.parseSpatialOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return &spatialNode{op: op, left: left, right: right}, nil
+}
+
+var wktTypes = map[string]string{
+	"POINT":              "Point",
+	"MULTIPOINT":         "MultiPoint",
+	"LINESTRING":         "LineString",
+	"MULTILINESTRING":    "MultiLineString",
+	"POLYGON":            "Polygon",
+	"MULTIPOLYGON":       "MultiPolygon",
+	"GEOMETRYCOLLECTION": "GeometryCollection",
+}
+
+func (p *filterParser) parseSpatialOperand() (spatialOperand, error) {
+
+	t := p.next()
+	switch {
+	case t.kind == tokenQuoted:
+		return spatialOperand{}, nil
+	case t.is("BBOX"):
+		return p.parseBBox()
+	case t.kind == tokenIdent && wktTypes[strings.ToUpper(t.text)] != "":
+		// Find the closing parenthesis and hand the text to GEOS.
+		depth := 0
+		for {
+			next := p.next()
+			switch {
+			case next.kind == tokenEnd:
+				return spatialOperand{}, p.unexpected(next, "the end of the geometry")
+			case next.is("("):
+				depth++
+			case next.is(")"):
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		end := p.tokens[p.i-1].pos + 1
+		wkt := string([]rune(p.text)[t.pos:end])
+		geometry, err := geos.FromWKT(wkt)
+		if err != nil {
+			return spatialOperand{}, errors.Wrapf(err, "could not parse geometry %s", wkt)
+		}
+		literal := NewFeature()
+		if err := literal.SetGeometry(wktTypes[strings.ToUpper(t.text)], geometry); err != nil {
+			return spatialOperand{}, err
+		}
+		return spatialOperand{literal: literal}, nil
+	case t.kind == tokenIdent && !filterKeywords[strings.ToUpper(t.text)]:
+		return spatialOperand{}, nil
+	}
+
+	return spatialOperand{}, p.unexpected(t, "a geometry property, BBOX or geometry literal")
+}
+
+// parseBBox reads the four bounds of a BBOX literal, which becomes a polygon,
+// or a pair of them when west is greater than east.
+func (p *filterParser) parseBBox() (spatialOperand, error) {
+
+	if err := p.expect("("); err != nil {
+		return spatialOperand{}, err
+	}
+	bbox := make([]float64, 4)
+	for i := range bbox {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return spatialOperand{}, err
+			}
+		}
+		t := p.next()
+		number, err := strconv.ParseFloat(t.text, 64)
+		if t.kind != tokenNumber || err != nil {
+			return spatialOperand{}, p.unexpected(t, "a number")
+		}
+		bbox[i] = number
+	}
+	if err := p.expect(")"); err != nil {
+		return spatialOperand{}, err
+	}
+
+	west, south, east, north := bbox[0], bbox[1], bbox[2], bbox[3]
+	if south > north {
+		return spatialOperand{}, errors.Newf("The BBOX %v has its south above its north.", bbox)
+	}
+	ring := func(w, e float64) string {
+		return fmt.Sprintf("((%[1]v %[2]v, %[3]v %[2]v, %[3]v %[4]v, %[1]v %[4]v, %[1]v %[2]v))", w, south, e, north)
+	}
+	typer, wkt := "Polygon", "POLYGON"+ring(west, east)
+	if west > east {
+		typer, wkt = "MultiPolygon", "MULTIPOLYGON("+ring(west, 180)+", "+ring(-180, east)+")"
+	}
+
+	geometry, err := geos.FromWKT(wkt)
+	if err != nil {
+		return spatialOperand{}, errors.Wrap(err, "could not create BBOX geometry")
+	}
+	literal := NewFeature()
+	if err := literal.SetGeometry(typer, geometry); err != nil {
+		return spatialOperand{}, err
+	}
+	literal.BBox = bbox
+
+	return spatialOperand{literal: literal}, nil
+}
@@ -0,0 +1,145 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilter(t *testing.T) {
+
+	t.Parallel()
+
+	aruba := &Feature{ID: "ABW", Properties: map[string]interface{}{
+		"name":      "Aruba",
+		"continent": "North America",
+		"pop_est":   float64(103065),
+		"scalerank": float64(3),
+		"founded":   "1986-01-01",
+		"tags":      []interface{}{"island"},
+		"address":   map[string]interface{}{"city": "Oranjestad"},
+		"note":      nil,
+	}}
+
+	matches := func(text string) bool {
+		filter, err := ParseFilter(text)
+		So(err, ShouldBeNil)
+		ok, err := filter.Match(aruba)
+		So(err, ShouldBeNil)
+		return ok
+	}
+
+	Convey("should evaluate comparisons and logic", t, func() {
+		So(matches(`continent = 'North America' AND pop_est > 100000`), ShouldBeTrue)
+		So(matches(`continent = 'North America' AND pop_est > 1e6`), ShouldBeFalse)
+		So(matches(`scalerank <= 2 OR (name <> 'Anguilla' AND NOT scalerank = 5)`), ShouldBeTrue)
+		So(matches(`100000 < pop_est`), ShouldBeTrue)
+		So(matches(`address.city = 'Oranjestad' and "name" = 'Aruba'`), ShouldBeTrue)
+		So(matches(`name = 3`), ShouldBeFalse)
+		So(matches(`TRUE`), ShouldBeTrue)
+	})
+
+	Convey("should treat comparisons with null as unknown", t, func() {
+		So(matches(`missing = 1`), ShouldBeFalse)
+		So(matches(`NOT missing = 1`), ShouldBeFalse)
+		So(matches(`missing = 1 OR name = 'Aruba'`), ShouldBeTrue)
+		So(matches(`note IS NULL AND missing IS NULL AND name IS NOT NULL`), ShouldBeTrue)
+	})
+
+	Convey("should evaluate LIKE, IN and BETWEEN", t, func() {
+		So(matches(`name LIKE 'Ar_b%'`), ShouldBeTrue)
+		So(matches(`name NOT LIKE 'A%'`), ShouldBeFalse)
+		So(matches(`name LIKE '100\%'`), ShouldBeFalse)
+		So(matches(`continent IN ('Europe', 'North America')`), ShouldBeTrue)
+		So(matches(`scalerank NOT IN (1, 2, 3)`), ShouldBeFalse)
+		So(matches(`pop_est BETWEEN 100000 AND 200000`), ShouldBeTrue)
+		So(matches(`scalerank NOT BETWEEN 1 AND 2`), ShouldBeTrue)
+	})
+
+	Convey("should compare dates", t, func() {
+		So(matches(`founded < DATE('1990-01-01')`), ShouldBeTrue)
+		So(matches(`founded = TIMESTAMP('1986-01-01T00:00:00Z')`), ShouldBeTrue)
+	})
+
+	Convey("should report where a filter fails to parse", t, func() {
+		for _, text := range []string{
+			`name = `,
+			`name = 'Aruba`,
+			`(name = 'Aruba'`,
+			`name ~ 'Aruba'`,
+			`name BETWEEN 1 OR 2`,
+			`founded < DATE('soon')`,
+			`S_INTERSECTS(geometry)`,
+		} {
+			_, err := ParseFilter(text)
+			So(err, ShouldNotBeNil)
+		}
+		_, err := ParseFilter(`name = 'Aruba' scalerank`)
+		So(err.Error(), ShouldEqual, "Unable to parse the filter at position 15: expected the end of the filter, found scalerank.")
+	})
+
+	Convey("should evaluate spatial functions", t, func() {
+		point, err := NewPoint(12.52, -70.03)
+		So(err, ShouldBeNil)
+		point.Properties = aruba.Properties
+
+		for text, want := range map[string]bool{
+			`S_INTERSECTS(geometry, BBOX(-80, 10, -60, 20))`:                        true,
+			`S_WITHIN(geometry, POLYGON((-71 12, -69 12, -69 13, -71 13, -71 12)))`: true,
+			`S_DISJOINT(geometry, BBOX(170, 10, -170, 20))`:                         true,
+			`S_EQUALS(POINT(-70.03 12.52), geometry) AND name = 'Aruba'`:            true,
+			`S_CONTAINS(geometry, BBOX(-80, 10, -60, 20))`:                          false,
+		} {
+			filter, err := ParseFilter(text)
+			So(err, ShouldBeNil)
+			ok, err := filter.Match(point)
+			So(err, ShouldBeNil)
+			So(ok, ShouldEqual, want)
+		}
+	})
+
+	Convey("given a store with an index", t, func() {
+
+		store, err := OpenGeostore("./geostore-filter")
+		So(err, ShouldBeNil)
+		So(store.CreateIndex("scalerank"), ShouldBeNil)
+
+		for i, place := range []struct {
+			lat, lng  float64
+			continent string
+		}{{12.52, -70.03, "North America"}, {18.22, -63.05, "North America"}, {52.37, 4.90, "Europe"}} {
+			point, err := NewPoint(place.lat, place.lng)
+			So(err, ShouldBeNil)
+			point.ID = string(rune('a' + i))
+			point.Properties = map[string]interface{}{"continent": place.continent, "scalerank": i}
+			_, err = store.Add(point)
+			So(err, ShouldBeNil)
+		}
+
+		Convey("should plan indexed and spatial restrictions", func() {
+			filter, err := ParseFilter(`scalerank >= 1 AND continent LIKE 'North%' AND S_INTERSECTS(geometry, BBOX(-80, 10, -60, 20))`)
+			So(err, ShouldBeNil)
+			q := store.plan(filter.root)
+			So(q.Conditions, ShouldResemble, []Condition{Between("scalerank", float64(1), nil)})
+			So(q.Intersects, ShouldNotBeNil)
+
+			found, err := store.Where(filter)
+			So(err, ShouldBeNil)
+			So(len(found), ShouldEqual, 1)
+			So(found[0].ID, ShouldEqual, "b")
+
+			filter, err = ParseFilter(`scalerank = 0 OR continent = 'Europe'`)
+			So(err, ShouldBeNil)
+			So(store.plan(filter.root).Conditions, ShouldBeEmpty)
+			found, err = store.Where(filter)
+			So(err, ShouldBeNil)
+			So(len(found), ShouldEqual, 2)
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.DropIndex("scalerank"), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,347 @@
+package terra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// CRS is a coordinate reference system: a datum, and for projected systems the
+// projection from its longitudes and latitudes to easting and northing in
+// meters. Geographic coordinates are always ordered longitude then latitude,
+// as GeoJSON writes them, whatever the axis order of the EPSG definition.
+type CRS struct {
+	// Name identifies the system, as "EPSG:<code>" for those in the registry.
+	Name  string
+	Datum Datum
+	// Projection is nil for a geographic system.
+	Projection Projection
+}
+
+func (crs *CRS) String() string {
+	return crs.Name
+}
+
+// URN returns the name of the system in the form a legacy GeoJSON "crs"
+// member uses.
+func (crs *CRS) URN() string {
+	if code, ok := crs.EPSG(); ok {
+		return fmt.Sprintf("urn:ogc:def:crs:EPSG::%d", code)
+	}
+	return crs.Name
+}
+
+// EPSG returns the EPSG code of the system, if it has one.
+func (crs *CRS) EPSG() (int, bool) {
+	if !strings.HasPrefix(crs.Name, "EPSG:") {
+		return 0, false
+	}
+	code, err := strconv.Atoi(crs.Name[len("EPSG:"):])
+	return code, err == nil
+}
+
+// isWGS84 reports whether coordinates in the system are WGS84 longitudes and
+// latitudes. A feature without a CRS is taken to be.
+func isWGS84(crs *CRS) bool {
+	return crs == nil || crs.Name == WGS84.Name
+}
+
+// sameCRS reports whether the two systems are the same.
+func sameCRS(a, b *CRS) bool {
+	if isWGS84(a) || isWGS84(b) {
+		return isWGS84(a) && isWGS84(b)
+	}
+	return a.Name == b.Name
+}
+
+// crs returns the system the coordinates of the feature are in: the one it
+// declares, or else the one inherited from its collection.
+func (feat *Feature) crs() *CRS {
+	if feat.CRS != nil {
+		return feat.CRS
+	}
+	return feat.inheritedCRS
+}
+
+// wgs84 returns the feature itself if it is in WGS84, and otherwise a copy
+// reprojected into it, for measuring and indexing.
+func (feat *Feature) wgs84() (*Feature, error) {
+	if isWGS84(feat.crs()) {
+		return feat, nil
+	}
+	return feat.Reproject(WGS84)
+}
+
+var (
+	// WGS84 is the geographic system GeoJSON uses, EPSG:4326.
+	WGS84 = &CRS{Name: "EPSG:4326", Datum: WGS84Datum}
+	// WebMercator is the spherical Mercator system of web maps, EPSG:3857.
+	WebMercator = &CRS{Name: "EPSG:3857", Datum: WGS84Datum, Projection: webMercator{}}
+)
+
+// UTM returns the WGS84 Universal Transverse Mercator system for the zone, in
+// its northern or southern hemisphere variant.
+func UTM(zone int, north bool) (*CRS, error) {
+
+	if zone < 1 || zone > 60 {
+		return nil, errors.Newf("There is no UTM zone %d.", zone)
+	}
+
+	if north {
+		return utm(fmt.Sprintf("EPSG:%d", 32600+zone), WGS84Datum, zone, true), nil
+	}
+	return utm(fmt.Sprintf("EPSG:%d", 32700+zone), WGS84Datum, zone, false), nil
+}
+
+func utm(name string, datum Datum, zone int, north bool) *CRS {
+	projection := TransverseMercator{
+		CentralMeridian: float64(zone)*6 - 183,
+		ScaleFactor:     0.9996,
+		FalseEasting:    500000,
+	}
+	if !north {
+		projection.FalseNorthing = 10000000
+	}
+	return &CRS{Name: name, Datum: datum, Projection: projection}
+}
+
+// NewCRS returns a system with the given name projecting the datum, such as a
+// national grid absent from the registry.
+func NewCRS(name string, datum Datum, projection Projection) *CRS {
+	return &CRS{Name: name, Datum: datum, Projection: projection}
+}
+
+// EPSGCode returns the registered system with the code. Besides WGS84, Web
+// Mercator and the UTM zones, a few national grids built on transverse
+// Mercator and Lambert conformal conic projections are known.
+func EPSGCode(code int) (*CRS, error) {
+
+	name := fmt.Sprintf("EPSG:%d", code)
+
+	switch {
+	case code == 4326:
+		return WGS84, nil
+	case code == 3857, code == 900913, code == 3785, code == 102100:
+		return WebMercator, nil
+	case code == 4258:
+		return &CRS{Name: name, Datum: ETRS89Datum}, nil
+	case code == 4269:
+		return &CRS{Name: name, Datum: NAD83Datum}, nil
+	case code > 32600 && code <= 32660:
+		return UTM(code-32600, true)
+	case code > 32700 && code <= 32760:
+		return UTM(code-32700, false)
+	case code >= 25828 && code <= 25838:
+		return utm(name, ETRS89Datum, code-25800, true), nil
+	case code >= 26901 && code <= 26923:
+		return utm(name, NAD83Datum, code-26900, true), nil
+	case code == 27700:
+		// British National Grid.
+		return NewCRS(name, OSGB36Datum, TransverseMercator{
+			CentralMeridian:  -2,
+			LatitudeOfOrigin: 49,
+			ScaleFactor:      0.9996012717,
+			FalseEasting:     400000,
+			FalseNorthing:    -100000,
+		}), nil
+	case code == 2193:
+		// New Zealand Transverse Mercator 2000.
+		return NewCRS(name, NZGD2000Datum, TransverseMercator{
+			CentralMeridian: 173,
+			ScaleFactor:     0.9996,
+			FalseEasting:    1600000,
+			FalseNorthing:   10000000,
+		}), nil
+	case code == 2154:
+		// RGF93 / Lambert-93, France.
+		return NewCRS(name, ETRS89Datum, LambertConformalConic{
+			CentralMeridian:   3,
+			LatitudeOfOrigin:  46.5,
+			StandardParallel1: 44,
+			StandardParallel2: 49,
+			FalseEasting:      700000,
+			FalseNorthing:     6600000,
+		}), nil
+	case code == 3034:
+		// ETRS89 / LCC Europe.
+		return NewCRS(name, ETRS89Datum, LambertConformalConic{
+			CentralMeridian:   10,
+			LatitudeOfOrigin:  52,
+			StandardParallel1: 35,
+			StandardParallel2: 65,
+			FalseEasting:      4000000,
+			FalseNorthing:     2800000,
+		}), nil
+	case code == 3347:
+		// NAD83 / Statistics Canada Lambert.
+		return NewCRS(name, NAD83Datum, LambertConformalConic{
+			CentralMeridian:   -91.866666666667,
+			LatitudeOfOrigin:  63.390675,
+			StandardParallel1: 49,
+			StandardParallel2: 77,
+			FalseEasting:      6200000,
+			FalseNorthing:     3000000,
+		}), nil
+	}
+
+	return nil, errors.Newf("The coordinate reference system %s is not supported.", name)
+}
+
+// ParseCRS returns the system a name refers to, accepting the forms found in
+// legacy GeoJSON "crs" members and elsewhere: "EPSG:3857",
+// "urn:ogc:def:crs:EPSG::3857", "http://www.opengis.net/def/crs/EPSG/0/3857"
+// and "urn:ogc:def:crs:OGC:1.3:CRS84".
+func ParseCRS(name string) (*CRS, error) {
+
+	upper := strings.ToUpper(strings.TrimSpace(name))
+
+	if strings.HasSuffix(upper, "CRS84") {
+		return WGS84, nil
+	}
+
+	if !strings.Contains(upper, "EPSG") {
+		return nil, errors.Newf("Unable to recognize the coordinate reference system %s.", name)
+	}
+
+	i := strings.LastIndexAny(upper, ":/")
+	code, err := strconv.Atoi(upper[i+1:])
+	if err != nil {
+		return nil, errors.Newf("Unable to find an EPSG code in %s.", name)
+	}
+
+	return EPSGCode(code)
+}
+
+// decodeCRS reads a legacy GeoJSON "crs" member, of either the named or the
+// older EPSG form.
+func decodeCRS(member interface{}) (*CRS, error) {
+
+	crs, ok := member.(map[string]interface{})
+	if !ok {
+		return nil, errors.Newf("The crs member should be an object: %v.", member)
+	}
+	properties, _ := crs["properties"].(map[string]interface{})
+
+	switch crs["type"] {
+	case "name":
+		name, ok := properties["name"].(string)
+		if !ok {
+			return nil, errors.Newf("A named crs member should have a name property: %v.", member)
+		}
+		return ParseCRS(name)
+	case "EPSG":
+		code, ok := properties["code"].(float64)
+		if !ok {
+			return nil, errors.Newf("An EPSG crs member should have a numeric code property: %v.", member)
+		}
+		return EPSGCode(int(code))
+	}
+
+	return nil, errors.Newf("Unable to decode a crs member of type %v.", crs["type"])
+}
+
+type geoJSONCRSEncodeType struct {
+	Type       string            `json:"type"`
+	Properties map[string]string `json:"properties"`
+}
+
+// encodeCRS writes a legacy "crs" member for any system other than WGS84.
+func encodeCRS(crs *CRS) *geoJSONCRSEncodeType {
+	if isWGS84(crs) {
+		return nil
+	}
+	return &geoJSONCRSEncodeType{
+		Type:       "name",
+		Properties: map[string]string{"name": crs.URN()},
+	}
+}
+
+// isWGS84 reports whether all of the features are in WGS84.
+func (coll FeatureCollection) isWGS84() bool {
+	for _, feat := range coll {
+		if !isWGS84(feat.crs()) {
+			return false
+		}
+	}
+	return true
+}
+
+// transform converts a coordinate from one system into another, passing
+// through longitude and latitude and, between datums, geocentric coordinates.
+func transform(from, to *CRS) func(geos.Coord) geos.Coord {
+
+	if from == nil {
+		from = WGS84
+	}
+	if to == nil {
+		to = WGS84
+	}
+
+	return func(coord geos.Coord) geos.Coord {
+		lon, lat := coord.X, coord.Y
+		if from.Projection != nil {
+			lon, lat = from.Projection.Inverse(from.Datum.Ellipsoid, coord.X, coord.Y)
+		}
+		if from.Datum != to.Datum {
+			lon, lat = shiftDatum(from.Datum, to.Datum, lon, lat)
+		}
+		if to.Projection != nil {
+			x, y := to.Projection.Forward(to.Datum.Ellipsoid, lon, lat)
+			return geos.NewCoord(x, y)
+		}
+		return geos.NewCoord(lon, lat)
+	}
+}
+
+// Reproject returns a copy of the feature with its coordinates converted into
+// another system. A feature without a CRS is taken to be in WGS84. A system
+// the feature inherited from its collection is replaced by an inherited one,
+// so the copy still declares none of its own.
+func (feat *Feature) Reproject(to *CRS) (*Feature, error) {
+
+	if feat.Geometry == nil {
+		return nil, errors.New("Unable to reproject a feature without geometry.")
+	}
+
+	geometry, err := transformGeometry(feat.Geometry, transform(feat.crs(), to))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not reproject feature %s to %s", feat.ID, to)
+	}
+
+	reprojected := &Feature{
+		ID:             feat.ID,
+		Properties:     feat.Properties,
+		ForeignMembers: feat.ForeignMembers,
+		sourceID:       feat.sourceID,
+		generatedID:    feat.generatedID,
+	}
+	if feat.CRS == nil && feat.inheritedCRS != nil {
+		reprojected.inheritedCRS = to
+	} else {
+		reprojected.CRS = to
+	}
+	if err := reprojected.SetGeometry(feat.Type, geometry); err != nil {
+		return nil, err
+	}
+
+	return reprojected, nil
+}
+
+// Reproject returns a new collection with every feature converted into
+// another system.
+func (coll FeatureCollection) Reproject(to *CRS) (FeatureCollection, error) {
+
+	reprojected := make(FeatureCollection, 0, len(coll))
+	for _, feat := range coll {
+		feature, err := feat.Reproject(to)
+		if err != nil {
+			return nil, err
+		}
+		reprojected = append(reprojected, feature)
+	}
+
+	return reprojected, nil
+}
@@ -0,0 +1,143 @@
+package terra
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paulsmith/gogeos/geos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCRS(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should parse the common ways of naming a system", t, func() {
+		for _, name := range []string{"urn:ogc:def:crs:OGC:1.3:CRS84", "EPSG:4326", "urn:ogc:def:crs:EPSG::4326"} {
+			crs, err := ParseCRS(name)
+			So(err, ShouldBeNil)
+			So(crs, ShouldEqual, WGS84)
+		}
+		crs, err := ParseCRS("http://www.opengis.net/def/crs/EPSG/0/32633")
+		So(err, ShouldBeNil)
+		So(crs.Name, ShouldEqual, "EPSG:32633")
+		So(crs.URN(), ShouldEqual, "urn:ogc:def:crs:EPSG::32633")
+
+		_, err = ParseCRS("EPSG:99999")
+		So(err, ShouldNotBeNil)
+		_, err = ParseCRS("urn:ogc:def:crs:OGC:1.3:CRS27")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("should decode legacy crs members", t, func() {
+		crs, err := decodeCRS(map[string]interface{}{
+			"type":       "EPSG",
+			"properties": map[string]interface{}{"code": float64(3857)},
+		})
+		So(err, ShouldBeNil)
+		So(crs, ShouldEqual, WebMercator)
+
+		_, err = decodeCRS(map[string]interface{}{
+			"type":       "link",
+			"properties": map[string]interface{}{"href": "http://example.com/crs/42"},
+		})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("should project to Web Mercator", t, func() {
+		c := transform(WGS84, WebMercator)(geos.NewCoord(180, webMercatorMaxLatitude))
+		So(c.X, ShouldAlmostEqual, 20037508.342789, 0.001)
+		So(c.Y, ShouldAlmostEqual, 20037508.342789, 0.001)
+	})
+
+	Convey("should project to UTM", t, func() {
+		crs, err := UTM(31, true)
+		So(err, ShouldBeNil)
+		c := transform(WGS84, crs)(geos.NewCoord(3, 0))
+		So(c.X, ShouldAlmostEqual, 500000, 0.001)
+		So(c.Y, ShouldAlmostEqual, 0, 0.001)
+
+		south, err := UTM(31, false)
+		So(err, ShouldBeNil)
+		c = transform(south, WGS84)(transform(WGS84, south)(geos.NewCoord(5.5, -33.9)))
+		So(c.X, ShouldAlmostEqual, 5.5, 0.000000001)
+		So(c.Y, ShouldAlmostEqual, -33.9, 0.000000001)
+
+		_, err = UTM(61, true)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("should project the Ordnance Survey's worked example", t, func() {
+		grid, err := EPSGCode(27700)
+		So(err, ShouldBeNil)
+		// Caister water tower, on the OSGB36 datum.
+		x, y := grid.Projection.Forward(Airy1830Ellipsoid, 1.71792158, 52.65757031)
+		So(x, ShouldAlmostEqual, 651409.903, 0.01)
+		So(y, ShouldAlmostEqual, 313177.270, 0.01)
+	})
+
+	Convey("should place the origin of Lambert-93", t, func() {
+		crs, err := EPSGCode(2154)
+		So(err, ShouldBeNil)
+		x, y := crs.Projection.Forward(GRS80Ellipsoid, 3, 46.5)
+		So(x, ShouldAlmostEqual, 700000, 0.001)
+		So(y, ShouldAlmostEqual, 6600000, 0.001)
+		lon, lat := crs.Projection.Inverse(GRS80Ellipsoid, 652469.02, 6862035.26)
+		So(lon, ShouldAlmostEqual, 2.3522, 0.0001)
+		So(lat, ShouldAlmostEqual, 48.8566, 0.0001)
+	})
+
+	Convey("should shift between datums", t, func() {
+		grid, err := EPSGCode(27700)
+		So(err, ShouldBeNil)
+		c := transform(WGS84, grid)(geos.NewCoord(-0.1276, 51.5072))
+		back := transform(grid, WGS84)(c)
+		So(back.X, ShouldAlmostEqual, -0.1276, 0.000001)
+		So(back.Y, ShouldAlmostEqual, 51.5072, 0.000001)
+		// OSGB36 lies about a hundred meters east of WGS84 in London.
+		lon, _ := shiftDatum(WGS84Datum, OSGB36Datum, -0.1276, 51.5072)
+		So(lon, ShouldAlmostEqual, -0.1260, 0.0005)
+	})
+
+	Convey("should decode a projected collection and reproject it", t, func() {
+		doc, err := NewFeatureCollectionDocumentFromJSON([]byte(`{
+			"type": "FeatureCollection",
+			"crs": { "type": "name", "properties": { "name": "urn:ogc:def:crs:EPSG::3857" } },
+			"features": [
+				{ "type": "Feature", "properties": {}, "geometry": { "type": "Point", "coordinates": [ 1113194.908, 0 ] } }
+			]
+		}`))
+		So(err, ShouldBeNil)
+		So(doc.CRS, ShouldEqual, WebMercator)
+		So(doc.Features[0].CRS, ShouldBeNil)
+		So(doc.Features[0].crs(), ShouldEqual, WebMercator)
+
+		reprojected, err := doc.Features[0].Reproject(WGS84)
+		So(err, ShouldBeNil)
+		x, y, err := reprojected.PointCoords()
+		So(err, ShouldBeNil)
+		So(x, ShouldAlmostEqual, 10, 0.000001)
+		So(y, ShouldAlmostEqual, 0, 0.000001)
+
+		// The inherited system is measured in, but written only on the collection.
+		north, err := NewPoint(1, 10)
+		So(err, ShouldBeNil)
+		distance, err := doc.Features[0].Distance(north)
+		So(err, ShouldBeNil)
+		So(distance, ShouldAlmostEqual, 110574, 1)
+		So(doc.Features[0].Bounds().PointCoord(0), ShouldAlmostEqual, 10, 0.000001)
+
+		encoded, err := doc.ToJSON()
+		So(err, ShouldBeNil)
+		So(string(encoded), ShouldContainSubstring, `"crs":{"type":"name","properties":{"name":"urn:ogc:def:crs:EPSG::3857"}}`)
+		So(strings.Count(string(encoded), `"crs"`), ShouldEqual, 1)
+
+		encoded, err = doc.Features[0].ToJSON()
+		So(err, ShouldBeNil)
+		So(string(encoded), ShouldContainSubstring, `"crs"`)
+
+		encoded, err = doc.ToJSON(WithRFC7946())
+		So(err, ShouldBeNil)
+		So(string(encoded), ShouldNotContainSubstring, `"crs"`)
+	})
+}
@@ -20,25 +20,60 @@ func NewFeatureFromJSON(request []byte) (*Feature, error) {
 
 func NewFeatureCollectionFromJSON(request []byte) (FeatureCollection, error) {
 
-	type geoJSONFeatureType struct {
-		Features []map[string]interface{} `json:"features"`
+	doc, err := NewFeatureCollectionDocumentFromJSON(request)
+	if err != nil {
+		return nil, err
 	}
 
-	var geo geoJSONFeatureType
+	return doc.Features, nil
+}
+
+// NewFeatureCollectionDocumentFromJSON decodes a FeatureCollection along with
+// its bbox, crs and foreign members.
+func NewFeatureCollectionDocumentFromJSON(request []byte) (*FeatureCollectionDocument, error) {
+
+	var geo map[string]interface{}
 	if err := json.Unmarshal(request, &geo); err != nil {
 		return nil, errors.Wrap(err, "could not unmarhsal geojson feature type")
 	}
 
-	var coll FeatureCollection
-	for i := range geo.Features {
-		feat, err := decodeFeature(geo.Features[i])
+	doc := FeatureCollectionDocument{}
+
+	if b, ok := geo["bbox"]; ok && b != nil {
+		var err error
+		if doc.BBox, err = decodeBBox(b); err != nil {
+			return nil, errors.Wrap(err, "invalid feature collection bbox")
+		}
+	}
+
+	if c, ok := geo["crs"]; ok && c != nil {
+		var err error
+		if doc.CRS, err = decodeCRS(c); err != nil {
+			return nil, errors.Wrap(err, "invalid feature collection crs")
+		}
+	}
+
+	features, ok := geo["features"].([]interface{})
+	if !ok && geo["features"] != nil {
+		return nil, errors.Newf("The features member should be an array: %v.", geo["features"])
+	}
+
+	for i := range features {
+		f, ok := features[i].(map[string]interface{})
+		if !ok {
+			return nil, errors.Newf("Each element of the features array should be an object: %v.", features[i])
+		}
+		feat, err := decodeFeature(f)
 		if err != nil {
 			return nil, err
 		}
-		coll = append(coll, feat)
+		feat.inheritedCRS = doc.CRS
+		doc.Features = append(doc.Features, feat)
 	}
 
-	return coll, nil
+	doc.ForeignMembers = decodeForeignMembers(geo, "type", "bbox", "crs", "features")
+
+	return &doc, nil
 }
 
 func decodeFeature(geo map[string]interface{}) (*Feature, error) {
@@ -46,10 +81,14 @@ func decodeFeature(geo map[string]interface{}) (*Feature, error) {
 
 	feature := Feature{}
 
-	if _, ok := geo["id"]; !ok || feature.ID == "" {
+	switch id := geo["id"].(type) {
+	case string, float64:
+		feature.sourceID = id
+		feature.ID = formatID(id)
+	}
+	if feature.ID == "" {
 		feature.ID = generateKey()
-	} else {
-		feature.ID = geo["id"].(string)
+		feature.generatedID = feature.ID
 	}
 
 	// DECODE PROPERTIES
@@ -57,80 +96,160 @@ func decodeFeature(geo map[string]interface{}) (*Feature, error) {
 		feature.Properties = geo["properties"].(map[string]interface{})
 	}
 
+	// RETAIN FOREIGN MEMBERS
+	feature.ForeignMembers = decodeForeignMembers(geo, "type", "id", "bbox", "crs", "properties", "geometry")
+
 	g, ok := geo["geometry"]
 	if !ok {
 		return nil, errors.New("Missing a geoJSON geometry property.")
 	}
 
+	var err error
+	feature.Type, feature.Geometry, err = decodeGeometry(g)
+	if err != nil {
+		return nil, err
+	}
+
+	// DECODE BOUNDING BOX
+	if b, ok := geo["bbox"]; ok && b != nil {
+		if feature.BBox, err = decodeBBox(b); err != nil {
+			return nil, errors.Wrap(err, "invalid feature bbox")
+		}
+	}
+
+	// DECODE LEGACY COORDINATE REFERENCE SYSTEM
+	if c, ok := geo["crs"]; ok && c != nil {
+		if feature.CRS, err = decodeCRS(c); err != nil {
+			return nil, errors.Wrap(err, "invalid feature crs")
+		}
+	}
+
+	return &feature, nil
+
+}
+
+// decodeGeometry decodes a GeoJSON geometry object, returning its type.
+func decodeGeometry(g interface{}) (string, *geos.Geometry, error) {
+
 	geometry, ok := g.(map[string]interface{})
 	if !ok {
-		return nil, errors.Newf("Geometry property is malformed: %s.", geo["geometry"])
+		return "", nil, errors.Newf("Geometry property is malformed: %s.", g)
 	}
 
 	geometryType, ok := geometry["type"]
 	if !ok {
-		return nil, errors.New("A Geometry Type property is required for decoding geoJSON.")
+		return "", nil, errors.New("A Geometry Type property is required for decoding geoJSON.")
 	}
 
-	feature.Type, ok = geometryType.(string)
+	typer, ok := geometryType.(string)
 	if !ok {
-		return nil, errors.New("The geoJSON Geometry Type property is expected to be a string.")
+		return "", nil, errors.New("The geoJSON Geometry Type property is expected to be a string.")
+	}
+
+	if typer == "GeometryCollection" {
+		collection, err := decodeGeometryCollection(geometry["geometries"])
+		return typer, collection, err
 	}
 
 	coords, ok := geometry["coordinates"]
 	if !ok {
-		return nil, errors.New("GeoJSON Geometry Coordinates property is required.")
+		return "", nil, errors.New("GeoJSON Geometry Coordinates property is required.")
 	}
 
 	coordinates, ok := coords.([]interface{})
 	if !ok {
-		return nil, errors.Newf("Geometry Coordinates property values are are malformed: %s.", geometry["coordinates"])
+		return "", nil, errors.Newf("Geometry Coordinates property values are are malformed: %s.", geometry["coordinates"])
 	}
 
-	var err error
+	var (
+		response *geos.Geometry
+		err      error
+	)
 	switch {
-	case feature.Type == "Point":
-		feature.Geometry, err = decodePoint(coordinates)
-	case feature.Type == "LineString":
-		feature.Geometry, err = decodeLineString(coordinates)
-	case feature.Type == "Polygon":
-		feature.Geometry, err = decodePolygon(coordinates)
-	case feature.Type == "MultiPolygon":
-		feature.Geometry, err = decodeMultiPolygon(coordinates)
+	case typer == "Point":
+		response, err = decodePoint(coordinates)
+	case typer == "MultiPoint":
+		response, err = decodeMultiPoint(coordinates)
+	case typer == "LineString":
+		response, err = decodeLineString(coordinates)
+	case typer == "MultiLineString":
+		response, err = decodeMultiLineString(coordinates)
+	case typer == "Polygon":
+		response, err = decodePolygon(coordinates)
+	case typer == "MultiPolygon":
+		response, err = decodeMultiPolygon(coordinates)
 	default:
-		return nil, errors.Newf("Unsupported type: %s.Currently, GeoJSON must be type Point, Linestring, Polygon, or Multipolygon.", feature.Type)
+		return "", nil, errors.Newf("Unsupported type: %s.Currently, GeoJSON must be type Point, MultiPoint, Linestring, MultiLineString, Polygon, Multipolygon or GeometryCollection.", typer)
 	}
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
-	return &feature, nil
-
+	return typer, response, nil
 }
 
-func decodePoint(coordinates []interface{}) (*geos.Geometry, error) {
+func decodeGeometryCollection(g interface{}) (*geos.Geometry, error) {
 
-	var (
-		latitude, longitude float64
-		err error
-	)
+	members, ok := g.([]interface{})
+	if !ok {
+		return nil, errors.Newf("GeometryCollection geometries property is malformed: %v.", g)
+	}
 
-	if lng, ok := coordinates[0].(string); !ok || lng == "" {
-		return nil, errors.New("first element of Point array should be a float64 longitude").SetState(M{logkeys.StringValue: coordinates[0]})
-	} else {
-		longitude, err = strconv.ParseFloat(lng, 64)
+	geometries := []*geos.Geometry{}
+	for i := range members {
+		_, geometry, err := decodeGeometry(members[i])
 		if err != nil {
-			return nil, errors.Wrap(err, "could not parse longitude").SetState(M{logkeys.StringValue: lng})
+			return nil, errors.Wrap(err, "could not decode collection geometry")
 		}
+		geometries = append(geometries, geometry)
+	}
+
+	response, err := geos.NewCollection(geos.GEOMETRYCOLLECTION, geometries...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create new collection")
 	}
 
-	if lat, ok := coordinates[1].(string); !ok || lat == "" {
-		return nil, errors.New("second element of Point array should be a float64 latitude").SetState(M{logkeys.StringValue: coordinates[1]})
-	} else {
-		latitude, err = strconv.ParseFloat(lat, 64)
+	return response, nil
+}
+
+func decodeMultiPoint(coordinates []interface{}) (*geos.Geometry, error) {
+
+	geometries := []*geos.Geometry{}
+
+	for _, coordinate := range coordinates {
+		position, ok := coordinate.([]interface{})
+		if !ok {
+			return nil, errors.New("coordinate position not an interface array")
+		}
+		g, err := decodePoint(position)
 		if err != nil {
-			return nil, errors.Wrap(err, "could not parse latitude").SetState(M{logkeys.StringValue: lat})
+			return nil, errors.Wrap(err, "could not decode point")
 		}
+		geometries = append(geometries, g)
+	}
+
+	response, err := geos.NewCollection(geos.MULTIPOINT, geometries...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create new collection")
+	}
+
+	return response, nil
+}
+
+func decodePoint(coordinates []interface{}) (*geos.Geometry, error) {
+
+	if len(coordinates) < 2 {
+		return nil, errors.Newf("A Point position requires at least two elements, found %d.", len(coordinates))
+	}
+
+	longitude, err := decodePointOrdinate(coordinates[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "first element of Point array should be a float64 longitude")
+	}
+
+	latitude, err := decodePointOrdinate(coordinates[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "second element of Point array should be a float64 latitude")
 	}
 
 	response, err := geos.NewPoint(geos.NewCoord(longitude, latitude))
@@ -142,6 +261,27 @@ func decodePoint(coordinates []interface{}) (*geos.Geometry, error) {
 
 }
 
+// decodePointOrdinate accepts a GeoJSON number, as well as the numeric strings
+// earlier versions of the store wrote for points.
+func decodePointOrdinate(value interface{}) (float64, error) {
+
+	if f, ok := value.(float64); ok {
+		return f, nil
+	}
+
+	str, ok := value.(string)
+	if !ok || str == "" {
+		return 0, errors.New("position element is not a number").SetState(M{logkeys.StringValue: value})
+	}
+
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not parse position element").SetState(M{logkeys.StringValue: str})
+	}
+
+	return f, nil
+}
+
 func decodeLineString(coordinates []interface{}) (*geos.Geometry, error) {
 
 	var coords []geos.Coord
@@ -159,15 +299,23 @@ func decodeLineString(coordinates []interface{}) (*geos.Geometry, error) {
 			return nil, errors.New("Expect each element in a LineString property array to be a coordinate array.")
 		}
 
-		if latitude, ok = points[1].(float64); !ok {
-			return nil, errors.Newf("First element of Point array should be a float64 %s.", points[0])
+		if len(points) < 2 {
+			return nil, errors.New("Expect each LineString position to have at least two elements.")
 		}
 
 		if longitude, ok = points[0].(float64); !ok {
+			return nil, errors.Newf("First element of Point array should be a float64 %s.", points[0])
+		}
+
+		if latitude, ok = points[1].(float64); !ok {
 			return nil, errors.Newf("Second element of Point array should be a float64 %s.", points[1])
 		}
 
-		coords = append(coords, geos.NewCoord(latitude, longitude))
+		coords = append(coords, geos.NewCoord(longitude, latitude))
+	}
+
+	if len(coords) < 2 {
+		return nil, errors.Newf("A LineString requires at least two positions, found %d.", len(coords))
 	}
 
 	response, err := geos.NewLineString(coords...)
@@ -179,6 +327,30 @@ func decodeLineString(coordinates []interface{}) (*geos.Geometry, error) {
 
 }
 
+func decodeMultiLineString(coordinates []interface{}) (*geos.Geometry, error) {
+
+	geometries := []*geos.Geometry{}
+
+	for _, coordinate := range coordinates {
+		linestring, ok := coordinate.([]interface{})
+		if !ok {
+			return nil, errors.New("coordinate linestring not an interface array")
+		}
+		g, err := decodeLineString(linestring)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decode linestring")
+		}
+		geometries = append(geometries, g)
+	}
+
+	response, err := geos.NewCollection(geos.MULTILINESTRING, geometries...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create new collection")
+	}
+
+	return response, nil
+}
+
 func decodePolygon(coordinates []interface{}) (*geos.Geometry, error) {
 
 	var contours [][]geos.Coord
@@ -199,6 +371,10 @@ func decodePolygon(coordinates []interface{}) (*geos.Geometry, error) {
 				return nil, errors.New("Expect each sub-element in a Polygon Linestring sub-array to be a coordinates array.")
 			}
 
+			if len(points) < 2 {
+				return nil, errors.New("Expect each Polygon position to have at least two elements.")
+			}
+
 			latitude, ok := points[1].(float64)
 			if !ok {
 				return nil, errors.Newf("First element of Point array should be a float64 %s.", points[0])
@@ -211,10 +387,22 @@ func decodePolygon(coordinates []interface{}) (*geos.Geometry, error) {
 			coords = append(coords, geos.NewCoord(longitude, latitude))
 		}
 
+		if len(coords) < 4 {
+			return nil, errors.Newf("A Polygon ring requires at least four positions, found %d.", len(coords))
+		}
+
+		if first, last := coords[0], coords[len(coords)-1]; first.X != last.X || first.Y != last.Y {
+			return nil, errors.Newf("A Polygon ring should be closed, but starts at [%f, %f] and ends at [%f, %f].", first.X, first.Y, last.X, last.Y)
+		}
+
 		contours = append(contours, coords)
 
 	}
 
+	if len(contours) == 0 {
+		return nil, errors.New("A Polygon requires at least one ring.")
+	}
+
 	response, err := geos.NewPolygon(contours[0], contours[1:]...)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create new polygon")
@@ -248,6 +436,42 @@ func decodeMultiPolygon(coordinates []interface{}) (*geos.Geometry, error) {
 	return response, nil
 }
 
+// decodeForeignMembers collects every member of a GeoJSON object that is not
+// one of the given reserved names.
+func decodeForeignMembers(geo map[string]interface{}, reserved ...string) map[string]interface{} {
+
+	var members map[string]interface{}
+
+	for key, value := range geo {
+		if isReservedMember(key, reserved) {
+			continue
+		}
+		if members == nil {
+			members = make(map[string]interface{})
+		}
+		members[key] = value
+	}
+
+	return members
+}
+
+func isReservedMember(key string, reserved []string) bool {
+	for i := range reserved {
+		if reserved[i] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// formatID returns a decoded id member, a string or a number, as a string.
+func formatID(id interface{}) string {
+	if number, ok := id.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return id.(string)
+}
+
 func generateKey() string {
 	const alphanum = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 	var bytes = make([]byte, 15)
@@ -1,22 +1,130 @@
 package terra
 
 import (
+	"bytes"
 	"encoding/json"
+	"sort"
+
 	"github.com/paulsmith/gogeos/geos"
 	"github.com/saleswise/errors/errors"
 )
 
 type geoJSONEncodeType struct {
-	ID          string                 `json:"id"`
-	Type        string                 `json:"type"`
-	Properties  map[string]interface{} `json:"properties"`
-	Geometry    struct {
-		Type        string        `json:"type"`
-		Coordinates []interface{} `json:"coordinates"`
-	} `json:"geometry"`
+	ID          interface{}                `json:"id,omitempty"`
+	Type        string                     `json:"type"`
+	BBox        []float64                  `json:"bbox,omitempty"`
+	CRS         *geoJSONCRSEncodeType      `json:"crs,omitempty"`
+	Properties  map[string]interface{}     `json:"properties"`
+	Geometry    *geoJSONGeometryEncodeType `json:"geometry"`
+}
+
+type geoJSONGeometryEncodeType struct {
+	Type        string                       `json:"type"`
+	Coordinates []interface{}                `json:"coordinates,omitempty"`
+	Geometries  []*geoJSONGeometryEncodeType `json:"geometries,omitempty"`
+}
+
+type geoJSONCollectionEncodeType struct {
+	Type     string                `json:"type"`
+	BBox     []float64             `json:"bbox,omitempty"`
+	CRS      *geoJSONCRSEncodeType `json:"crs,omitempty"`
+	Features []json.RawMessage     `json:"features"`
+}
+
+// EncodeOption adjusts how features and collections are written as GeoJSON.
+type EncodeOption func(*encoder)
+
+type encoder struct {
+	bbox         bool
+	stripForeign bool
+	rewind       bool
+	normalize    bool
+	wgs84        bool
+	cut          bool
+}
+
+func newEncoder(opts []EncodeOption) *encoder {
+	enc := &encoder{}
+	for _, opt := range opts {
+		opt(enc)
+	}
+	return enc
+}
+
+// WithBBox writes a bbox member on every feature and collection, computing
+// it from the geometry when none was declared.
+func WithBBox() EncodeOption {
+	return func(enc *encoder) {
+		enc.bbox = true
+	}
+}
+
+// WithoutForeignMembers drops any foreign members retained from decoding.
+func WithoutForeignMembers() EncodeOption {
+	return func(enc *encoder) {
+		enc.stripForeign = true
+	}
 }
 
-func (feat *Feature) ToJSON() ([]byte, error) {
+// WithRewind writes polygon rings following the RFC 7946 right-hand rule:
+// exterior rings counterclockwise and holes clockwise.
+func WithRewind() EncodeOption {
+	return func(enc *encoder) {
+		enc.rewind = true
+	}
+}
+
+// WithNormalizedCoordinates wraps longitudes into [-180, 180] and clamps
+// latitudes to [-90, 90].
+func WithNormalizedCoordinates() EncodeOption {
+	return func(enc *encoder) {
+		enc.normalize = true
+	}
+}
+
+// WithWGS84 reprojects features in any other coordinate reference system to
+// WGS84 longitude and latitude before writing them.
+func WithWGS84() EncodeOption {
+	return func(enc *encoder) {
+		enc.wgs84 = true
+	}
+}
+
+// WithRFC7946 writes output that strict RFC 7946 validators accept. Features
+// crossing the antimeridian, or written beyond it, are first cut there as
+// section 3.1.9 recommends.
+func WithRFC7946() EncodeOption {
+	return func(enc *encoder) {
+		enc.cut = true
+		enc.rewind = true
+		enc.normalize = true
+		enc.wgs84 = true
+	}
+}
+
+// ToJSON encodes the feature as GeoJSON. A declared BBox and any foreign
+// members are always written unless stripped, as is a crs member for any
+// system other than WGS84.
+func (feat *Feature) ToJSON(opts ...EncodeOption) ([]byte, error) {
+	return newEncoder(opts).feature(feat, nil)
+}
+
+// encodeID returns the id member to write: the one decoded, keeping its type,
+// and none for a feature decoded without one, unless ID has been changed since.
+func (feat *Feature) encodeID() interface{} {
+	switch {
+	case feat.generatedID != "" && feat.ID == feat.generatedID:
+		return feat.sourceID
+	case feat.sourceID != nil && formatID(feat.sourceID) == feat.ID:
+		return feat.sourceID
+	}
+	return feat.ID
+}
+
+// feature encodes the feature within a collection in the given system, or on
+// its own when that is nil. A crs member is written where the feature declares
+// one, or where the system it inherited is not the collection's.
+func (enc *encoder) feature(feat *Feature, collection *CRS) ([]byte, error) {
 
 	empty, err := feat.IsEmpty()
 	if err != nil {
@@ -26,30 +134,40 @@ func (feat *Feature) ToJSON() ([]byte, error) {
 		return nil, errors.New("The feature is empty, with nothing to encode into GeoJSON.")
 	}
 
+	if enc.wgs84 && !isWGS84(feat.crs()) {
+		if feat, err = feat.Reproject(WGS84); err != nil {
+			return nil, err
+		}
+	}
+
+	if enc.cut {
+		bbox := feat.BBox
+		if feat, err = feat.CutAntimeridian(); err != nil {
+			return nil, err
+		}
+		feat.BBox = bbox
+	}
+
+	crs := feat.CRS
+	if crs == nil && !sameCRS(feat.inheritedCRS, collection) {
+		crs = feat.inheritedCRS
+	}
+
 	var construct = &geoJSONEncodeType{
-		ID:          feat.ID,
+		ID:          feat.encodeID(),
 		Type:        "Feature",
 		Properties:  feat.Properties,
+		BBox:        feat.BBox,
+		CRS:         encodeCRS(crs),
 	}
-	construct.Geometry.Type = feat.Type
 
-	switch {
-	case feat.Type == "Point":
-		coords, err := feat.Geometry.Coords()
-		if err != nil {
-			return nil, errors.Wrap(err, "could not get geometry coords")
+	if enc.bbox && len(construct.BBox) == 0 {
+		if construct.BBox, err = feat.BoundingBox(); err != nil {
+			return nil, err
 		}
-		construct.Geometry.Coordinates = encodeCoord(coords[0])
-	case feat.Type == "LineString":
-		construct.Geometry.Coordinates, err = encodeLineString(feat.Geometry)
-	case feat.Type == "Polygon":
-		construct.Geometry.Coordinates, err = encodePolygon(feat.Geometry)
-	case feat.Type == "MultiPolygon":
-		construct.Geometry.Coordinates, err = encodeMultiPolygon(feat.Geometry)
-	default:
-		return nil, errors.Newf("Currently GeoJSON must be type Point, Linestring, Polygon, or Multipolygon. Found %s.", feat.Type)
 	}
-	if err != nil {
+
+	if construct.Geometry, err = enc.encodeGeometry(feat.Type, feat.Geometry); err != nil {
 		return nil, err
 	}
 
@@ -58,57 +176,236 @@ func (feat *Feature) ToJSON() ([]byte, error) {
 		return nil, errors.Wrap(err, "could not marshal geojson")
 	}
 
+	if !enc.stripForeign {
+		return appendForeignMembers(geojson, feat.ForeignMembers)
+	}
+
 	return geojson, nil
 
 }
 
-func encodeCoord(coord geos.Coord) []interface{} {
-	return []interface{}{coord.X, coord.Y}
+// ToJSON encodes the collection as a GeoJSON FeatureCollection.
+func (coll FeatureCollection) ToJSON(opts ...EncodeOption) ([]byte, error) {
+	return (&FeatureCollectionDocument{Features: coll}).ToJSON(opts...)
 }
 
-func encodeLineString(geometry *geos.Geometry) ([]interface{}, error) {
+// ToJSON encodes the document as a GeoJSON FeatureCollection, writing its
+// declared bbox, crs and foreign members.
+func (doc *FeatureCollectionDocument) ToJSON(opts ...EncodeOption) ([]byte, error) {
 
-	coords, err := geometry.Coords()
+	enc := newEncoder(opts)
+
+	var (
+		coll = doc.Features
+		crs  = doc.CRS
+	)
+	if enc.wgs84 && !(isWGS84(crs) && coll.isWGS84()) {
+		var err error
+		if coll, err = coll.Reproject(WGS84); err != nil {
+			return nil, err
+		}
+		crs = nil
+	}
+
+	var construct = &geoJSONCollectionEncodeType{
+		Type:     "FeatureCollection",
+		BBox:     doc.BBox,
+		Features: []json.RawMessage{},
+	}
+
+	construct.CRS = encodeCRS(crs)
+
+	if enc.bbox && len(construct.BBox) == 0 && len(coll) > 0 {
+		var err error
+		if construct.BBox, err = coll.BoundingBox(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range coll {
+		feature, err := enc.feature(coll[i], crs)
+		if err != nil {
+			return nil, err
+		}
+		construct.Features = append(construct.Features, feature)
+	}
+
+	geojson, err := json.Marshal(construct)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal geojson feature collection")
+	}
+
+	if !enc.stripForeign {
+		return appendForeignMembers(geojson, doc.ForeignMembers)
+	}
+
+	return geojson, nil
+}
+
+// appendForeignMembers writes the members, in key order, after those already
+// in the encoded object.
+func appendForeignMembers(object []byte, members map[string]interface{}) ([]byte, error) {
+
+	if len(members) == 0 {
+		return object, nil
+	}
+
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer(object[:len(object)-1])
+	for _, key := range keys {
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not marshal foreign member name %s", key)
+		}
+		v, err := json.Marshal(members[key])
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not marshal foreign member %s", key)
+		}
+		buf.WriteByte(',')
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func (enc *encoder) encodeGeometry(typer string, geometry *geos.Geometry) (*geoJSONGeometryEncodeType, error) {
+
+	var (
+		construct = &geoJSONGeometryEncodeType{Type: typer}
+		err       error
+	)
+
+	switch {
+	case typer == "Point":
+		coords, err := geometry.Coords()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get geometry coords")
+		}
+		construct.Coordinates = enc.encodeCoord(coords[0])
+	case typer == "MultiPoint":
+		coords, err := geometryCoords(geometry)
+		if err != nil {
+			return nil, err
+		}
+		construct.Coordinates = enc.encodeCoords(coords)
+	case typer == "LineString":
+		construct.Coordinates, err = enc.encodeLineString(geometry)
+	case typer == "MultiLineString":
+		construct.Coordinates, err = enc.encodeMultiLineString(geometry)
+	case typer == "Polygon":
+		construct.Coordinates, err = enc.encodePolygon(geometry)
+	case typer == "MultiPolygon":
+		construct.Coordinates, err = enc.encodeMultiPolygon(geometry)
+	case typer == "GeometryCollection":
+		construct.Geometries, err = enc.encodeGeometryCollection(geometry)
+	default:
+		return nil, errors.Newf("Currently GeoJSON must be type Point, MultiPoint, Linestring, MultiLineString, Polygon, Multipolygon or GeometryCollection. Found %s.", typer)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var res []interface{}
-	for i := range coords {
-		res = append(res, encodeCoord(coords[i]))
+	return construct, nil
+}
+
+func (enc *encoder) encodeGeometryCollection(collection *geos.Geometry) ([]*geoJSONGeometryEncodeType, error) {
+
+	parts, err := geometryParts(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*geoJSONGeometryEncodeType{}
+	for _, part := range parts {
+		typer, err := geometryTypeName(part)
+		if err != nil {
+			return nil, err
+		}
+		compiled, err := enc.encodeGeometry(typer, part)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, compiled)
 	}
 
 	return res, nil
 }
 
-func encodePolygon(geometry *geos.Geometry) ([]interface{}, error) {
+func (enc *encoder) encodeCoord(coord geos.Coord) []interface{} {
+	if enc.normalize {
+		coord = normalizeCoord(coord)
+	}
+	return []interface{}{coord.X, coord.Y}
+}
 
-	var geometries []*geos.Geometry
-	shell, err := geometry.Shell()
-	if err != nil {
-		return nil, errors.Wrap(err, "could not get shell")
+func (enc *encoder) encodeCoords(coords []geos.Coord) []interface{} {
+	var res []interface{}
+	for i := range coords {
+		res = append(res, enc.encodeCoord(coords[i]))
 	}
-	geometries = append(geometries, shell)
+	return res
+}
+
+func (enc *encoder) encodeLineString(geometry *geos.Geometry) ([]interface{}, error) {
 
-	holes, err := geometry.Holes()
+	coords, err := geometry.Coords()
 	if err != nil {
-		return nil, errors.Wrap(err, "could not get geometry holes")
+		return nil, err
 	}
-	geometries = append(geometries, holes...)
 
+	return enc.encodeCoords(coords), nil
+}
+
+func (enc *encoder) encodeMultiLineString(multilinestring *geos.Geometry) ([]interface{}, error) {
+
+	collectionLength, err := multilinestring.NGeometry()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get geometry count")
+	}
 	res := []interface{}{}
-	for i := range geometries {
-		coordProgression, err := encodeLineString(geometries[i])
+	for i := 0; i < collectionLength; i++ {
+		g, err := multilinestring.Geometry(i)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get linestring geometry")
+		}
+		compiled, err := enc.encodeLineString(g)
 		if err != nil {
 			return nil, err
 		}
-		res = append(res, coordProgression)
+		res = append(res, compiled)
+	}
+
+	return res, nil
+}
+
+func (enc *encoder) encodePolygon(geometry *geos.Geometry) ([]interface{}, error) {
+
+	rings, err := polygonRings(geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []interface{}{}
+	for i, ring := range rings {
+		// Exterior rings wind counterclockwise and holes clockwise.
+		if enc.rewind && (i == 0) != (ringArea(ring) > 0) {
+			ring = reverseCoords(ring)
+		}
+		res = append(res, enc.encodeCoords(ring))
 	}
 
 	return res, nil
 }
 
-func encodeMultiPolygon(multipolygon *geos.Geometry) ([]interface{}, error) {
+func (enc *encoder) encodeMultiPolygon(multipolygon *geos.Geometry) ([]interface{}, error) {
 
 	collectionLength, err := multipolygon.NGeometry()
 	if err != nil {
@@ -120,7 +417,7 @@ func encodeMultiPolygon(multipolygon *geos.Geometry) ([]interface{}, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "could not get polygon geometry")
 		}
-		compiled, err := encodePolygon(g)
+		compiled, err := enc.encodePolygon(g)
 		if err != nil {
 			return nil, err
 		}
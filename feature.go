@@ -13,10 +13,44 @@ type Feature struct {
 	Type        string
 	Properties  map[string]interface{}
 	Geometry    *geos.Geometry
+	// BBox is the GeoJSON bounding box declared for the feature, as
+	// [west, south, east, north]. It is nil unless decoded or set.
+	BBox        []float64
+	// ForeignMembers holds any members of the GeoJSON object beyond those the
+	// specification defines, such as "title" or vendor extensions.
+	ForeignMembers map[string]interface{}
+	// CRS is the coordinate reference system declared on the feature. Without
+	// one, the feature is in that of its collection, or else WGS84.
+	CRS         *CRS
+	// inheritedCRS is the system declared by the collection the feature was
+	// decoded from, which is never written back on the feature itself.
+	inheritedCRS *CRS
+	// sourceID is the id member as decoded, a string or a number, and
+	// generatedID the key made up for a feature decoded without one. Neither
+	// is written once ID has been changed.
+	sourceID    interface{}
+	generatedID string
+	bbox        []float64
+	bounds      *rtreego.Rect
+	rects       []*rtreego.Rect
 }
 
 type FeatureCollection []*Feature
 
+// FeatureCollectionDocument is a GeoJSON FeatureCollection together with the
+// members the collection object itself may carry.
+type FeatureCollectionDocument struct {
+	Features FeatureCollection
+	// BBox is the bounding box declared for the collection, if any.
+	BBox []float64
+	// ForeignMembers holds any non-standard members of the collection, such as
+	// a vendor extension.
+	ForeignMembers map[string]interface{}
+	// CRS is the coordinate reference system declared for the collection, which
+	// its features share unless they declare their own.
+	CRS *CRS
+}
+
 // FIXME: READ ABOUT WKB AND WKT
 
 func NewFeature() (feature *Feature) {
@@ -73,120 +107,112 @@ func NewPolygon(polygons [][][]float64) (*Feature, error) {
 	return feat, nil
 }
 
-func (feat Feature) Bounds() *rtreego.Rect {
+// Bounds returns a rectangle covering the feature in WGS84, derived from the
+// bounding box once and cached. For a feature crossing the antimeridian the
+// rectangle extends east beyond 180 degrees; use Rects to search or index it.
+func (feat *Feature) Bounds() *rtreego.Rect {
 
-	typer, err := feat.Geometry.Type()
-	if err != nil {
-		// return nil, errors.Wrap(err, "could not get type")
-		return nil
-	}
-	if typer == geos.POINT {
-		x, err := feat.Geometry.X()
-		if err != nil {
-			// return nil, errors.Wrap(err, "could not get geometry x")
-			return nil
-		}
-		y, err := feat.Geometry.Y()
-		if err != nil {
-			// return nil, errors.Wrap(err, "could not get geometry y")
-			return nil
-		}
-		rect, err := rtreego.NewRect(rtreego.Point{x, y}, []float64{0.00001, 0.00001})
-		if err != nil {
-			// return nil, errors.Wrap(err, "could not get new rectangle")
-			return nil
-		}
-		return rect
-		// return rect, nil
+	if feat.bounds != nil {
+		return feat.bounds
 	}
 
-	envelope, err := feat.Geometry.Envelope()
+	bbox, err := feat.wgs84BoundingBox()
 	if err != nil {
-		// return nil, errors.Wrap(err, "could not get envelope")
 		return nil
 	}
-	envelope, err = envelope.Shell()
+
+	rect, err := bboxToRect(bbox)
 	if err != nil {
-		// return nil, errors.Wrap(err, "could not get shell")
 		return nil
 	}
-	coords, er := envelope.Coords()
-	if er != nil {
-		// return nil, errors.Wrap(err, "could not get coords")
-		return nil
+
+	feat.bounds = rect
+
+	return rect
+}
+
+// Rects returns the rectangles covering the feature within the WGS84 longitude
+// domain: one, or two split at the antimeridian when the feature crosses it.
+func (feat *Feature) Rects() ([]*rtreego.Rect, error) {
+
+	if feat.rects != nil {
+		return feat.rects, nil
 	}
-	if len(coords) != 5 {
-		// return nil, errors.New("coords not equal to five")
-		return nil
+
+	bbox, err := feat.wgs84BoundingBox()
+	if err != nil {
+		return nil, err
 	}
 
-	// FIXME: GEOJSON somehow reverses these coordinates.
-	height := coords[1].X - coords[0].X
-	width := coords[3].Y - coords[0].Y
-	rect, err := rtreego.NewRect(rtreego.Point{coords[0].X, coords[0].Y}, []float64{height, width})
+	rects, err := bboxToRects(bbox)
 	if err != nil {
-		// return nil, errors.Wrap(err, "could not get rectangle")
-		return nil
+		return nil, err
 	}
 
-	return rect
+	feat.rects = rects
+
+	return rects, nil
 }
 
-func (feat *Feature) SetGeometry(typer string, geometry *geos.Geometry) error {
+// BoundingBox computes the [west, south, east, north] extent of the geometry
+// in its own coordinate reference system, caching the result. A declared BBox
+// is not trusted here, so a wrong one never misplaces the feature in an index;
+// Validate checks it in strict mode.
+func (feat *Feature) BoundingBox() ([]float64, error) {
 
-	if typer != "Polygon" && typer != "Point" && typer != "MultiPolygon" {
-		return errors.New("Presently, geostore only accepts GeoJSON types Point, LineString, Polygon and Multipolygon.")
+	if feat.bbox != nil {
+		return feat.bbox, nil
 	}
 
-	feat.Type = typer
-
-	if geometry == nil {
-		return nil
+	if feat.Geometry == nil {
+		return nil, errors.New("The feature has no geometry from which to compute a bounding box.")
 	}
 
-	feat.Geometry = geometry
+	bbox, err := geometryBBox(feat.Geometry)
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
+	feat.bbox = bbox
 
+	return bbox, nil
 }
 
-func (feat *Feature) Property(name string) (property interface{}) {
+// wgs84BoundingBox returns the bounding box of the feature in WGS84.
+func (feat *Feature) wgs84BoundingBox() ([]float64, error) {
 
-	//FIXME: Properly handle subarrays or subobjects.
+	feat, err := feat.wgs84()
+	if err != nil {
+		return nil, err
+	}
 
-	property, ok := feat.Properties[name]
-	if !ok {
-		return
+	return feat.BoundingBox()
+}
+
+func (feat *Feature) SetGeometry(typer string, geometry *geos.Geometry) error {
+
+	switch typer {
+	case "Point", "MultiPoint", "LineString", "MultiLineString", "Polygon", "MultiPolygon", "GeometryCollection":
+	default:
+		return errors.New("Presently, geostore only accepts GeoJSON types Point, MultiPoint, LineString, MultiLineString, Polygon, Multipolygon and GeometryCollection.")
 	}
 
-	return
+	feat.Type = typer
 
-	/*
-		if floatValue, ok := feat.Properties[name].(float64); ok {
-			property = strconv.FormatFloat(floatValue, 'f', 6, 64)
-			return
-		}
+	if geometry == nil {
+		return nil
+	}
 
-		if boolValue, ok := feat.Properties[name].(bool); ok {
-			// Fixme: More Tersley Convert Boolean to string
-			property = strconv.FormatBool(boolValue)
-			return
-		}
+	feat.Geometry = geometry
 
-		if property, ok = feat.Properties[name].(string); ok {
-			// Fixme: More Tersley Convert Boolean to string
-			return
-		}
-	*/
+	// The geometry has changed, so previous bounds no longer apply.
+	feat.BBox = nil
+	feat.bbox = nil
+	feat.bounds = nil
+	feat.rects = nil
 
-	return
-}
+	return nil
 
-func (feat *Feature) SetProperty(name string, property interface{}) {
-	if feat.Properties == nil {
-		feat.Properties = make(map[string]interface{})
-	}
-	feat.Properties[name] = property
 }
 
 func (feat *Feature) Contains(subfeat *Feature) (bool, error) {
@@ -248,3 +274,23 @@ func (feat *Feature) IsEmpty() (bool, error) {
 
 	return false, nil
 }
+
+// BoundingBox returns the [west, south, east, north] extent of every feature
+// in the collection, computed from their geometries.
+func (coll FeatureCollection) BoundingBox() ([]float64, error) {
+
+	var bbox []float64
+	for i := range coll {
+		b, err := coll[i].BoundingBox()
+		if err != nil {
+			return nil, err
+		}
+		bbox = mergeBBox(bbox, b)
+	}
+
+	if bbox == nil {
+		return nil, errors.New("An empty feature collection has no bounding box.")
+	}
+
+	return bbox, nil
+}
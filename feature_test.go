@@ -1,10 +1,22 @@
 package terra
 
 import (
-	. "github.com/smartystreets/goconvey/convey"
+	"math"
 	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
 )
 
+// rectangle returns a polygon feature of the [west, south, east, north] box.
+func rectangle(id string, west, south, east, north float64) *Feature {
+	polygon, err := NewPolygon([][][]float64{{
+		{west, south}, {east, south}, {east, north}, {west, north}, {west, south},
+	}})
+	So(err, ShouldBeNil)
+	polygon.ID = id
+	return polygon
+}
+
 func TestFeatureCollection(t *testing.T) {
 
 	t.Parallel()
@@ -102,3 +114,205 @@ func TestFeatureCollection(t *testing.T) {
 	})
 }
 
+
+func TestBBox(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should read, validate and write bounding boxes", t, func() {
+
+		feat, err := NewFeatureFromJSON([]byte(`{
+			"type": "Feature",
+			"bbox": [-70.1, 12.4, -69.8, 12.7],
+			"properties": {"name": "Aruba"},
+			"geometry": { "type": "Polygon", "coordinates": [ [ [ -69.899121093749997, 12.452001953124991 ], [ -69.895703125, 12.422998046874994 ], [ -70.066113281249997, 12.546972656249991 ], [ -70.035107421874997, 12.614111328124991 ], [ -69.899121093749997, 12.452001953124991 ] ] ] }
+		}`))
+		So(err, ShouldBeNil)
+		So(feat.BBox, ShouldResemble, []float64{-70.1, 12.4, -69.8, 12.7})
+
+		rect := feat.Bounds()
+		So(rect, ShouldNotBeNil)
+		So(rect.PointCoord(0), ShouldEqual, -70.066113281249997)
+		So(rect.PointCoord(1), ShouldEqual, 12.422998046874994)
+
+		// A declared bbox that misses the geometry is reported, never indexed.
+		wrong, err := NewFeatureFromJSON([]byte(`{
+			"type": "Feature",
+			"bbox": [10, 10, 11, 11],
+			"geometry": { "type": "Point", "coordinates": [ -69.9, 12.5 ] }
+		}`))
+		So(err, ShouldBeNil)
+		bbox, err := wrong.BoundingBox()
+		So(err, ShouldBeNil)
+		So(bbox, ShouldResemble, []float64{-69.9, 12.5, -69.9, 12.5})
+		So(wrong.Validate(false), ShouldBeNil)
+		So(wrong.Validate(true), ShouldNotBeNil)
+
+		_, err = NewFeatureFromJSON([]byte(`{
+			"type": "Feature",
+			"bbox": [-69.8, 12.4, -70.1],
+			"geometry": { "type": "Polygon", "coordinates": [ [ [ -69.899121093749997, 12.452001953124991 ], [ -69.895703125, 12.422998046874994 ], [ -70.066113281249997, 12.546972656249991 ], [ -69.899121093749997, 12.452001953124991 ] ] ] }
+		}`))
+		So(err, ShouldNotBeNil)
+
+		polygon, err := NewPolygon([][][]float64{
+			[][]float64{
+				{-139.75, 55.03},
+				{-51.28, 55.03},
+				{-51.28, 23.73},
+				{-139.75, 23.73},
+				{-139.75, 55.03},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		bbox, err = polygon.BoundingBox()
+		So(err, ShouldBeNil)
+		So(bbox, ShouldResemble, []float64{-139.75, 23.73, -51.28, 55.03})
+
+		encoded, err := FeatureCollection{polygon}.ToJSON(WithBBox())
+		So(err, ShouldBeNil)
+
+		decoded, err := NewFeatureCollectionDocumentFromJSON(encoded)
+		So(err, ShouldBeNil)
+		So(len(decoded.Features), ShouldEqual, 1)
+		So(decoded.Features[0].BBox, ShouldResemble, bbox)
+		So(decoded.BBox, ShouldResemble, bbox)
+
+	})
+}
+
+func TestForeignMembers(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should retain foreign members through a round trip", t, func() {
+
+		coll, err := NewFeatureCollectionDocumentFromJSON([]byte(`{
+			"type": "FeatureCollection",
+			"name": "Caribbean",
+			"features": [
+				{
+					"type": "Feature",
+					"id": "AIA",
+					"title": "Anguilla",
+					"links": [ { "href": "http://www.naturalearthdata.com", "rel": "via" } ],
+					"properties": { "name": "Anguilla" },
+					"geometry": { "type": "Polygon", "coordinates": [ [ [ -63.001220703125, 18.221777343749991 ], [ -63.160009765624991, 18.17138671875 ], [ -63.1533203125, 18.200292968749991 ], [ -63.026025390624994, 18.269726562499997 ], [ -62.979589843749991, 18.264794921874994 ], [ -63.001220703125, 18.221777343749991 ] ] ] }
+				}
+			]
+		}`))
+		So(err, ShouldBeNil)
+		So(coll.ForeignMembers, ShouldContainKey, "name")
+
+		feat := coll.Features[0]
+		So(feat.ID, ShouldEqual, "AIA")
+		So(feat.ForeignMembers["title"], ShouldEqual, "Anguilla")
+		So(feat.ForeignMembers, ShouldContainKey, "links")
+
+		encoded, err := coll.ToJSON()
+		So(err, ShouldBeNil)
+
+		decoded, err := NewFeatureCollectionDocumentFromJSON(encoded)
+		So(err, ShouldBeNil)
+		So(decoded.ForeignMembers, ShouldResemble, coll.ForeignMembers)
+		So(decoded.Features[0].ForeignMembers, ShouldResemble, feat.ForeignMembers)
+
+		stripped, err := feat.ToJSON(WithoutForeignMembers())
+		So(err, ShouldBeNil)
+
+		plain, err := NewFeatureFromJSON(stripped)
+		So(err, ShouldBeNil)
+		So(plain.ForeignMembers, ShouldBeNil)
+		So(plain.ID, ShouldEqual, "AIA")
+
+	})
+
+	Convey("should write ids back as they were decoded", t, func() {
+
+		numbered, err := NewFeatureFromJSON([]byte(`{"type": "Feature", "id": 5, "properties": null, "geometry": {"type": "Point", "coordinates": [-63.05, 18.22]}}`))
+		So(err, ShouldBeNil)
+		So(numbered.ID, ShouldEqual, "5")
+		encoded, err := numbered.ToJSON()
+		So(err, ShouldBeNil)
+		So(string(encoded), ShouldContainSubstring, `"id":5,`)
+
+		anonymous, err := NewFeatureFromJSON([]byte(`{"type": "Feature", "properties": null, "geometry": {"type": "Point", "coordinates": [-63.05, 18.22]}}`))
+		So(err, ShouldBeNil)
+		So(anonymous.ID, ShouldNotBeEmpty)
+		encoded, err = anonymous.ToJSON()
+		So(err, ShouldBeNil)
+		So(string(encoded), ShouldNotContainSubstring, `"id"`)
+
+		// An ID set since is written as given.
+		anonymous.ID = "anguilla"
+		encoded, err = anonymous.ToJSON()
+		So(err, ShouldBeNil)
+		So(string(encoded), ShouldContainSubstring, `"id":"anguilla"`)
+	})
+}
+
+func TestOperations(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("given two overlapping squares", t, func() {
+
+		west, err := NewPolygon([][][]float64{{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}})
+		So(err, ShouldBeNil)
+		west.SetProperty("name", "west")
+		west.SetProperty("side", "left")
+
+		east, err := NewPolygon([][][]float64{{{1, 0}, {3, 0}, {3, 2}, {1, 2}, {1, 0}}})
+		So(err, ShouldBeNil)
+		east.SetProperty("name", "east")
+		east.SetProperty("color", "red")
+
+		Convey("should keep the receiver properties by default", func() {
+			union, err := west.Union(east)
+			So(err, ShouldBeNil)
+			So(union.Type, ShouldEqual, "Polygon")
+			So(union.ID, ShouldNotEqual, west.ID)
+			So(union.Properties, ShouldResemble, west.Properties)
+		})
+
+		Convey("should merge properties when asked", func() {
+			intersection, err := west.Intersection(east, MergeProperties)
+			So(err, ShouldBeNil)
+			So(intersection.Property("name"), ShouldEqual, "west")
+			So(intersection.Property("color"), ShouldEqual, "red")
+		})
+
+		Convey("should type a difference of disjoint parts", func() {
+			wide, err := NewPolygon([][][]float64{{{-1, 0.5}, {4, 0.5}, {4, 1.5}, {-1, 1.5}, {-1, 0.5}}})
+			So(err, ShouldBeNil)
+			union, err := west.Union(east)
+			So(err, ShouldBeNil)
+			difference, err := wide.Difference(union)
+			So(err, ShouldBeNil)
+			So(difference.Type, ShouldEqual, "MultiPolygon")
+		})
+
+		Convey("should find the centroid as a point", func() {
+			centroid, err := west.Centroid(DropProperties)
+			So(err, ShouldBeNil)
+			So(centroid.Type, ShouldEqual, "Point")
+			So(centroid.Properties, ShouldBeNil)
+			x, y, err := centroid.PointCoords()
+			So(err, ShouldBeNil)
+			So(x, ShouldEqual, 1)
+			So(y, ShouldEqual, 1)
+		})
+
+		Convey("should buffer a point by meters into a geodesic circle", func() {
+			point, err := NewPoint(60, 179.999)
+			So(err, ShouldBeNil)
+			buffer, err := point.GeodesicBuffer(1000)
+			So(err, ShouldBeNil)
+			So(buffer.Type, ShouldEqual, "MultiPolygon")
+			area, err := buffer.Area()
+			So(err, ShouldBeNil)
+			So(area, ShouldAlmostEqual, math.Pi*1000*1000, 5000)
+		})
+	})
+}
@@ -0,0 +1,123 @@
+package terra
+
+import (
+	"sort"
+)
+
+// ReverseGeocoder finds the features of a store containing a point, such as
+// the country, state, county and park it lies in.
+type ReverseGeocoder struct {
+	store    *Geostore
+	level    string
+	order    []interface{}
+	fallback float64
+}
+
+// Placement is a feature a point was placed in.
+type Placement struct {
+	Feature *Feature
+	// Contained is false for a feature found by the nearest feature fallback,
+	// which is Distance meters from the point.
+	Contained bool
+	Distance  float64
+}
+
+// NewReverseGeocoder creates a geocoder for the store, ordering the features
+// it finds by the level property, lowest first. Features lacking the property
+// come last.
+func NewReverseGeocoder(store *Geostore, level string) *ReverseGeocoder {
+	return &ReverseGeocoder{store: store, level: level}
+}
+
+// SetLevelOrder orders features by the position of their level in the values,
+// such as "country", "state", "county" and "park", rather than by the level
+// itself. Features with a level not listed come after those with one.
+func (r *ReverseGeocoder) SetLevelOrder(values ...interface{}) {
+	r.order = values
+}
+
+// SetFallbackDistance has Lookup add, for each level no feature containing the
+// point has, the nearest feature of that level within the meters. This places
+// points just offshore or on a border. Zero, the default, disables it.
+func (r *ReverseGeocoder) SetFallbackDistance(meters float64) {
+	r.fallback = meters
+}
+
+// Lookup returns the placements of the point, ordered by level.
+func (r *ReverseGeocoder) Lookup(latitude, longitude float64) ([]Placement, error) {
+
+	point, err := NewPoint(latitude, longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	containing, err := r.store.Contains(point)
+	if err != nil {
+		return nil, err
+	}
+
+	placements := []Placement{}
+	placed := make(map[*Feature]bool)
+	levels := make(map[string]bool)
+	for _, feat := range containing {
+		placements = append(placements, Placement{Feature: feat, Contained: true})
+		placed[feat] = true
+		levels[propertyKey(feat.Property(r.level))] = true
+	}
+
+	if r.fallback > 0 {
+		nearby, err := r.store.WithinDistance(point, r.fallback)
+		if err != nil {
+			return nil, err
+		}
+		for _, feat := range nearby {
+			level := propertyKey(feat.Property(r.level))
+			if placed[feat] || levels[level] {
+				continue
+			}
+			distance, err := feat.Distance(point)
+			if err != nil {
+				return nil, err
+			}
+			placements = append(placements, Placement{Feature: feat, Distance: distance})
+			levels[level] = true
+		}
+	}
+
+	sort.SliceStable(placements, func(i, j int) bool {
+		return r.less(placements[i].Feature, placements[j].Feature)
+	})
+
+	return placements, nil
+}
+
+// less reports whether feature a comes before b by level.
+func (r *ReverseGeocoder) less(a, b *Feature) bool {
+
+	x, y := a.Property(r.level), b.Property(r.level)
+
+	if r.order != nil {
+		return r.rank(x) < r.rank(y)
+	}
+
+	switch {
+	case x == nil:
+		return false
+	case y == nil:
+		return true
+	}
+	return compareProperties(x, y) < 0
+}
+
+// rank returns the position of the level in the order, or the length of the
+// order if it is not listed.
+func (r *ReverseGeocoder) rank(level interface{}) int {
+	if level != nil {
+		for i, value := range r.order {
+			if propertiesEqual(value, level) {
+				return i
+			}
+		}
+	}
+	return len(r.order)
+}
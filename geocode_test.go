@@ -0,0 +1,85 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReverseGeocoder(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("given a store of nested areas", t, func() {
+
+		store, err := OpenGeostore("./geostore-geocode")
+		So(err, ShouldBeNil)
+
+		areas := []struct {
+			id, kind string
+			level    int
+			west     float64
+			south    float64
+			east     float64
+			north    float64
+		}{
+			{"park", "park", 4, -119.9, 37.5, -119.2, 38.2},
+			{"state", "state", 1, -124.4, 32.5, -114.1, 42.0},
+			{"country", "country", 0, -125.0, 24.5, -66.9, 49.4},
+			{"county", "county", 2, -120.2, 37.4, -119.3, 38.3},
+		}
+		for _, area := range areas {
+			polygon := rectangle(area.id, area.west, area.south, area.east, area.north)
+			polygon.Properties = map[string]interface{}{"level": area.level, "kind": area.kind}
+			_, err = store.Add(polygon)
+			So(err, ShouldBeNil)
+		}
+
+		ids := func(placements []Placement) []string {
+			list := []string{}
+			for _, placement := range placements {
+				list = append(list, placement.Feature.ID)
+			}
+			return list
+		}
+
+		Convey("should order containing features by level", func() {
+			geocoder := NewReverseGeocoder(store, "level")
+			placements, err := geocoder.Lookup(37.865101, -119.538329)
+			So(err, ShouldBeNil)
+			So(ids(placements), ShouldResemble, []string{"country", "state", "county", "park"})
+			So(placements[0].Contained, ShouldBeTrue)
+
+			geocoder = NewReverseGeocoder(store, "kind")
+			geocoder.SetLevelOrder("country", "state", "county")
+			placements, err = geocoder.Lookup(37.865101, -119.538329)
+			So(err, ShouldBeNil)
+			So(ids(placements), ShouldResemble, []string{"country", "state", "county", "park"})
+		})
+
+		Convey("should fall back to the nearest feature of each missing level", func() {
+			geocoder := NewReverseGeocoder(store, "level")
+			// Just off the coast, west of the state and country.
+			placements, err := geocoder.Lookup(36.0, -125.05)
+			So(err, ShouldBeNil)
+			So(placements, ShouldBeEmpty)
+
+			geocoder.SetFallbackDistance(10000)
+			placements, err = geocoder.Lookup(36.0, -125.05)
+			So(err, ShouldBeNil)
+			So(ids(placements), ShouldResemble, []string{"country"})
+			So(placements[0].Contained, ShouldBeFalse)
+			So(placements[0].Distance, ShouldAlmostEqual, 4500, 100)
+
+			geocoder.SetFallbackDistance(100000)
+			placements, err = geocoder.Lookup(36.0, -125.05)
+			So(err, ShouldBeNil)
+			So(ids(placements), ShouldResemble, []string{"country", "state"})
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
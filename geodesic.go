@@ -0,0 +1,513 @@
+package terra
+
+import (
+	"math"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// The WGS84 ellipsoid, on which all measurements are made.
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+	wgs84B = wgs84A * (1 - wgs84F)
+)
+
+var (
+	// wgs84E is the first eccentricity of the ellipsoid.
+	wgs84E = math.Sqrt(wgs84F * (2 - wgs84F))
+	// authalicRadius is the radius of the sphere with the ellipsoid's area.
+	authalicRadius = math.Sqrt(wgs84A * wgs84A / 2 * (1 + (1-wgs84E*wgs84E)/(2*wgs84E)*math.Log((1+wgs84E)/(1-wgs84E))))
+	// meanRadius is the mean radius of the ellipsoid.
+	meanRadius = (2*wgs84A + wgs84B) / 3
+)
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// geodesicInverse solves the inverse geodesic problem with Vincenty's formulae,
+// returning the distance in meters between two lon/lat positions and the
+// azimuth in degrees from the first towards the second. Nearly antipodal
+// positions, for which the iteration does not converge, fall back to a
+// great circle on the mean sphere.
+func geodesicInverse(from, to geos.Coord) (float64, float64) {
+
+	if from.X == to.X && from.Y == to.Y {
+		return 0, 0
+	}
+
+	L := radians(wrapLongitude(to.X - from.X))
+	U1 := math.Atan((1 - wgs84F) * math.Tan(radians(from.Y)))
+	U2 := math.Atan((1 - wgs84F) * math.Tan(radians(to.Y)))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	var sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM, sinLambda, cosLambda float64
+	for i := 0; ; i++ {
+		if i == 200 {
+			return greatCircleInverse(from, to)
+		}
+		sinLambda, cosLambda = math.Sincos(lambda)
+		sinSigma = math.Sqrt(math.Pow(cosU2*sinLambda, 2) + math.Pow(cosU1*sinU2-sinU1*cosU2*cosLambda, 2))
+		if sinSigma == 0 {
+			return 0, 0
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+		C := wgs84F / 16 * cosSqAlpha * (4 + wgs84F*(4-3*cosSqAlpha))
+		previous := lambda
+		lambda = L + (1-C)*wgs84F*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-previous) < 1e-12 {
+			break
+		}
+		if math.Abs(lambda) > math.Pi {
+			return greatCircleInverse(from, to)
+		}
+	}
+
+	uSq := cosSqAlpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+
+	distance := wgs84B * A * (sigma - deltaSigma)
+	azimuth := degrees(math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda))
+
+	return distance, azimuth
+}
+
+// greatCircleInverse is the spherical counterpart of geodesicInverse.
+func greatCircleInverse(from, to geos.Coord) (float64, float64) {
+
+	phi1, phi2 := radians(from.Y), radians(to.Y)
+	dLambda := radians(to.X - from.X)
+
+	a := math.Pow(math.Sin((phi2-phi1)/2), 2) + math.Cos(phi1)*math.Cos(phi2)*math.Pow(math.Sin(dLambda/2), 2)
+	distance := 2 * meanRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+	azimuth := degrees(math.Atan2(math.Sin(dLambda)*math.Cos(phi2), math.Cos(phi1)*math.Sin(phi2)-math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)))
+
+	return distance, azimuth
+}
+
+// geodesicDirect solves the direct geodesic problem with Vincenty's formulae,
+// returning the lon/lat position reached by travelling the given number of
+// meters from a position along the azimuth in degrees.
+func geodesicDirect(from geos.Coord, azimuth, distance float64) geos.Coord {
+
+	if distance == 0 {
+		return from
+	}
+
+	sinAlpha1, cosAlpha1 := math.Sincos(radians(azimuth))
+	tanU1 := (1 - wgs84F) * math.Tan(radians(from.Y))
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cosSqAlpha := 1 - sinAlpha*sinAlpha
+	uSq := cosSqAlpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+
+	sigma := distance / (wgs84B * A)
+	var sinSigma, cosSigma, cos2SigmaM float64
+	for i := 0; i < 200; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		previous := sigma
+		sigma = distance/(wgs84B*A) + deltaSigma
+		if math.Abs(sigma-previous) < 1e-12 {
+			break
+		}
+	}
+	sinSigma, cosSigma = math.Sincos(sigma)
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+
+	tmp := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	phi2 := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1, (1-wgs84F)*math.Sqrt(sinAlpha*sinAlpha+tmp*tmp))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	C := wgs84F / 16 * cosSqAlpha * (4 + wgs84F*(4-3*cosSqAlpha))
+	L := lambda - (1-C)*wgs84F*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+
+	return normalizeCoord(geos.NewCoord(from.X+degrees(L), degrees(phi2)))
+}
+
+// lineLength is the geodesic length in meters of a line through the positions.
+func lineLength(coords []geos.Coord) float64 {
+	var length float64
+	for i := 0; i+1 < len(coords); i++ {
+		d, _ := geodesicInverse(coords[i], coords[i+1])
+		length += d
+	}
+	return length
+}
+
+// authalicLatitude maps a geodetic latitude in radians onto the sphere of equal
+// area.
+func authalicLatitude(phi float64) float64 {
+	q := func(sinPhi float64) float64 {
+		e := wgs84E
+		return (1 - e*e) * (sinPhi/(1-e*e*sinPhi*sinPhi) - 1/(2*e)*math.Log((1-e*sinPhi)/(1+e*sinPhi)))
+	}
+	return math.Asin(math.Max(-1, math.Min(1, q(math.Sin(phi))/q(1))))
+}
+
+// geodesicRingArea is the unsigned area in square meters enclosed by a ring,
+// measured on the authalic sphere, which preserves the area of the ellipsoid.
+func geodesicRingArea(ring []geos.Coord) float64 {
+
+	var excess float64
+	for i := 0; i+1 < len(ring); i++ {
+		t1 := math.Tan(authalicLatitude(radians(ring[i].Y)) / 2)
+		t2 := math.Tan(authalicLatitude(radians(ring[i+1].Y)) / 2)
+		dLambda := math.Remainder(radians(ring[i+1].X-ring[i].X), 2*math.Pi)
+		excess += 2 * math.Atan2(math.Tan(dLambda/2)*(t1+t2), 1+t1*t2)
+	}
+
+	return math.Abs(excess) * authalicRadius * authalicRadius
+}
+
+// Area returns the area of a polygonal feature in square meters, measured on
+// the WGS84 ellipsoid. Other features have no area.
+func (feat *Feature) Area() (float64, error) {
+
+	feat, err := feat.wgs84()
+	if err != nil {
+		return 0, err
+	}
+
+	polygons, err := featurePolygons(feat)
+	if err != nil {
+		return 0, err
+	}
+
+	var area float64
+	for _, rings := range polygons {
+		area += geodesicRingArea(rings[0])
+		for _, hole := range rings[1:] {
+			area -= geodesicRingArea(hole)
+		}
+	}
+
+	return area, nil
+}
+
+// Length returns the geodesic length in meters of a linear feature. Polygons
+// are measured with Perimeter and points have no length.
+func (feat *Feature) Length() (float64, error) {
+
+	if feat.Geometry == nil {
+		return 0, errors.New("Unable to measure a feature without geometry.")
+	}
+
+	feat, err := feat.wgs84()
+	if err != nil {
+		return 0, err
+	}
+
+	parts, err := geometryParts(feat.Geometry)
+	if err != nil {
+		return 0, err
+	}
+
+	var length float64
+	for _, part := range parts {
+		typer, err := part.Type()
+		if err != nil {
+			return 0, errors.Wrap(err, "could not get geometry type")
+		}
+		if typer != geos.LINESTRING && typer != geos.LINEARRING {
+			continue
+		}
+		coords, err := part.Coords()
+		if err != nil {
+			return 0, errors.Wrap(err, "could not get geometry coords")
+		}
+		length += lineLength(coords)
+	}
+
+	return length, nil
+}
+
+// Perimeter returns the geodesic length in meters of every ring of a polygonal
+// feature, holes included.
+func (feat *Feature) Perimeter() (float64, error) {
+
+	feat, err := feat.wgs84()
+	if err != nil {
+		return 0, err
+	}
+
+	polygons, err := featurePolygons(feat)
+	if err != nil {
+		return 0, err
+	}
+
+	var perimeter float64
+	for _, rings := range polygons {
+		for _, ring := range rings {
+			perimeter += lineLength(ring)
+		}
+	}
+
+	return perimeter, nil
+}
+
+// featurePolygons returns the rings of every polygon in the feature.
+func featurePolygons(feat *Feature) ([][][]geos.Coord, error) {
+
+	if feat.Geometry == nil {
+		return nil, errors.New("Unable to measure a feature without geometry.")
+	}
+
+	parts, err := geometryParts(feat.Geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	var polygons [][][]geos.Coord
+	for _, part := range parts {
+		typer, err := part.Type()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get geometry type")
+		}
+		if typer != geos.POLYGON {
+			continue
+		}
+		rings, err := polygonRings(part)
+		if err != nil {
+			return nil, err
+		}
+		polygons = append(polygons, rings)
+	}
+
+	return polygons, nil
+}
+
+// Distance returns the shortest geodesic distance in meters between the two
+// features, which is zero where they intersect.
+func (feat *Feature) Distance(other *Feature) (float64, error) {
+
+	if feat.Geometry == nil || other == nil || other.Geometry == nil {
+		return 0, errors.New("Unable to measure the distance between features without geometry.")
+	}
+
+	feat, err := feat.wgs84()
+	if err != nil {
+		return 0, err
+	}
+	if other, err = other.wgs84(); err != nil {
+		return 0, err
+	}
+
+	intersects, err := feat.Geometry.Intersects(other.Geometry)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not check intersection")
+	}
+	if intersects {
+		return 0, nil
+	}
+
+	aVertices, aSegments, err := featureSegments(feat)
+	if err != nil {
+		return 0, err
+	}
+	bVertices, bSegments, err := featureSegments(other)
+	if err != nil {
+		return 0, err
+	}
+
+	// Rank each position against each segment of the other feature, or each
+	// position where it has no segments, on the sphere. The sphere is never
+	// off the ellipsoid by as much as a percent, so only the pairs within that
+	// of the nearest can be nearest on the ellipsoid, and only those are
+	// measured there.
+	var (
+		pairs []distancePair
+		best  = math.Inf(1)
+	)
+	rank := func(vertices []geos.Coord, segments [][2]geos.Coord, others []geos.Coord) {
+		for _, v := range vertices {
+			for _, s := range segments {
+				if greatCircleDistance(v, segmentPoint(s[0], s[1], 0.5))-segmentReach(s[0], s[1]) > best*distanceMargin {
+					continue
+				}
+				d := segmentDistance(v, s[0], s[1], greatCircleDistance)
+				pairs = append(pairs, distancePair{v, s[0], s[1], d})
+				best = math.Min(best, d)
+			}
+			if len(segments) > 0 {
+				continue
+			}
+			for _, o := range others {
+				d := greatCircleDistance(v, o)
+				pairs = append(pairs, distancePair{v, o, o, d})
+				best = math.Min(best, d)
+			}
+		}
+	}
+	rank(aVertices, bSegments, bVertices)
+	rank(bVertices, aSegments, aVertices)
+
+	if math.IsInf(best, 1) {
+		return 0, errors.New("Unable to measure the distance between empty features.")
+	}
+
+	distance := math.Inf(1)
+	for _, pair := range pairs {
+		if pair.sphere > best*distanceMargin {
+			continue
+		}
+		d := segmentDistance(pair.p, pair.a, pair.b, geodesicDistance)
+		distance = math.Min(distance, d)
+	}
+
+	return distance, nil
+}
+
+// distanceMargin bounds how much further than the nearest on the sphere a
+// pair may be and still be the nearest on the ellipsoid.
+const distanceMargin = 1.02
+
+// distancePair is a position and the segment ab of another feature, or the
+// position a = b, with the distance between them on the sphere.
+type distancePair struct {
+	p, a, b geos.Coord
+	sphere  float64
+}
+
+func greatCircleDistance(a, b geos.Coord) float64 {
+	d, _ := greatCircleInverse(a, b)
+	return d
+}
+
+func geodesicDistance(a, b geos.Coord) float64 {
+	d, _ := geodesicInverse(a, b)
+	return d
+}
+
+// segmentPoint returns the position a fraction t along the segment ab,
+// interpolating longitude and latitude as the geometry does.
+func segmentPoint(a, b geos.Coord, t float64) geos.Coord {
+	return geos.NewCoord(a.X+t*math.Remainder(b.X-a.X, 360), a.Y+t*(b.Y-a.Y))
+}
+
+// segmentReach bounds the distance on the sphere from the middle of the
+// segment ab to any position on it: half its length were every degree of
+// longitude as long as at the latitude nearest the equator.
+func segmentReach(a, b geos.Coord) float64 {
+
+	cos := math.Cos(radians(math.Min(math.Abs(a.Y), math.Abs(b.Y))))
+	if (a.Y < 0) != (b.Y < 0) {
+		cos = 1
+	}
+
+	return radians(math.Hypot(math.Remainder(b.X-a.X, 360)*cos, b.Y-a.Y)) * meanRadius / 2
+}
+
+// segmentDistance returns the distance by the measure from p to the nearest
+// position on the segment ab, narrowing in on it by golden section search.
+func segmentDistance(p, a, b geos.Coord, measure func(a, b geos.Coord) float64) float64 {
+
+	if a.X == b.X && a.Y == b.Y {
+		return measure(p, a)
+	}
+
+	const ratio = 0.6180339887498949
+	lo, hi := 0.0, 1.0
+	t1, t2 := hi-ratio*(hi-lo), lo+ratio*(hi-lo)
+	d1, d2 := measure(p, segmentPoint(a, b, t1)), measure(p, segmentPoint(a, b, t2))
+	for hi-lo > 1e-9 {
+		if d1 <= d2 {
+			hi, t2, d2 = t2, t1, d1
+			t1 = hi - ratio*(hi-lo)
+			d1 = measure(p, segmentPoint(a, b, t1))
+		} else {
+			lo, t1, d1 = t1, t2, d2
+			t2 = lo + ratio*(hi-lo)
+			d2 = measure(p, segmentPoint(a, b, t2))
+		}
+	}
+
+	// The search settles inside the segment, so an end may still be nearer.
+	return math.Min(math.Min(d1, d2), math.Min(measure(p, a), measure(p, b)))
+}
+
+// featureSegments returns the vertices of the feature and the segments of its
+// lines and rings.
+func featureSegments(feat *Feature) ([]geos.Coord, [][2]geos.Coord, error) {
+
+	lines, err := featureLines(feat)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		vertices []geos.Coord
+		segments [][2]geos.Coord
+	)
+	for _, line := range lines {
+		vertices = append(vertices, line...)
+		for i := 0; i+1 < len(line); i++ {
+			segments = append(segments, [2]geos.Coord{line[i], line[i+1]})
+		}
+	}
+
+	if len(lines) == 0 {
+		if vertices, err = geometryCoords(feat.Geometry); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return vertices, segments, nil
+}
+
+// expandBBox grows a bbox by at least the given number of meters in every
+// direction, wrapping across the antimeridian and covering every longitude
+// when close enough to a pole.
+func expandBBox(bbox []float64, meters float64) []float64 {
+
+	dimensions := len(bbox) / 2
+	west, south, east, north := bbox[0], bbox[1], bbox[dimensions], bbox[dimensions+1]
+
+	// A degree of latitude is never shorter than at the equator.
+	dLat := meters / 110574
+	south, north = math.Max(-90, south-dLat), math.Min(90, north+dLat)
+
+	cos := math.Cos(radians(math.Max(math.Abs(south), math.Abs(north))))
+	width := east - west
+	if width < 0 {
+		width += 360
+	}
+	dLon := meters / (111320 * cos)
+	if cos <= 0 || width+2*dLon >= 360 {
+		return []float64{-180, south, 180, north}
+	}
+
+	west, east = west-dLon, east+dLon
+	if west < -180 {
+		west += 360
+	}
+	if east > 180 {
+		east -= 360
+	}
+
+	return []float64{west, south, east, north}
+}
@@ -0,0 +1,95 @@
+package terra
+
+import (
+	"testing"
+
+	"github.com/paulsmith/gogeos/geos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGeodesic(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should solve the inverse problem on the ellipsoid", t, func() {
+		// Flinders Peak to Buninyong, Vincenty's own test line.
+		distance, azimuth := geodesicInverse(
+			geos.NewCoord(144.424867889, -37.951033417),
+			geos.NewCoord(143.926495528, -37.652821139),
+		)
+		So(distance, ShouldAlmostEqual, 54972.271, 0.01)
+		So(azimuth, ShouldAlmostEqual, -53.1318, 0.001)
+	})
+
+	Convey("should solve the direct problem on the ellipsoid", t, func() {
+		c := geodesicDirect(geos.NewCoord(144.424867889, -37.951033417), 306.86816, 54972.271)
+		So(c.X, ShouldAlmostEqual, 143.926495528, 0.000001)
+		So(c.Y, ShouldAlmostEqual, -37.652821139, 0.000001)
+	})
+
+	Convey("should round trip the azimuthal projection", t, func() {
+		projection := azimuthalProjection{center: geos.NewCoord(179.9, 10)}
+		c := projection.inverse(projection.forward(geos.NewCoord(-179.8, 10.5)))
+		So(c.X, ShouldAlmostEqual, -179.8, 0.0000001)
+		So(c.Y, ShouldAlmostEqual, 10.5, 0.0000001)
+		So(projection.forward(geos.NewCoord(179.9, 10.01)).Y, ShouldAlmostEqual, 1106.3, 1)
+	})
+
+	Convey("should fall back for antipodal positions", t, func() {
+		distance, _ := geodesicInverse(geos.NewCoord(0, 0), geos.NewCoord(180, 0))
+		So(distance, ShouldAlmostEqual, 20003931, 20000)
+	})
+
+	Convey("should measure the area of a degree square on the equator", t, func() {
+		area := geodesicRingArea(ring(0, 0, 1, 0, 1, 1, 0, 1, 0, 0))
+		So(area, ShouldAlmostEqual, 12308778361, 12308778361*0.001)
+	})
+
+	Convey("should measure the same area either side of the antimeridian", t, func() {
+		So(geodesicRingArea(ring(179.5, 0, -179.5, 0, -179.5, 1, 179.5, 1, 179.5, 0)),
+			ShouldAlmostEqual, geodesicRingArea(ring(0, 0, 1, 0, 1, 1, 0, 1, 0, 0)), 1)
+	})
+
+	Convey("should expand a bounding box across the antimeridian", t, func() {
+		bbox := expandBBox([]float64{179.99, 0, 179.995, 0.01}, 5000)
+		So(bbox[0], ShouldBeGreaterThan, bbox[2])
+	})
+
+	Convey("should find the nearest position on a segment", t, func() {
+		// A degree of longitude on the equator.
+		So(segmentDistance(geos.NewCoord(1, 0), geos.NewCoord(0, -1), geos.NewCoord(0, 1), geodesicDistance),
+			ShouldAlmostEqual, 111319.491, 0.001)
+		So(segmentDistance(geos.NewCoord(0, 85), geos.NewCoord(-90, 80), geos.NewCoord(90, 80), geodesicDistance),
+			ShouldAlmostEqual, geodesicDistance(geos.NewCoord(0, 85), geos.NewCoord(0, 80)), 0.001)
+	})
+
+	Convey("should measure between the nearest positions far apart at high latitude", t, func() {
+		point, err := NewPoint(80, 0)
+		So(err, ShouldBeNil)
+		// Across the pole, the first is nearer, though a degree of longitude
+		// at the point would make the second look so.
+		others, err := NewFeatureFromJSON([]byte(`{"type": "Feature", "properties": null, "geometry": {"type": "MultiPoint", "coordinates": [[90, 80], [0, 65.5]]}}`))
+		So(err, ShouldBeNil)
+
+		distance, err := point.Distance(others)
+		So(err, ShouldBeNil)
+		So(distance, ShouldAlmostEqual, 1575400, 1)
+	})
+
+	Convey("should report a geometry that cannot be rebuilt", t, func() {
+		square, err := NewPolygon([][][]float64{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}})
+		So(err, ShouldBeNil)
+
+		// Moving only the first position leaves the ring unclosed.
+		moved := false
+		transformed, err := transformGeometry(square.Geometry, func(c geos.Coord) geos.Coord {
+			if moved {
+				return c
+			}
+			moved = true
+			return geos.NewCoord(c.X+0.5, c.Y)
+		})
+		So(err, ShouldNotBeNil)
+		So(transformed, ShouldBeNil)
+	})
+}
@@ -0,0 +1,251 @@
+package terra
+
+/*
+#cgo LDFLAGS: -lgeos_c
+#include <stdlib.h>
+#include <string.h>
+#include <geos_c.h>
+
+#define TERRA_MESSAGE_SIZE 256
+
+static void terra_geos_error(const char *message, void *userdata) {
+	strncpy((char *) userdata, message, TERRA_MESSAGE_SIZE - 1);
+	((char *) userdata)[TERRA_MESSAGE_SIZE - 1] = '\0';
+}
+
+static GEOSContextHandle_t terra_geos_init(char *message) {
+	GEOSContextHandle_t handle = GEOS_init_r();
+	if (handle != NULL) {
+		GEOSContext_setErrorMessageHandler_r(handle, terra_geos_error, message);
+	}
+	return handle;
+}
+*/
+import "C"
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// geosContext is a GEOS handle of its own, used through the reentrant GEOS
+// API. gogeos shares a single handle among all goroutines and serializes every
+// call on it, so work meant to run in parallel, such as the ContainsStream
+// workers, gives each goroutine a context instead. A context must be used by
+// one goroutine at a time, holding its lock, and destroyed once done with.
+type geosContext struct {
+	sync.Mutex
+	handle  C.GEOSContextHandle_t
+	message *C.char
+	reader  *C.GEOSWKBReader
+	// geometries and prepared hold the stored features converted into this
+	// context, by key.
+	geometries map[string]*C.GEOSGeometry
+	prepared   map[string]*C.GEOSPreparedGeometry
+}
+
+func newGEOSContext() (*geosContext, error) {
+
+	message := (*C.char)(C.calloc(C.TERRA_MESSAGE_SIZE, 1))
+	handle := C.terra_geos_init(message)
+	if handle == nil {
+		C.free(unsafe.Pointer(message))
+		return nil, errors.New("Unable to initialize a GEOS context.")
+	}
+
+	return &geosContext{
+		handle:     handle,
+		message:    message,
+		reader:     C.GEOSWKBReader_create_r(handle),
+		geometries: make(map[string]*C.GEOSGeometry),
+		prepared:   make(map[string]*C.GEOSPreparedGeometry),
+	}, nil
+}
+
+// destroy frees the context and every geometry converted into it.
+func (c *geosContext) destroy() {
+
+	c.forgetAll()
+	C.GEOSWKBReader_destroy_r(c.handle, c.reader)
+	C.GEOS_finish_r(c.handle)
+	C.free(unsafe.Pointer(c.message))
+}
+
+// err returns the last error GEOS reported on the context.
+func (c *geosContext) err(action string) error {
+	return errors.Newf("Unable to %s: %s.", action, C.GoString(c.message))
+}
+
+// geometry converts a gogeos geometry into one owned by the context, which the
+// caller must destroy. It is carried across as WKB, which keeps coordinates
+// exactly and is cheaper to write and read than WKT.
+func (c *geosContext) geometry(geometry *geos.Geometry) (*C.GEOSGeometry, error) {
+
+	wkb, err := geometry.WKB()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not write geometry as wkb")
+	}
+	if len(wkb) == 0 {
+		return nil, errors.New("Unable to read an empty wkb into a GEOS context.")
+	}
+
+	g := C.GEOSWKBReader_read_r(c.handle, c.reader, (*C.uchar)(unsafe.Pointer(&wkb[0])), C.size_t(len(wkb)))
+	if g == nil {
+		return nil, c.err("read a geometry into a GEOS context")
+	}
+
+	return g, nil
+}
+
+// point creates a point owned by the context, which the caller must destroy.
+func (c *geosContext) point(x, y float64) (*C.GEOSGeometry, error) {
+
+	seq := C.GEOSCoordSeq_create_r(c.handle, 1, 2)
+	if seq == nil {
+		return nil, c.err("create a coordinate sequence")
+	}
+	C.GEOSCoordSeq_setX_r(c.handle, seq, 0, C.double(x))
+	C.GEOSCoordSeq_setY_r(c.handle, seq, 0, C.double(y))
+
+	g := C.GEOSGeom_createPoint_r(c.handle, seq)
+	if g == nil {
+		return nil, c.err("create a point")
+	}
+
+	return g, nil
+}
+
+// prepare returns the prepared geometry of the stored feature, converting and
+// caching it on first use.
+func (c *geosContext) prepare(key string, feat *Feature) (*C.GEOSPreparedGeometry, error) {
+
+	if prepared, ok := c.prepared[key]; ok {
+		return prepared, nil
+	}
+
+	g, err := c.geometry(feat.Geometry)
+	if err != nil {
+		return nil, err
+	}
+	prepared := C.GEOSPrepare_r(c.handle, g)
+	if prepared == nil {
+		C.GEOSGeom_destroy_r(c.handle, g)
+		return nil, c.err("prepare a geometry")
+	}
+
+	c.geometries[key] = g
+	c.prepared[key] = prepared
+
+	return prepared, nil
+}
+
+// forget destroys the geometry prepared for the keyed feature, if there is one,
+// so that it is read again when next needed.
+func (c *geosContext) forget(key string) {
+
+	prepared, ok := c.prepared[key]
+	if !ok {
+		return
+	}
+	C.GEOSPreparedGeom_destroy_r(c.handle, prepared)
+	C.GEOSGeom_destroy_r(c.handle, c.geometries[key])
+	delete(c.prepared, key)
+	delete(c.geometries, key)
+}
+
+// forgetAll destroys every geometry prepared in the context.
+func (c *geosContext) forgetAll() {
+	for key := range c.prepared {
+		c.forget(key)
+	}
+}
+
+// contains reports whether the prepared geometry contains the other.
+func (c *geosContext) contains(prepared *C.GEOSPreparedGeometry, g *C.GEOSGeometry) (bool, error) {
+	switch C.GEOSPreparedContains_r(c.handle, prepared, g) {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	}
+	return false, c.err("test containment")
+}
+
+// containsInContext returns the stored features containing the feature, as
+// containsPrepared does, testing candidates with geometries prepared in the
+// context.
+func (g *Geostore) containsInContext(c *geosContext, feat *Feature) ([]*Feature, error) {
+
+	if feat.Geometry == nil {
+		return nil, errors.New("Unable to find the features containing one without geometry.")
+	}
+
+	var (
+		geometry *C.GEOSGeometry
+		err      error
+	)
+	if feat.Type == "Point" {
+		// The bounds of a point are cached, so reading them never waits on
+		// the handle gogeos shares.
+		var bbox []float64
+		if bbox, err = feat.wgs84BoundingBox(); err != nil {
+			return nil, err
+		}
+		geometry, err = c.point(bbox[0], bbox[1])
+	} else {
+		geometry, err = c.geometry(feat.Geometry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer C.GEOSGeom_destroy_r(c.handle, geometry)
+
+	return g.containsPrepared(feat, func(key string, candidate *Feature) (bool, error) {
+		prepared, err := c.prepare(key, candidate)
+		if err != nil {
+			return false, err
+		}
+		return c.contains(prepared, geometry)
+	})
+}
+
+// isValid reports whether GEOS considers the geometry valid, and if not the
+// reason it gives.
+func (c *geosContext) isValid(geometry *geos.Geometry) (bool, string, error) {
+
+	g, err := c.geometry(geometry)
+	if err != nil {
+		return false, "", err
+	}
+	defer C.GEOSGeom_destroy_r(c.handle, g)
+
+	switch C.GEOSisValid_r(c.handle, g) {
+	case 1:
+		return true, "", nil
+	case 2:
+		return false, "", c.err("check validity")
+	}
+
+	reason := C.GEOSisValidReason_r(c.handle, g)
+	if reason == nil {
+		return false, "", nil
+	}
+	defer C.GEOSFree_r(c.handle, unsafe.Pointer(reason))
+
+	return false, C.GoString(reason), nil
+}
+
+// geosIsValid checks the geometry with GEOS, in a context of its own.
+func geosIsValid(geometry *geos.Geometry) (bool, string, error) {
+
+	c, err := newGEOSContext()
+	if err != nil {
+		return false, "", err
+	}
+	defer c.destroy()
+
+	return c.isValid(geometry)
+}
@@ -0,0 +1,266 @@
+package terra
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// GridUnit is the unit in which the size of grid cells is given.
+type GridUnit int
+
+const (
+	// Degrees sizes cells in degrees of longitude and latitude.
+	Degrees GridUnit = iota
+	// Meters sizes cells in meters, converted into degrees at the latitude of
+	// the middle of the extent, so that cells further north or south are
+	// narrower on the ground.
+	Meters
+)
+
+// maxGridCells bounds the cells a grid may have.
+const maxGridCells = 1 << 22
+
+// gridSteps returns the size in degrees of longitude and latitude of a cell
+// side, checking the extent and the number of cells it would hold.
+func gridSteps(bbox []float64, size float64, unit GridUnit) (float64, float64, error) {
+
+	if len(bbox) != 4 {
+		return 0, 0, errors.New("A grid extent must be a [west, south, east, north] bounding box.")
+	}
+	if bbox[0] >= bbox[2] || bbox[1] >= bbox[3] {
+		return 0, 0, errors.New("Unable to build a grid over an empty extent or across the antimeridian.")
+	}
+	if size <= 0 {
+		return 0, 0, errors.Newf("The grid cell size %g is not positive.", size)
+	}
+
+	dx, dy := size, size
+	if unit == Meters {
+		// The length of a degree of latitude, and of longitude at the equator.
+		dy = size / 111132
+		dx = size / (111320 * math.Cos(radians((bbox[1]+bbox[3])/2)))
+	}
+
+	if cells := (bbox[2] - bbox[0]) / dx * (bbox[3] - bbox[1]) / dy; cells > maxGridCells {
+		return 0, 0, errors.Newf("A grid of about %.0f cells is larger than the %d allowed.", cells, maxGridCells)
+	}
+
+	return dx, dy, nil
+}
+
+// gridCount returns the number of steps needed to cover the span. Counting
+// them up front, and placing each cell by its index rather than by adding up
+// steps, keeps the error of the steps from adding a row or column.
+func gridCount(span, step float64) int {
+	return int(math.Ceil(span/step - 1e-9))
+}
+
+// gridCell returns a polygon feature of the ring, numbered in the grid.
+func gridCell(i int, ring [][]float64) (*Feature, error) {
+
+	cell, err := NewPolygon([][][]float64{append(ring, ring[0])})
+	if err != nil {
+		return nil, err
+	}
+	cell.ID = strconv.Itoa(i)
+	cell.Properties = map[string]interface{}{}
+
+	return cell, nil
+}
+
+// SquareGrid returns a collection of square cells of the side covering the
+// [west, south, east, north] extent, from its southwest corner, row by row.
+// Cells are numbered in that order from "0".
+func SquareGrid(bbox []float64, size float64, unit GridUnit) (FeatureCollection, error) {
+
+	dx, dy, err := gridSteps(bbox, size, unit)
+	if err != nil {
+		return nil, err
+	}
+	columns, rows := gridCount(bbox[2]-bbox[0], dx), gridCount(bbox[3]-bbox[1], dy)
+
+	grid := make(FeatureCollection, 0, columns*rows)
+	for j := 0; j < rows; j++ {
+		south, north := bbox[1]+float64(j)*dy, bbox[1]+float64(j+1)*dy
+		for i := 0; i < columns; i++ {
+			west, east := bbox[0]+float64(i)*dx, bbox[0]+float64(i+1)*dx
+			cell, err := gridCell(len(grid), [][]float64{{west, south}, {east, south}, {east, north}, {west, north}})
+			if err != nil {
+				return nil, err
+			}
+			grid = append(grid, cell)
+		}
+	}
+
+	return grid, nil
+}
+
+// HexGrid returns a collection of flat-topped hexagons of the side covering
+// the extent, the first centered on its southwest corner, column by column.
+// Every other column is shifted north by half a hexagon.
+func HexGrid(bbox []float64, size float64, unit GridUnit) (FeatureCollection, error) {
+
+	dx, dy, err := gridSteps(bbox, size, unit)
+	if err != nil {
+		return nil, err
+	}
+	height := math.Sqrt(3) * dy
+
+	grid := FeatureCollection{}
+	for i, columns := 0, gridCount(bbox[2]-bbox[0]+dx, 1.5*dx); i < columns; i++ {
+		x := bbox[0] + float64(i)*1.5*dx
+		// Even columns are centered from the southern edge, and odd ones
+		// from half a hexagon north of it.
+		south, rows := bbox[1], gridCount(bbox[3]-bbox[1]+height/2, height)
+		if i%2 == 1 {
+			south, rows = bbox[1]+height/2, gridCount(bbox[3]-bbox[1], height)
+		}
+		for j := 0; j < rows; j++ {
+			y := south + float64(j)*height
+			ring := make([][]float64, 6)
+			for k := range ring {
+				angle := float64(k) * math.Pi / 3
+				ring[k] = []float64{x + dx*math.Cos(angle), y + dy*math.Sin(angle)}
+			}
+			cell, err := gridCell(len(grid), ring)
+			if err != nil {
+				return nil, err
+			}
+			grid = append(grid, cell)
+		}
+	}
+
+	return grid, nil
+}
+
+// TriangleGrid returns a collection of equilateral triangles of the side
+// covering the extent, pointing north and south in turn, row by row from its
+// southwest corner. Every other row is shifted west by half a side.
+func TriangleGrid(bbox []float64, size float64, unit GridUnit) (FeatureCollection, error) {
+
+	dx, dy, err := gridSteps(bbox, size, unit)
+	if err != nil {
+		return nil, err
+	}
+	height := math.Sqrt(3) / 2 * dy
+
+	// Vertices fall on every half side, so they are placed by their index in
+	// half sides from the western edge, which neighbouring triangles share.
+	half := func(k int) float64 {
+		return bbox[0] + float64(k)*dx/2
+	}
+
+	grid := FeatureCollection{}
+	for j, rows := 0, gridCount(bbox[3]-bbox[1], height); j < rows; j++ {
+		south, north := bbox[1]+float64(j)*height, bbox[1]+float64(j+1)*height
+		first, columns := 0, gridCount(bbox[2]-bbox[0], dx)
+		if j%2 == 1 {
+			first, columns = -1, gridCount(bbox[2]-bbox[0]+dx/2, dx)
+		}
+		for i := 0; i < columns; i++ {
+			k := first + 2*i
+			rings := [][][]float64{
+				{{half(k), south}, {half(k + 2), south}, {half(k + 1), north}},
+				{{half(k + 1), north}, {half(k + 2), south}, {half(k + 3), north}},
+			}
+			for _, ring := range rings {
+				// The western and eastern vertices are the first and one of
+				// the others.
+				east := math.Max(ring[1][0], ring[2][0])
+				if east <= bbox[0] || ring[0][0] >= bbox[2] {
+					continue
+				}
+				cell, err := gridCell(len(grid), ring)
+				if err != nil {
+					return nil, err
+				}
+				grid = append(grid, cell)
+			}
+		}
+	}
+
+	return grid, nil
+}
+
+// Bin counts and aggregates the features falling in each cell of the grid,
+// returning a new collection of the cells with the results as properties.
+// Points are binned into a single cell, the first covering them, while other
+// features count in each cell they intersect. Every cell is kept; empty ones
+// can be dropped with Filter. To bin the results of a store query, wrap them
+// in a FeatureCollection.
+func Bin(grid FeatureCollection, features FeatureSource, aggregates ...Aggregate) (FeatureCollection, error) {
+
+	if err := checkAggregates(aggregates); err != nil {
+		return nil, err
+	}
+
+	list, err := features.sourceFeatures()
+	if err != nil {
+		return nil, err
+	}
+	cells, err := grid.sourceFeatures()
+	if err != nil {
+		return nil, err
+	}
+	index, err := newJoinIndex(cells)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		stats    = make([][]aggregateStats, len(cells))
+		prepared = make([]*geos.PGeometry, len(cells))
+	)
+	for _, feat := range list {
+		if feat.Geometry == nil {
+			continue
+		}
+		rects, err := feat.Rects()
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range index.search(rects) {
+			if prepared[i] == nil {
+				prepared[i] = cells[i].Geometry.Prepare()
+			}
+			var ok bool
+			if feat.Type == "Point" {
+				ok, err = prepared[i].Covers(feat.Geometry)
+			} else {
+				ok, err = prepared[i].Intersects(feat.Geometry)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not test feature against grid cell %s", cells[i].ID)
+			}
+			if !ok {
+				continue
+			}
+			if stats[i] == nil {
+				stats[i] = make([]aggregateStats, len(aggregates))
+			}
+			accumulate(stats[i], aggregates, feat)
+			if feat.Type == "Point" {
+				break
+			}
+		}
+	}
+
+	binned := make(FeatureCollection, len(cells))
+	for i, cell := range cells {
+		copied := *cell
+		copied.Properties = copyProperties(cell.Properties)
+		if copied.Properties == nil {
+			copied.Properties = make(map[string]interface{}, len(aggregates))
+		}
+		if stats[i] == nil {
+			stats[i] = make([]aggregateStats, len(aggregates))
+		}
+		writeAggregates(copied.Properties, stats[i], aggregates)
+		binned[i] = &copied
+	}
+
+	return binned, nil
+}
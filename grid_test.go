@@ -0,0 +1,106 @@
+package terra
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGrid(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should cover an extent with squares", t, func() {
+		grid, err := SquareGrid([]float64{0, 0, 2, 1}, 0.5, Degrees)
+		So(err, ShouldBeNil)
+		So(len(grid), ShouldEqual, 8)
+		So(grid[7].ID, ShouldEqual, "7")
+		bbox, err := grid[1].BoundingBox()
+		So(err, ShouldBeNil)
+		So(bbox, ShouldResemble, []float64{0.5, 0, 1, 0.5})
+
+		grid, err = SquareGrid([]float64{0, 0, 1, 1}, 10000, Meters)
+		So(err, ShouldBeNil)
+		So(len(grid), ShouldEqual, 144)
+	})
+
+	Convey("should not add a row or column for a step that does not divide exactly", t, func() {
+		grid, err := SquareGrid([]float64{0, 0, 1, 1}, 0.1, Degrees)
+		So(err, ShouldBeNil)
+		So(len(grid), ShouldEqual, 100)
+
+		last, err := grid[99].BoundingBox()
+		So(err, ShouldBeNil)
+		So(last[2], ShouldAlmostEqual, 1, 0.0000001)
+		So(last[3], ShouldAlmostEqual, 1, 0.0000001)
+
+		// Neighbouring cells share their edges exactly.
+		cell, err := grid[36].BoundingBox()
+		So(err, ShouldBeNil)
+		east, err := grid[37].BoundingBox()
+		So(err, ShouldBeNil)
+		north, err := grid[46].BoundingBox()
+		So(err, ShouldBeNil)
+		So(east[0], ShouldEqual, cell[2])
+		So(north[1], ShouldEqual, cell[3])
+	})
+
+	Convey("should cover an extent with hexagons", t, func() {
+		grid, err := HexGrid([]float64{0, 0, 1, 1}, 0.25, Degrees)
+		So(err, ShouldBeNil)
+		So(len(grid), ShouldEqual, 12)
+		area, err := grid[0].Geometry.Area()
+		So(err, ShouldBeNil)
+		So(area, ShouldAlmostEqual, 3*math.Sqrt(3)/2*0.0625, 0.0000001)
+	})
+
+	Convey("should cover an extent with triangles", t, func() {
+		grid, err := TriangleGrid([]float64{0, 0, 1, 1}, 0.5, Degrees)
+		So(err, ShouldBeNil)
+		So(len(grid), ShouldEqual, 13)
+	})
+
+	Convey("should reject unusable grids", t, func() {
+		_, err := SquareGrid([]float64{170, 0, -170, 10}, 1, Degrees)
+		So(err, ShouldNotBeNil)
+		_, err = HexGrid([]float64{0, 0, 1, 1}, 0, Degrees)
+		So(err, ShouldNotBeNil)
+		_, err = TriangleGrid([]float64{-180, -90, 180, 90}, 1, Meters)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("should bin points into cells", t, func() {
+		grid, err := SquareGrid([]float64{0, 0, 2, 1}, 1, Degrees)
+		So(err, ShouldBeNil)
+
+		places := FeatureCollection{}
+		for _, place := range []struct {
+			lat, lng float64
+			value    interface{}
+		}{
+			{0.5, 0.5, 1.0},
+			{0.6, 0.5, 3.0},
+			{0.5, 1.5, 10.0},
+			{0.5, 1, nil},
+		} {
+			point, err := NewPoint(place.lat, place.lng)
+			So(err, ShouldBeNil)
+			point.Properties = map[string]interface{}{"value": place.value}
+			places = append(places, point)
+		}
+
+		binned, err := Bin(grid, places,
+			Aggregate{Function: AggregateCount, As: "count"},
+			Aggregate{Function: AggregateMean, Property: "value", As: "mean"},
+		)
+		So(err, ShouldBeNil)
+		So(len(binned), ShouldEqual, 2)
+		// The point on the shared edge is binned only once.
+		So(binned[0].Properties["count"], ShouldEqual, 3)
+		So(binned[0].Properties["mean"], ShouldEqual, 2)
+		So(binned[1].Properties["count"], ShouldEqual, 1)
+		So(binned[1].Properties["mean"], ShouldEqual, 10)
+		So(grid[0].Properties, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,293 @@
+package terra
+
+import (
+	"sort"
+
+	"github.com/saleswise/errors/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// The containment hierarchy shares the index database too. It records every
+// pair of stored polygons where one contains the other, in both directions:
+//
+//	h\x00                                  the hierarchy is kept
+//	a\x00<store key>\x00<container key>    a polygon and one containing it
+//	b\x00<container key>\x00<store key>    a polygon and one it contains
+//
+// Direct parents and children are derived from these when queried.
+var (
+	hierarchyDeclaration = []byte("h\x00")
+	ancestorPrefix       = []byte("a\x00")
+	descendantPrefix     = []byte("b\x00")
+)
+
+func edgeKey(prefix []byte, from, to string) []byte {
+	return append(append(append(append([]byte{}, prefix...), from...), 0), to...)
+}
+
+func isPolygonal(feat *Feature) bool {
+	return feat.Geometry != nil && (feat.Type == "Polygon" || feat.Type == "MultiPolygon")
+}
+
+// CreateHierarchy has the store keep a graph of which of its polygons contain
+// which, built from the features already stored and updated as they change.
+// Polygons with equal geometries contain neither the other.
+func (g *Geostore) CreateHierarchy() error {
+
+	if g.hierarchy {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(hierarchyDeclaration, nil)
+	if err := g.deletePrefixes(batch, ancestorPrefix, descendantPrefix); err != nil {
+		return err
+	}
+	for key, entries := range g.entries {
+		if err := g.containmentEdges(batch, key, entries[0].feature, false); err != nil {
+			return err
+		}
+	}
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrap(err, "could not build containment hierarchy")
+	}
+
+	g.hierarchy = true
+
+	return nil
+}
+
+// DropHierarchy stops keeping the containment hierarchy and removes it.
+func (g *Geostore) DropHierarchy() error {
+
+	batch := new(leveldb.Batch)
+	batch.Delete(hierarchyDeclaration)
+	if err := g.deletePrefixes(batch, ancestorPrefix, descendantPrefix); err != nil {
+		return err
+	}
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrap(err, "could not drop containment hierarchy")
+	}
+
+	g.hierarchy = false
+
+	return nil
+}
+
+// containmentEdges adds to the batch an edge from the keyed polygon to each
+// stored polygon containing it and, if both is set, from each it contains.
+func (g *Geostore) containmentEdges(batch *leveldb.Batch, key string, feature *Feature, both bool) error {
+
+	if !isPolygonal(feature) {
+		return nil
+	}
+
+	rects, err := feature.Rects()
+	if err != nil {
+		return err
+	}
+
+	for other := range g.searchKeys(rects) {
+		if other == key || len(g.entries[other]) == 0 {
+			continue
+		}
+		candidate := g.entries[other][0].feature
+		if !isPolygonal(candidate) {
+			continue
+		}
+		contained, err := candidate.Geometry.Contains(feature.Geometry)
+		if err != nil {
+			return errors.Wrapf(err, "could not test whether %s contains %s", other, key)
+		}
+		containing, err := feature.Geometry.Contains(candidate.Geometry)
+		if err != nil {
+			return errors.Wrapf(err, "could not test whether %s contains %s", key, other)
+		}
+		switch {
+		case contained && containing:
+			continue
+		case contained:
+			batch.Put(edgeKey(ancestorPrefix, key, other), nil)
+			batch.Put(edgeKey(descendantPrefix, other, key), nil)
+		case containing && both:
+			batch.Put(edgeKey(ancestorPrefix, other, key), nil)
+			batch.Put(edgeKey(descendantPrefix, key, other), nil)
+		}
+	}
+
+	return nil
+}
+
+// updateHierarchy replaces the edges of the keyed feature, removing them if
+// the feature is nil.
+func (g *Geostore) updateHierarchy(key string, feature *Feature) error {
+
+	if !g.hierarchy {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	for _, prefix := range [][]byte{ancestorPrefix, descendantPrefix} {
+		reverse := descendantPrefix
+		if prefix[0] == descendantPrefix[0] {
+			reverse = ancestorPrefix
+		}
+		related, err := g.related(prefix, key)
+		if err != nil {
+			return err
+		}
+		for _, other := range related {
+			batch.Delete(edgeKey(prefix, key, other))
+			batch.Delete(edgeKey(reverse, other, key))
+		}
+	}
+	if feature != nil {
+		if err := g.containmentEdges(batch, key, feature, true); err != nil {
+			return err
+		}
+	}
+
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrapf(err, "could not update containment hierarchy for %s", key)
+	}
+
+	return nil
+}
+
+// related returns the keys the edges with the prefix lead to from the key.
+func (g *Geostore) related(prefix []byte, key string) ([]string, error) {
+
+	start := edgeKey(prefix, key, "")
+	var keys []string
+
+	iter := g.indexDB.NewIterator(util.BytesPrefix(start), nil)
+	defer iter.Release()
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()[len(start):]))
+	}
+	if err := iter.Error(); err != nil {
+		return nil, errors.Wrapf(err, "could not read containment hierarchy for %s", key)
+	}
+
+	return keys, nil
+}
+
+func (g *Geostore) hierarchyFeatures(keys []string) []*Feature {
+
+	features := []*Feature{}
+	for _, key := range keys {
+		if len(g.entries[key]) > 0 {
+			features = append(features, g.entries[key][0].feature)
+		}
+	}
+
+	return features
+}
+
+func (g *Geostore) checkHierarchy() error {
+	if !g.hierarchy {
+		return errors.New("The store keeps no containment hierarchy; create one first.")
+	}
+	return nil
+}
+
+// Ancestors returns every stored polygon containing the keyed one, nearest
+// first.
+func (g *Geostore) Ancestors(key []byte) ([]*Feature, error) {
+
+	if err := g.checkHierarchy(); err != nil {
+		return nil, err
+	}
+
+	ancestors, err := g.related(ancestorPrefix, string(key))
+	if err != nil {
+		return nil, err
+	}
+
+	// Nearer ancestors are contained by more of the others.
+	depths := make(map[string]int, len(ancestors))
+	for _, ancestor := range ancestors {
+		above, err := g.related(ancestorPrefix, ancestor)
+		if err != nil {
+			return nil, err
+		}
+		depths[ancestor] = len(above)
+	}
+	sort.SliceStable(ancestors, func(i, j int) bool {
+		return depths[ancestors[i]] > depths[ancestors[j]]
+	})
+
+	return g.hierarchyFeatures(ancestors), nil
+}
+
+// Parents returns the stored polygons directly containing the keyed one,
+// those containing it but none of its other ancestors, ordered by key.
+// Overlapping polygons may give it more than one.
+func (g *Geostore) Parents(key []byte) ([]*Feature, error) {
+
+	if err := g.checkHierarchy(); err != nil {
+		return nil, err
+	}
+
+	ancestors, err := g.related(ancestorPrefix, string(key))
+	if err != nil {
+		return nil, err
+	}
+
+	// An ancestor of another ancestor is not a parent.
+	indirect := make(map[string]bool)
+	for _, ancestor := range ancestors {
+		above, err := g.related(ancestorPrefix, ancestor)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range above {
+			indirect[a] = true
+		}
+	}
+
+	var parents []string
+	for _, ancestor := range ancestors {
+		if !indirect[ancestor] {
+			parents = append(parents, ancestor)
+		}
+	}
+
+	return g.hierarchyFeatures(parents), nil
+}
+
+// Children returns the stored polygons the keyed one directly contains, those
+// it contains that no other of its descendants does, ordered by key.
+func (g *Geostore) Children(key []byte) ([]*Feature, error) {
+
+	if err := g.checkHierarchy(); err != nil {
+		return nil, err
+	}
+
+	descendants, err := g.related(descendantPrefix, string(key))
+	if err != nil {
+		return nil, err
+	}
+
+	// A descendant of another descendant is not a child.
+	indirect := make(map[string]bool)
+	for _, descendant := range descendants {
+		below, err := g.related(descendantPrefix, descendant)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range below {
+			indirect[d] = true
+		}
+	}
+
+	var children []string
+	for _, descendant := range descendants {
+		if !indirect[descendant] {
+			children = append(children, descendant)
+		}
+	}
+
+	return g.hierarchyFeatures(children), nil
+}
@@ -0,0 +1,81 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHierarchy(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("given a store of nested polygons", t, func() {
+
+		store, err := OpenGeostore("./geostore-hierarchy")
+		So(err, ShouldBeNil)
+
+		add := func(id string, west, south, east, north float64) {
+			_, err := store.Add(rectangle(id, west, south, east, north))
+			So(err, ShouldBeNil)
+		}
+
+		ids := func(features []*Feature, err error) []string {
+			So(err, ShouldBeNil)
+			list := []string{}
+			for _, feat := range features {
+				list = append(list, feat.ID)
+			}
+			return list
+		}
+
+		add("park", -119.9, 37.5, -119.2, 38.2)
+		add("country", -125.0, 24.5, -66.9, 49.4)
+		So(store.CreateHierarchy(), ShouldBeNil)
+		add("state", -124.4, 32.5, -114.1, 42.0)
+		add("neighbor", -114.1, 35.0, -109.0, 42.0)
+
+		point, err := NewPoint(37.8, -119.5)
+		So(err, ShouldBeNil)
+		point.ID = "point"
+		_, err = store.Add(point)
+		So(err, ShouldBeNil)
+
+		Convey("should find direct and indirect containers", func() {
+			So(ids(store.Parents([]byte("park"))), ShouldResemble, []string{"state"})
+			So(ids(store.Ancestors([]byte("park"))), ShouldResemble, []string{"state", "country"})
+			So(ids(store.Parents([]byte("country"))), ShouldBeEmpty)
+			So(ids(store.Children([]byte("country"))), ShouldResemble, []string{"neighbor", "state"})
+			So(ids(store.Children([]byte("state"))), ShouldResemble, []string{"park"})
+			So(ids(store.Parents([]byte("point"))), ShouldBeEmpty)
+		})
+
+		Convey("should follow updates and removals", func() {
+			So(store.Remove([]byte("state")), ShouldBeNil)
+			So(ids(store.Parents([]byte("park"))), ShouldResemble, []string{"country"})
+			So(ids(store.Children([]byte("country"))), ShouldResemble, []string{"neighbor", "park"})
+
+			// Moving the park out of the country.
+			So(store.Update([]byte("park"), rectangle("park", -130.0, 37.5, -129.0, 38.2)), ShouldBeNil)
+			So(ids(store.Parents([]byte("park"))), ShouldBeEmpty)
+			So(ids(store.Children([]byte("country"))), ShouldResemble, []string{"neighbor"})
+		})
+
+		Convey("should persist the hierarchy", func() {
+			So(store.Close(), ShouldBeNil)
+			store, err = OpenGeostore("./geostore-hierarchy")
+			So(err, ShouldBeNil)
+			So(ids(store.Ancestors([]byte("park"))), ShouldResemble, []string{"state", "country"})
+
+			So(store.DropHierarchy(), ShouldBeNil)
+			_, err = store.Parents([]byte("park"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.DropHierarchy(), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
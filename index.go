@@ -0,0 +1,580 @@
+package terra
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Property indexes live in their own LevelDB beside the features, under three
+// kinds of key:
+//
+//	d\x00<path>                             a declared index
+//	i\x00<path>\x00<value><store key>       a feature holding a value
+//	r\x00<store key>\x00<entry key>         the entry, for removing it later
+//
+// Values are encoded so that their bytes sort as the values do: a tag for the
+// kind of value, then a number as its sign-adjusted IEEE bits, a string with
+// its zero bytes escaped and a terminator, or a boolean as a byte.
+var (
+	indexDeclarationPrefix = []byte("d\x00")
+	indexEntryPrefix       = []byte("i\x00")
+	indexReversePrefix     = []byte("r\x00")
+)
+
+const (
+	indexNumber byte = iota + 1
+	indexString
+	indexBool
+)
+
+// encodeIndexValue encodes a number, string or boolean, reporting false for
+// values of any other kind.
+func encodeIndexValue(value interface{}) ([]byte, bool) {
+
+	if number, ok := propertyNumber(value); ok {
+		if math.IsNaN(number) {
+			return nil, false
+		}
+		if number == 0 {
+			number = 0 // Fold negative zero.
+		}
+		bits := math.Float64bits(number)
+		if bits>>63 == 0 {
+			bits |= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		encoded := make([]byte, 9)
+		encoded[0] = indexNumber
+		binary.BigEndian.PutUint64(encoded[1:], bits)
+		return encoded, true
+	}
+
+	switch v := value.(type) {
+	case string:
+		encoded := []byte{indexString}
+		for i := 0; i < len(v); i++ {
+			if v[i] == 0 {
+				encoded = append(encoded, 0, 0xff)
+				continue
+			}
+			encoded = append(encoded, v[i])
+		}
+		return append(encoded, 0, 1), true
+	case bool:
+		if v {
+			return []byte{indexBool, 1}, true
+		}
+		return []byte{indexBool, 0}, true
+	}
+
+	return nil, false
+}
+
+// indexValueLength returns the length of the encoded value at the start of b.
+func indexValueLength(b []byte) int {
+
+	switch b[0] {
+	case indexNumber:
+		return 9
+	case indexBool:
+		return 2
+	}
+
+	for i := 1; i+1 < len(b); i++ {
+		if b[i] != 0 {
+			continue
+		}
+		if b[i+1] == 1 {
+			return i + 2
+		}
+		i++ // An escaped zero byte.
+	}
+	return len(b)
+}
+
+// indexValues returns the encoded values a property is indexed under: its
+// own, or each of its elements if it is an array.
+func indexValues(value interface{}) [][]byte {
+
+	var values [][]byte
+	if v := reflect.ValueOf(value); value != nil && v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if encoded, ok := encodeIndexValue(v.Index(i).Interface()); ok {
+				values = append(values, encoded)
+			}
+		}
+		return values
+	}
+
+	if encoded, ok := encodeIndexValue(value); ok {
+		values = append(values, encoded)
+	}
+	return values
+}
+
+func indexPathPrefix(path string) []byte {
+	return append(append(append([]byte{}, indexEntryPrefix...), path...), 0)
+}
+
+func indexReverseKeyPrefix(key string) []byte {
+	return append(append(append([]byte{}, indexReversePrefix...), key...), 0)
+}
+
+// openIndexes opens the index database and loads the declared indexes and the
+// schema.
+func (g *Geostore) openIndexes(directory string) error {
+
+	var err error
+	g.indexDB, err = leveldb.OpenFile(directory, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to open the directory: %s.", directory)
+	}
+
+	g.indexed = make(map[string]bool)
+	g.textFields = make(map[string]bool)
+
+	for prefix, declared := range map[string]map[string]bool{
+		string(indexDeclarationPrefix): g.indexed,
+		string(textFieldPrefix):        g.textFields,
+	} {
+		iter := g.indexDB.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+		for iter.Next() {
+			declared[string(iter.Key()[len(prefix):])] = true
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return errors.Wrap(err, "could not read index declarations")
+		}
+	}
+
+	if g.hierarchy, err = g.indexDB.Has(hierarchyDeclaration, nil); err != nil {
+		return errors.Wrap(err, "could not read index declarations")
+	}
+
+	return g.loadSchema()
+}
+
+// CreateIndex declares an index on the property at a path, such as
+// "iso_a3" or "address.city", and builds it from the features already stored.
+// Numbers, strings and booleans are indexed, and each element of an array.
+func (g *Geostore) CreateIndex(path string) error {
+
+	if _, err := parsePropertyPath(path); err != nil {
+		return err
+	}
+	if g.indexed[path] {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(append(append([]byte{}, indexDeclarationPrefix...), path...), nil)
+	for key, entries := range g.entries {
+		putIndexEntries(batch, path, key, entries[0].feature)
+	}
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrapf(err, "could not build index on %s", path)
+	}
+
+	g.indexed[path] = true
+
+	return nil
+}
+
+// DropIndex removes the index on the property at a path.
+func (g *Geostore) DropIndex(path string) error {
+
+	if !g.indexed[path] {
+		return errors.Newf("There is no index on %s to drop.", path)
+	}
+
+	prefix := indexPathPrefix(path)
+	batch := new(leveldb.Batch)
+	batch.Delete(append(append([]byte{}, indexDeclarationPrefix...), path...))
+
+	iter := g.indexDB.NewIterator(util.BytesPrefix(indexReversePrefix), nil)
+	for iter.Next() {
+		if bytes.HasPrefix(iter.Value(), prefix) {
+			batch.Delete(append([]byte{}, iter.Key()...))
+			batch.Delete(append([]byte{}, iter.Value()...))
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return errors.Wrapf(err, "could not read index on %s", path)
+	}
+
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrapf(err, "could not drop index on %s", path)
+	}
+
+	delete(g.indexed, path)
+
+	return nil
+}
+
+// Indexes returns the paths of the declared property indexes.
+func (g *Geostore) Indexes() []string {
+
+	paths := make([]string, 0, len(g.indexed))
+	for path := range g.indexed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+func putIndexEntries(batch *leveldb.Batch, path, key string, feature *Feature) {
+	for _, value := range indexValues(feature.Property(path)) {
+		entry := append(append(indexPathPrefix(path), value...), key...)
+		batch.Put(entry, nil)
+		batch.Put(append(indexReverseKeyPrefix(key), entry...), entry)
+	}
+}
+
+// deleteIndexEntries adds the removal of every index entry of the keyed
+// feature to the batch.
+func (g *Geostore) deleteIndexEntries(batch *leveldb.Batch, key string) error {
+
+	iter := g.indexDB.NewIterator(util.BytesPrefix(indexReverseKeyPrefix(key)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		batch.Delete(append([]byte{}, iter.Key()...))
+		batch.Delete(append([]byte{}, iter.Value()...))
+	}
+	if err := iter.Error(); err != nil {
+		return errors.Wrapf(err, "could not read index entries of %s", key)
+	}
+
+	return nil
+}
+
+// indexProperties replaces the property and text index entries of the keyed
+// feature.
+func (g *Geostore) indexProperties(key string, feature *Feature) error {
+
+	if len(g.indexed) == 0 && len(g.textFields) == 0 {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	if err := g.deleteIndexEntries(batch, key); err != nil {
+		return err
+	}
+	if err := g.deleteTextEntries(batch, key); err != nil {
+		return err
+	}
+	if feature != nil {
+		for path := range g.indexed {
+			putIndexEntries(batch, path, key, feature)
+		}
+		g.putTextEntries(batch, key, feature)
+	}
+
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrapf(err, "could not index properties of %s", key)
+	}
+
+	return nil
+}
+
+// clearIndexes removes every index entry, hierarchy edge and cell, keeping
+// the declarations.
+func (g *Geostore) clearIndexes() error {
+
+	batch := new(leveldb.Batch)
+	if err := g.deletePrefixes(batch, indexEntryPrefix, indexReversePrefix, textPostingPrefix, textReversePrefix,
+		ancestorPrefix, descendantPrefix, cellPrefix, cellReversePrefix); err != nil {
+		return err
+	}
+
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrap(err, "could not clear indexes")
+	}
+
+	return nil
+}
+
+// Condition restricts the property at a path to a value, or to a range of
+// values when Value is nil. Min and Max bound the range inclusively, and
+// either may be nil to leave it open. Values are numbers, strings or
+// booleans, and a range only matches values of the same kind as its bounds.
+// A property holding an array matches if any element does.
+type Condition struct {
+	Path     string
+	Value    interface{}
+	Min, Max interface{}
+}
+
+// Equal is the condition that the property at the path has the value.
+func Equal(path string, value interface{}) Condition {
+	return Condition{Path: path, Value: value}
+}
+
+// Between is the condition that the property at the path lies between min and
+// max inclusive, either of which may be nil.
+func Between(path string, min, max interface{}) Condition {
+	return Condition{Path: path, Min: min, Max: max}
+}
+
+// bounds returns the index keys the condition's entries lie between.
+func (c Condition) bounds() (*util.Range, error) {
+
+	prefix := indexPathPrefix(c.Path)
+
+	if c.Value != nil {
+		value, ok := encodeIndexValue(c.Value)
+		if !ok {
+			return nil, errors.Newf("The value %#v for %s cannot be looked up in an index.", c.Value, c.Path)
+		}
+		return util.BytesPrefix(append(prefix, value...)), nil
+	}
+
+	if c.Min == nil && c.Max == nil {
+		return nil, errors.Newf("The condition on %s needs a value or a bound.", c.Path)
+	}
+
+	var min, max []byte
+	for _, bound := range []struct {
+		value   interface{}
+		encoded *[]byte
+	}{{c.Min, &min}, {c.Max, &max}} {
+		if bound.value == nil {
+			continue
+		}
+		encoded, ok := encodeIndexValue(bound.value)
+		if !ok {
+			return nil, errors.Newf("The bound %#v for %s cannot be looked up in an index.", bound.value, c.Path)
+		}
+		*bound.encoded = encoded
+	}
+	if min != nil && max != nil && min[0] != max[0] {
+		return nil, errors.Newf("The bounds %#v and %#v for %s are of different kinds.", c.Min, c.Max, c.Path)
+	}
+
+	r := &util.Range{}
+	if min != nil {
+		r.Start = append(append([]byte{}, prefix...), min...)
+	} else {
+		r.Start = append(append([]byte{}, prefix...), max[0])
+	}
+	if max != nil {
+		r.Limit = util.BytesPrefix(append(append([]byte{}, prefix...), max...)).Limit
+	} else {
+		r.Limit = append(append([]byte{}, prefix...), min[0]+1)
+	}
+
+	return r, nil
+}
+
+// matches reports whether the feature satisfies the condition.
+func (c Condition) matches(feat *Feature) bool {
+
+	value := feat.Property(c.Path)
+	if v := reflect.ValueOf(value); value != nil && v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if c.matchesValue(v.Index(i).Interface()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return c.matchesValue(value)
+}
+
+func (c Condition) matchesValue(value interface{}) bool {
+
+	if value == nil {
+		return false
+	}
+	if c.Value != nil {
+		return propertiesEqual(value, c.Value)
+	}
+	if c.Min != nil && (propertyRank(value) != propertyRank(c.Min) || compareProperties(value, c.Min) < 0) {
+		return false
+	}
+	if c.Max != nil && (propertyRank(value) != propertyRank(c.Max) || compareProperties(value, c.Max) > 0) {
+		return false
+	}
+
+	return true
+}
+
+// lookup returns the store keys the index on the condition's path holds for
+// its values.
+func (g *Geostore) lookup(c Condition) (map[string]bool, error) {
+
+	if !g.indexed[c.Path] {
+		return nil, errors.Newf("There is no index on %s.", c.Path)
+	}
+
+	r, err := c.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := len(indexPathPrefix(c.Path))
+	keys := make(map[string]bool)
+
+	iter := g.indexDB.NewIterator(r, nil)
+	defer iter.Release()
+	for iter.Next() {
+		rest := iter.Key()[prefix:]
+		keys[string(rest[indexValueLength(rest):])] = true
+	}
+	if err := iter.Error(); err != nil {
+		return nil, errors.Wrapf(err, "could not read index on %s", c.Path)
+	}
+
+	return keys, nil
+}
+
+// features returns the stored features with the keys, ordered by key.
+func (g *Geostore) features(keys map[string]bool) []*Feature {
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		if len(g.entries[key]) > 0 {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+
+	features := make([]*Feature, len(sorted))
+	for i, key := range sorted {
+		features[i] = g.entries[key][0].feature
+	}
+
+	return features
+}
+
+// FindEqual returns the stored features whose indexed property has the value,
+// ordered by key.
+func (g *Geostore) FindEqual(path string, value interface{}) ([]*Feature, error) {
+
+	keys, err := g.lookup(Equal(path, value))
+	if err != nil {
+		return nil, err
+	}
+
+	return g.features(keys), nil
+}
+
+// FindRange returns the stored features whose indexed property lies between
+// min and max inclusive, either of which may be nil, ordered by key.
+func (g *Geostore) FindRange(path string, min, max interface{}) ([]*Feature, error) {
+
+	keys, err := g.lookup(Between(path, min, max))
+	if err != nil {
+		return nil, err
+	}
+
+	return g.features(keys), nil
+}
+
+// Query selects stored features by property and location. Every condition
+// and spatial restriction given must hold.
+type Query struct {
+	Conditions []Condition
+	// BBox, if set, restricts results to features whose bounding boxes
+	// intersect it.
+	BBox []float64
+	// Intersects, if set, restricts results to features whose geometries
+	// intersect its geometry.
+	Intersects *Feature
+}
+
+// Query returns the stored features matching the query, ordered by key.
+// Conditions on indexed properties and the spatial restrictions each narrow
+// the candidates, which are then checked against every condition, so that
+// conditions on properties without an index are applied as filters.
+func (g *Geostore) Query(q Query) ([]*Feature, error) {
+
+	var candidates map[string]bool
+	restrict := func(keys map[string]bool) {
+		if candidates == nil {
+			candidates = keys
+			return
+		}
+		for key := range candidates {
+			if !keys[key] {
+				delete(candidates, key)
+			}
+		}
+	}
+
+	for _, c := range q.Conditions {
+		if c.Value == nil && c.Min == nil && c.Max == nil {
+			return nil, errors.Newf("The condition on %s needs a value or a bound.", c.Path)
+		}
+		if !g.indexed[c.Path] {
+			continue
+		}
+		keys, err := g.lookup(c)
+		if err != nil {
+			return nil, err
+		}
+		restrict(keys)
+	}
+
+	if q.BBox != nil {
+		rects, err := bboxToRects(q.BBox)
+		if err != nil {
+			return nil, err
+		}
+		restrict(g.searchKeys(rects))
+	}
+
+	var prepared *geos.PGeometry
+	if q.Intersects != nil {
+		rects, err := q.Intersects.Rects()
+		if err != nil {
+			return nil, err
+		}
+		restrict(g.searchKeys(rects))
+		prepared = q.Intersects.Geometry.Prepare()
+	}
+
+	if candidates == nil {
+		candidates = make(map[string]bool, len(g.entries))
+		for key := range g.entries {
+			candidates[key] = true
+		}
+	}
+
+	list := []*Feature{}
+	for _, feat := range g.features(candidates) {
+		matched := true
+		for _, c := range q.Conditions {
+			if !c.matches(feat) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if prepared != nil {
+			ok, err := prepared.Intersects(feat.Geometry)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not test feature against query geometry")
+			}
+			if !ok {
+				continue
+			}
+		}
+		list = append(list, feat)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,155 @@
+package terra
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIndex(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should encode values so their bytes sort as they do", t, func() {
+		values := []interface{}{-1e9, -2.5, -1, 0, 0.5, 3, 1e12, "", "a", "a\x00b", "ab", "b", false, true}
+		encoded := make([][]byte, len(values))
+		for i, value := range values {
+			var ok bool
+			encoded[i], ok = encodeIndexValue(value)
+			So(ok, ShouldBeTrue)
+			So(indexValueLength(append(encoded[i], "key"...)), ShouldEqual, len(encoded[i]))
+		}
+		So(sort.SliceIsSorted(encoded, func(i, j int) bool {
+			return bytes.Compare(encoded[i], encoded[j]) < 0
+		}), ShouldBeTrue)
+
+		negative, _ := encodeIndexValue(-0.0)
+		zero, _ := encodeIndexValue(0)
+		So(negative, ShouldResemble, zero)
+
+		_, ok := encodeIndexValue(map[string]interface{}{})
+		So(ok, ShouldBeFalse)
+		So(len(indexValues([]interface{}{"a", 1, nil})), ShouldEqual, 2)
+	})
+
+	Convey("should match conditions against features", t, func() {
+		feat := &Feature{ID: "ABW", Properties: map[string]interface{}{
+			"scalerank": float64(3), "iso_a3": "ABW", "tags": []interface{}{"island", "dutch"},
+		}}
+		So(Equal("scalerank", 3).matches(feat), ShouldBeTrue)
+		So(Between("scalerank", nil, 3).matches(feat), ShouldBeTrue)
+		So(Between("scalerank", 4, nil).matches(feat), ShouldBeFalse)
+		So(Between("iso_a3", 1, 5).matches(feat), ShouldBeFalse)
+		So(Equal("tags", "dutch").matches(feat), ShouldBeTrue)
+		So(Equal("missing", "x").matches(feat), ShouldBeFalse)
+
+		_, err := Between("scalerank", 1, "z").bounds()
+		So(err, ShouldNotBeNil)
+		_, err = Condition{Path: "scalerank"}.bounds()
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("given a store with indexes", t, func() {
+
+		store, err := OpenGeostore("./geostore-index")
+		So(err, ShouldBeNil)
+		So(store.CreateIndex("iso_a3"), ShouldBeNil)
+
+		places := []struct {
+			id        string
+			lat, lng  float64
+			iso       string
+			scalerank int
+		}{
+			{"oranjestad", 12.52, -70.03, "ABW", 3},
+			{"the-valley", 18.22, -63.05, "AIA", 1},
+			{"willemstad", 12.11, -68.93, "CUW", 5},
+			{"amsterdam", 52.37, 4.90, "NLD", 0},
+		}
+		for _, place := range places {
+			point, err := NewPoint(place.lat, place.lng)
+			So(err, ShouldBeNil)
+			point.ID = place.id
+			point.Properties = map[string]interface{}{"iso_a3": place.iso, "scalerank": place.scalerank}
+			_, err = store.Add(point)
+			So(err, ShouldBeNil)
+		}
+
+		ids := func(features []*Feature) []string {
+			list := []string{}
+			for _, feat := range features {
+				list = append(list, feat.ID)
+			}
+			return list
+		}
+
+		Convey("should find exact values and build indexes from stored features", func() {
+			found, err := store.FindEqual("iso_a3", "ABW")
+			So(err, ShouldBeNil)
+			So(ids(found), ShouldResemble, []string{"oranjestad"})
+
+			_, err = store.FindEqual("scalerank", 3)
+			So(err, ShouldNotBeNil)
+
+			So(store.CreateIndex("scalerank"), ShouldBeNil)
+			So(store.Indexes(), ShouldResemble, []string{"iso_a3", "scalerank"})
+			found, err = store.FindRange("scalerank", nil, 3)
+			So(err, ShouldBeNil)
+			So(ids(found), ShouldResemble, []string{"amsterdam", "oranjestad", "the-valley"})
+		})
+
+		Convey("should combine property and spatial restrictions", func() {
+			So(store.CreateIndex("scalerank"), ShouldBeNil)
+			found, err := store.Query(Query{
+				Conditions: []Condition{Between("scalerank", nil, 3)},
+				BBox:       []float64{-75, 10, -60, 20},
+			})
+			So(err, ShouldBeNil)
+			So(ids(found), ShouldResemble, []string{"oranjestad", "the-valley"})
+
+			found, err = store.Query(Query{Conditions: []Condition{Equal("iso_a3", "CUW"), Between("scalerank", 4, nil)}})
+			So(err, ShouldBeNil)
+			So(ids(found), ShouldResemble, []string{"willemstad"})
+		})
+
+		Convey("should keep indexes in step with updates and removals", func() {
+			point, err := NewPoint(12.52, -70.03)
+			So(err, ShouldBeNil)
+			point.ID = "oranjestad"
+			point.Properties = map[string]interface{}{"iso_a3": "NLD"}
+			So(store.Update([]byte("oranjestad"), point), ShouldBeNil)
+
+			found, err := store.FindEqual("iso_a3", "ABW")
+			So(err, ShouldBeNil)
+			So(len(found), ShouldEqual, 0)
+			found, err = store.FindEqual("iso_a3", "NLD")
+			So(err, ShouldBeNil)
+			So(ids(found), ShouldResemble, []string{"amsterdam", "oranjestad"})
+
+			So(store.Remove([]byte("amsterdam")), ShouldBeNil)
+			found, err = store.FindEqual("iso_a3", "NLD")
+			So(err, ShouldBeNil)
+			So(ids(found), ShouldResemble, []string{"oranjestad"})
+		})
+
+		Convey("should keep declarations when reopened", func() {
+			So(store.Close(), ShouldBeNil)
+			store, err = OpenGeostore("./geostore-index")
+			So(err, ShouldBeNil)
+			So(store.Indexes(), ShouldResemble, []string{"iso_a3"})
+			found, err := store.FindEqual("iso_a3", "AIA")
+			So(err, ShouldBeNil)
+			So(ids(found), ShouldResemble, []string{"the-valley"})
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			for _, path := range store.Indexes() {
+				So(store.DropIndex(path), ShouldBeNil)
+			}
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package terra
+
+import (
+	"github.com/saleswise/errors/errors"
+)
+
+// IngestPolicy decides what Add and Update do with invalid geometries.
+type IngestPolicy int
+
+const (
+	// AcceptInvalid stores features without checking their validity.
+	AcceptInvalid IngestPolicy = iota
+	// RejectInvalid fails to store a feature that is not valid.
+	RejectInvalid
+	// RepairInvalid stores the MakeValid repair of an invalid feature.
+	RepairInvalid
+	// FlagInvalid stores an invalid feature as it is, recording why it is
+	// invalid under the InvalidMember foreign member.
+	FlagInvalid
+)
+
+// InvalidMember is the foreign member under which FlagInvalid records the
+// reason and location a feature is invalid.
+const InvalidMember = "invalid"
+
+// SetIngestPolicy sets how Add and Update treat invalid geometries. The
+// default is AcceptInvalid.
+func (g *Geostore) SetIngestPolicy(policy IngestPolicy) {
+	g.policy = policy
+}
+
+// Flagged returns the stored features that FlagInvalid marked as invalid.
+func (g *Geostore) Flagged() []*Feature {
+	features := []*Feature{}
+	for _, entries := range g.entries {
+		if _, ok := entries[0].feature.ForeignMembers[InvalidMember]; ok {
+			features = append(features, entries[0].feature)
+		}
+	}
+	return features
+}
+
+// prepare readies a feature for storage and checks the result against the
+// schema, as preparing it may change its geometry type, such as a Polygon cut
+// at the antimeridian into a MultiPolygon.
+func (g *Geostore) prepare(feature *Feature) (*Feature, error) {
+
+	feature, err := g.normalize(feature)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.schema != nil {
+		if err := g.schema.Validate(feature); err != nil {
+			return nil, err
+		}
+	}
+
+	return feature, nil
+}
+
+// normalize reprojects a feature to WGS84, cuts it at the antimeridian and
+// applies the ingest policy.
+func (g *Geostore) normalize(feature *Feature) (*Feature, error) {
+
+	if !isWGS84(feature.crs()) {
+		var err error
+		if feature, err = feature.Reproject(WGS84); err != nil {
+			return nil, err
+		}
+	}
+
+	feature, err := feature.CutAntimeridian()
+	if err != nil {
+		return nil, err
+	}
+
+	if g.policy == AcceptInvalid {
+		return feature, nil
+	}
+
+	valid, invalid, err := feature.IsValid()
+	if err != nil {
+		return nil, err
+	}
+
+	switch g.policy {
+	case RejectInvalid:
+		if !valid {
+			return nil, errors.Newf("Feature %s is invalid: %s.", feature.ID, invalid)
+		}
+	case RepairInvalid:
+		if !valid {
+			return feature.MakeValid()
+		}
+	case FlagInvalid:
+		_, flagged := feature.ForeignMembers[InvalidMember]
+		if valid == !flagged {
+			return feature, nil
+		}
+		members := make(map[string]interface{}, len(feature.ForeignMembers)+1)
+		for key, value := range feature.ForeignMembers {
+			members[key] = value
+		}
+		delete(members, InvalidMember)
+		if !valid {
+			members[InvalidMember] = map[string]interface{}{
+				"reason":   invalid.Reason,
+				"location": []interface{}{invalid.Location.X, invalid.Location.Y},
+			}
+		}
+		copied := *feature
+		copied.ForeignMembers = members
+		return &copied, nil
+	}
+
+	return feature, nil
+}
@@ -0,0 +1,285 @@
+package terra
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/dhconnelly/rtreego"
+	"github.com/saleswise/errors/errors"
+)
+
+// FeatureSource is a set of features a spatial join reads from: a *Geostore,
+// whose features are read in key order, or a FeatureCollection.
+type FeatureSource interface {
+	sourceFeatures() ([]*Feature, error)
+}
+
+func (g *Geostore) sourceFeatures() ([]*Feature, error) {
+
+	keys := make(map[string]bool, len(g.entries))
+	for key := range g.entries {
+		keys[key] = true
+	}
+
+	return g.features(keys), nil
+}
+
+func (coll FeatureCollection) sourceFeatures() ([]*Feature, error) {
+
+	if coll.isWGS84() {
+		return coll, nil
+	}
+
+	reprojected, err := coll.Reproject(WGS84)
+	if err != nil {
+		return nil, err
+	}
+
+	return reprojected, nil
+}
+
+// JoinPredicate is the spatial relation pairing a left feature with a right
+// one in a join.
+type JoinPredicate int
+
+const (
+	// JoinIntersects pairs features which share any point.
+	JoinIntersects JoinPredicate = iota
+	// JoinWithin pairs a left feature with the right features it lies within.
+	JoinWithin
+	// JoinContains pairs a left feature with the right features it contains.
+	JoinContains
+	// JoinNearest pairs a left feature with the single nearest right feature
+	// within the join's MaxDistance.
+	JoinNearest
+)
+
+// JoinType decides what becomes of left features matching nothing.
+type JoinType int
+
+const (
+	// InnerJoin drops left features matching nothing.
+	InnerJoin JoinType = iota
+	// LeftJoin keeps left features matching nothing, with their own
+	// properties only.
+	LeftJoin
+)
+
+// Join describes a spatial join. The zero value is an inner join on
+// intersection, merging right properties into left ones under their own
+// names.
+type Join struct {
+	Predicate JoinPredicate
+	// MaxDistance is how far, in meters, JoinNearest looks for a right
+	// feature. It must be positive for JoinNearest.
+	MaxDistance float64
+	Type        JoinType
+	// LeftPrefix and RightPrefix are put before the names of the properties
+	// taken from either side. Where a right name still equals a left one, the
+	// left value is kept.
+	LeftPrefix  string
+	RightPrefix string
+	// DistanceProperty, if set, names a property holding the distance in
+	// meters to the nearest right feature, for JoinNearest.
+	DistanceProperty string
+}
+
+// joinIndex is an rtree over the right features of a join.
+type joinIndex struct {
+	tree     *rtreego.Rtree
+	features []*Feature
+}
+
+func newJoinIndex(features []*Feature) (*joinIndex, error) {
+
+	index := &joinIndex{tree: rtreego.NewTree(2, 25, 50), features: features}
+	for i, feat := range features {
+		rects, err := feat.Rects()
+		if err != nil {
+			return nil, err
+		}
+		for _, rect := range rects {
+			index.tree.Insert(&treeEntry{key: strconv.Itoa(i), feature: feat, rect: rect})
+		}
+	}
+
+	return index, nil
+}
+
+// search returns the positions of the features with a rectangle intersecting
+// any of the rectangles, in ascending order.
+func (index *joinIndex) search(rects []*rtreego.Rect) []int {
+
+	seen := make([]bool, len(index.features))
+	for _, rect := range rects {
+		for _, spatial := range index.tree.SearchIntersect(rect) {
+			i, _ := strconv.Atoi(spatial.(*treeEntry).key)
+			seen[i] = true
+		}
+	}
+
+	positions := []int{}
+	for i, ok := range seen {
+		if ok {
+			positions = append(positions, i)
+		}
+	}
+
+	return positions
+}
+
+// match returns the right features the left one pairs with, and their
+// distances for JoinNearest.
+func (j Join) match(index *joinIndex, left *Feature) ([]*Feature, []float64, error) {
+
+	if j.Predicate == JoinNearest {
+		bbox, err := left.BoundingBox()
+		if err != nil {
+			return nil, nil, err
+		}
+		rects, err := bboxToRects(expandBBox(bbox, j.MaxDistance))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var (
+			nearest *Feature
+			best    = math.Inf(1)
+		)
+		for _, i := range index.search(rects) {
+			distance, err := index.features[i].Distance(left)
+			if err != nil {
+				return nil, nil, err
+			}
+			if distance <= j.MaxDistance && distance < best {
+				nearest, best = index.features[i], distance
+			}
+		}
+		if nearest == nil {
+			return nil, nil, nil
+		}
+		return []*Feature{nearest}, []float64{best}, nil
+	}
+
+	rects, err := left.Rects()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prepared := left.Geometry.Prepare()
+	matches := []*Feature{}
+	for _, i := range index.search(rects) {
+		right := index.features[i]
+		var ok bool
+		switch j.Predicate {
+		case JoinWithin:
+			ok, err = prepared.Within(right.Geometry)
+		case JoinContains:
+			ok, err = prepared.Contains(right.Geometry)
+		default:
+			ok, err = prepared.Intersects(right.Geometry)
+		}
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "could not test join predicate")
+		}
+		if ok {
+			matches = append(matches, right)
+		}
+	}
+
+	return matches, nil, nil
+}
+
+// merge returns a copy of the left feature carrying the properties of both.
+func (j Join) merge(left, right *Feature, distance float64) *Feature {
+
+	merged := NewFeature()
+	merged.ID = left.ID
+	merged.Type = left.Type
+	merged.Geometry = left.Geometry
+	merged.Properties = make(map[string]interface{}, len(left.Properties))
+
+	for name, value := range left.Properties {
+		merged.Properties[j.LeftPrefix+name] = value
+	}
+	if right == nil {
+		return merged
+	}
+	for name, value := range right.Properties {
+		if _, taken := merged.Properties[j.RightPrefix+name]; !taken {
+			merged.Properties[j.RightPrefix+name] = value
+		}
+	}
+	if j.Predicate == JoinNearest && j.DistanceProperty != "" {
+		merged.Properties[j.DistanceProperty] = distance
+	}
+
+	return merged
+}
+
+// SpatialJoin pairs each left feature with the right features the join's
+// predicate holds for, returning in WGS84 a feature for each pair, with the
+// left geometry and the properties of both. Features follow the order of the
+// left features, then of the right ones. A left feature matching once keeps
+// its ID, while one matching several times has the right ID appended to its
+// own after a slash, so that each result can be stored.
+func SpatialJoin(left, right FeatureSource, join Join) (FeatureCollection, error) {
+
+	if join.Predicate == JoinNearest && join.MaxDistance <= 0 {
+		return nil, errors.Newf("The maximum distance %g of a nearest join is not positive.", join.MaxDistance)
+	}
+
+	lefts, err := left.sourceFeatures()
+	if err != nil {
+		return nil, err
+	}
+	rights, err := right.sourceFeatures()
+	if err != nil {
+		return nil, err
+	}
+	index, err := newJoinIndex(rights)
+	if err != nil {
+		return nil, err
+	}
+
+	joined := FeatureCollection{}
+	for _, feat := range lefts {
+		if feat.Geometry == nil {
+			continue
+		}
+
+		matches, distances, err := join.match(index, feat)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 && join.Type == LeftJoin {
+			joined = append(joined, join.merge(feat, nil, 0))
+		}
+		for i, match := range matches {
+			distance := 0.0
+			if distances != nil {
+				distance = distances[i]
+			}
+			merged := join.merge(feat, match, distance)
+			if len(matches) > 1 {
+				merged.ID = feat.ID + "/" + match.ID
+			}
+			joined = append(joined, merged)
+		}
+	}
+
+	return joined, nil
+}
+
+// AddSpatialJoin adds the features SpatialJoin returns to the store, returning
+// their keys.
+func (g *Geostore) AddSpatialJoin(left, right FeatureSource, join Join) ([]string, error) {
+
+	joined, err := SpatialJoin(left, right, join)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Add(joined...)
+}
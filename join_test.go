@@ -0,0 +1,120 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSpatialJoin(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("given polygons and points", t, func() {
+
+		square := func(id, name string, west, south, east, north float64) *Feature {
+			polygon := rectangle(id, west, south, east, north)
+			polygon.Properties = map[string]interface{}{"name": name}
+			return polygon
+		}
+		point := func(id, name string, lat, lng float64) *Feature {
+			point, err := NewPoint(lat, lng)
+			So(err, ShouldBeNil)
+			point.ID = id
+			point.Properties = map[string]interface{}{"name": name}
+			return point
+		}
+
+		areas := FeatureCollection{
+			square("west", "West", -10, -10, 0, 10),
+			square("north", "North", -10, 0, 10, 10),
+		}
+		places := FeatureCollection{
+			point("a", "A", 5, -5),
+			point("b", "B", -5, -5),
+			point("c", "C", -5, 5),
+		}
+
+		ids := func(coll FeatureCollection) []string {
+			list := []string{}
+			for _, feat := range coll {
+				list = append(list, feat.ID)
+			}
+			return list
+		}
+
+		Convey("should pair points with the areas they lie within", func() {
+			joined, err := SpatialJoin(places, areas, Join{Predicate: JoinWithin, RightPrefix: "area_"})
+			So(err, ShouldBeNil)
+			So(ids(joined), ShouldResemble, []string{"a/west", "a/north", "b"})
+			So(joined[0].Properties["name"], ShouldEqual, "A")
+			So(joined[0].Properties["area_name"], ShouldEqual, "West")
+			So(joined[1].Properties["area_name"], ShouldEqual, "North")
+		})
+
+		Convey("should keep unmatched features in a left join", func() {
+			joined, err := SpatialJoin(places, areas, Join{Predicate: JoinWithin, Type: LeftJoin})
+			So(err, ShouldBeNil)
+			So(ids(joined), ShouldResemble, []string{"a/west", "a/north", "b", "c"})
+			So(joined[3].Properties, ShouldResemble, map[string]interface{}{"name": "C"})
+			// Without prefixes the left value wins.
+			So(joined[0].Properties["name"], ShouldEqual, "A")
+		})
+
+		Convey("should pair areas with the points they contain", func() {
+			joined, err := SpatialJoin(areas, places, Join{Predicate: JoinContains, LeftPrefix: "area_"})
+			So(err, ShouldBeNil)
+			So(ids(joined), ShouldResemble, []string{"west/a", "west/b", "north"})
+			So(joined[2].Properties, ShouldResemble, map[string]interface{}{"area_name": "North", "name": "A"})
+		})
+
+		Convey("should pair features with the nearest within a distance", func() {
+			stations := FeatureCollection{
+				point("far", "Far", 5.3, -5),
+				point("near", "Near", 5.1, -5),
+			}
+			joined, err := SpatialJoin(places, stations, Join{
+				Predicate:        JoinNearest,
+				MaxDistance:      50000,
+				Type:             LeftJoin,
+				RightPrefix:      "station_",
+				DistanceProperty: "distance",
+			})
+			So(err, ShouldBeNil)
+			So(ids(joined), ShouldResemble, []string{"a", "b", "c"})
+			So(joined[0].Properties["station_name"], ShouldEqual, "Near")
+			So(joined[0].Properties["distance"], ShouldAlmostEqual, 11057, 10)
+			So(joined[1].Properties["station_name"], ShouldBeNil)
+
+			joined, err = SpatialJoin(stations, places, Join{Predicate: JoinNearest, MaxDistance: 20000})
+			So(err, ShouldBeNil)
+			So(ids(joined), ShouldResemble, []string{"near"})
+			So(joined[0].Properties["name"], ShouldEqual, "Near")
+
+			_, err = SpatialJoin(places, stations, Join{Predicate: JoinNearest})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("should read from and write into stores", func() {
+			source, err := OpenGeostore("./geostore-join-source")
+			So(err, ShouldBeNil)
+			target, err := OpenGeostore("./geostore-join-target")
+			So(err, ShouldBeNil)
+
+			_, err = source.Add(areas...)
+			So(err, ShouldBeNil)
+			keys, err := target.AddSpatialJoin(places, source, Join{Predicate: JoinIntersects, RightPrefix: "area_"})
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, []string{"a/north", "a/west", "b"})
+
+			feat, err := target.Get([]byte("b"))
+			So(err, ShouldBeNil)
+			So(feat.Properties["area_name"], ShouldEqual, "West")
+
+			So(source.Clear(), ShouldBeNil)
+			So(source.Close(), ShouldBeNil)
+			So(target.Clear(), ShouldBeNil)
+			So(target.Close(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,372 @@
+package terra
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// Struct fields map to features by their terra tag. A tag names the property
+// a field holds, which may be a path such as "address.city"; untagged exported
+// fields use their own name and "-" skips a field. The options ",id" and
+// ",geometry" mark the fields holding the feature ID, a string or integer,
+// and its geometry, a *geos.Geometry. The option ",omitempty" leaves a zero
+// valued field out of the properties.
+//
+//	type Park struct {
+//		Code     string         `terra:",id"`
+//		Name     string         `terra:"name"`
+//		Region   string         `terra:"region,omitempty"`
+//		Boundary *geos.Geometry `terra:",geometry"`
+//	}
+
+type structField struct {
+	index     []int
+	path      string
+	omitEmpty bool
+}
+
+type structFields struct {
+	id, geometry []int
+	properties   []structField
+}
+
+var (
+	structFieldCache sync.Map
+	geometryType     = reflect.TypeOf((*geos.Geometry)(nil))
+	timeType         = reflect.TypeOf(time.Time{})
+)
+
+func typeFields(t reflect.Type) (*structFields, error) {
+
+	if cached, ok := structFieldCache.Load(t); ok {
+		return cached.(*structFields), nil
+	}
+
+	fields := &structFields{}
+	if err := collectFields(t, nil, fields); err != nil {
+		return nil, err
+	}
+
+	structFieldCache.Store(t, fields)
+
+	return fields, nil
+}
+
+func collectFields(t reflect.Type, index []int, fields *structFields) error {
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, tagged := field.Tag.Lookup("terra")
+		if tag == "-" {
+			continue
+		}
+		at := append(append([]int{}, index...), i)
+
+		if field.Anonymous && !tagged && field.Type.Kind() == reflect.Struct {
+			if err := collectFields(field.Type, at, fields); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		options := strings.Split(tag, ",")
+		name := options[0]
+		var omitEmpty, special bool
+		for _, option := range options[1:] {
+			switch option {
+			case "id":
+				switch field.Type.Kind() {
+				case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				default:
+					return errors.Newf("The id field %s of %s should be a string or an integer.", field.Name, t)
+				}
+				fields.id, special = at, true
+			case "geometry":
+				if field.Type != geometryType {
+					return errors.Newf("The geometry field %s of %s should be a *geos.Geometry.", field.Name, t)
+				}
+				fields.geometry, special = at, true
+			case "omitempty":
+				omitEmpty = true
+			default:
+				return errors.Newf("The terra tag of field %s of %s has an unknown option %s.", field.Name, t, option)
+			}
+		}
+		if special {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if _, err := parsePropertyPath(name); err != nil {
+			return errors.Wrapf(err, "invalid terra tag on field %s of %s", field.Name, t)
+		}
+		fields.properties = append(fields.properties, structField{index: at, path: name, omitEmpty: omitEmpty})
+	}
+
+	return nil
+}
+
+// structValue returns the struct a value holds or points to.
+func structValue(v interface{}, settable bool) (reflect.Value, error) {
+
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	} else if settable {
+		return reflect.Value{}, errors.Newf("Unable to unmarshal a feature into %T, which is not a non-nil pointer.", v)
+	}
+
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}, errors.Newf("Unable to map %T to a feature, as it is not a struct.", v)
+	}
+
+	return value, nil
+}
+
+// MarshalFeature creates a feature from a struct, or a pointer to one, as its
+// terra tags describe. A struct without an ID field, or with a zero ID, gets a
+// generated one.
+func MarshalFeature(v interface{}) (*Feature, error) {
+
+	value, err := structValue(v, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := typeFields(value.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	feat := NewFeature()
+
+	if fields.id != nil {
+		if id := value.FieldByIndex(fields.id); !id.IsZero() {
+			switch id.Kind() {
+			case reflect.String:
+				feat.ID = id.String()
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				feat.ID = strconv.FormatInt(id.Int(), 10)
+			default:
+				feat.ID = strconv.FormatUint(id.Uint(), 10)
+			}
+		}
+	}
+
+	if fields.geometry != nil {
+		if geometry := value.FieldByIndex(fields.geometry).Interface().(*geos.Geometry); geometry != nil {
+			typer, err := geometryTypeName(geometry)
+			if err != nil {
+				return nil, err
+			}
+			if err := feat.SetGeometry(typer, geometry); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, field := range fields.properties {
+		property := value.FieldByIndex(field.index)
+		if field.omitEmpty && property.IsZero() {
+			continue
+		}
+		if err := feat.SetProperty(field.path, property.Interface()); err != nil {
+			return nil, err
+		}
+	}
+
+	return feat, nil
+}
+
+// UnmarshalFeature fills the struct v points to from the feature, as its
+// terra tags describe. Properties are coerced as the typed accessors do, so a
+// numeric string fills an int field; slices, maps and structs are filled as
+// encoding/json would. Missing and null properties leave fields unchanged.
+func UnmarshalFeature(feat *Feature, v interface{}) error {
+
+	value, err := structValue(v, true)
+	if err != nil {
+		return err
+	}
+
+	fields, err := typeFields(value.Type())
+	if err != nil {
+		return err
+	}
+
+	if fields.id != nil {
+		id := value.FieldByIndex(fields.id)
+		switch id.Kind() {
+		case reflect.String:
+			id.SetString(feat.ID)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(feat.ID, 10, id.Type().Bits())
+			if err != nil {
+				return errors.Newf("The feature ID %s does not fit the integer field of %s.", feat.ID, value.Type())
+			}
+			id.SetInt(n)
+		default:
+			n, err := strconv.ParseUint(feat.ID, 10, id.Type().Bits())
+			if err != nil {
+				return errors.Newf("The feature ID %s does not fit the integer field of %s.", feat.ID, value.Type())
+			}
+			id.SetUint(n)
+		}
+	}
+
+	if fields.geometry != nil {
+		value.FieldByIndex(fields.geometry).Set(reflect.ValueOf(feat.Geometry))
+	}
+
+	for _, field := range fields.properties {
+		property, err := feat.LookupProperty(field.path)
+		if err != nil || property == nil {
+			continue
+		}
+		if err := assignProperty(feat, field.path, value.FieldByIndex(field.index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assignProperty sets the field from the non-null property at the path.
+func assignProperty(feat *Feature, path string, field reflect.Value) error {
+
+	switch {
+	case field.Type() == timeType:
+		t, err := feat.PropertyTime(path)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case field.Kind() == reflect.Ptr:
+		elem := reflect.New(field.Type().Elem())
+		if err := assignProperty(feat, path, elem.Elem()); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		s, err := feat.PropertyString(path)
+		if err != nil {
+			return err
+		}
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := feat.PropertyBool(path)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := feat.PropertyFloat(path)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := feat.PropertyInt(path)
+		if err != nil {
+			return err
+		}
+		if field.OverflowInt(int64(n)) {
+			return &PropertyError{ID: feat.ID, Path: path, Value: n, Want: field.Type().String()}
+		}
+		field.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := feat.PropertyInt(path)
+		if err != nil {
+			return err
+		}
+		if n < 0 || field.OverflowUint(uint64(n)) {
+			return &PropertyError{ID: feat.ID, Path: path, Value: n, Want: field.Type().String()}
+		}
+		field.SetUint(uint64(n))
+	case reflect.Interface:
+		field.Set(reflect.ValueOf(feat.Property(path)))
+	default:
+		encoded, err := json.Marshal(feat.Property(path))
+		if err != nil {
+			return errors.Wrapf(err, "could not marshal property %s", path)
+		}
+		if err := json.Unmarshal(encoded, field.Addr().Interface()); err != nil {
+			return &PropertyError{ID: feat.ID, Path: path, Value: feat.Property(path), Want: field.Type().String()}
+		}
+	}
+
+	return nil
+}
+
+// MarshalFeatures creates a collection from a slice of structs.
+func MarshalFeatures[T any](values []T) (FeatureCollection, error) {
+
+	coll := FeatureCollection{}
+	for i := range values {
+		feat, err := MarshalFeature(&values[i])
+		if err != nil {
+			return nil, err
+		}
+		coll = append(coll, feat)
+	}
+
+	return coll, nil
+}
+
+// UnmarshalFeatures fills a slice of structs from the features.
+func UnmarshalFeatures[T any](features []*Feature) ([]T, error) {
+
+	values := make([]T, len(features))
+	for i := range features {
+		if err := UnmarshalFeature(features[i], &values[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// As adapts any query returning features to return structs instead, as in
+//
+//	parks, err := terra.As[Park](store.Contains(point))
+func As[T any](features []*Feature, err error) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalFeatures[T](features)
+}
+
+// GetAs returns the stored feature with the key as a struct.
+func GetAs[T any](g *Geostore, key []byte) (T, error) {
+
+	var value T
+
+	feat, err := g.Get(key)
+	if err != nil {
+		return value, err
+	}
+
+	if err := UnmarshalFeature(feat, &value); err != nil {
+		return value, err
+	}
+
+	return value, nil
+}
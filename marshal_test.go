@@ -0,0 +1,97 @@
+package terra
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paulsmith/gogeos/geos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testPark struct {
+	Code        string         `terra:",id"`
+	Name        string         `terra:"name"`
+	City        string         `terra:"address.city"`
+	Established time.Time      `terra:"established"`
+	Visitors    int            `terra:"visitors"`
+	Area        *float64       `terra:"area,omitempty"`
+	Tags        []string       `terra:"tags"`
+	Boundary    *geos.Geometry `terra:",geometry"`
+	Notes       string         `terra:"-"`
+	Region      string
+}
+
+func TestMarshal(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should marshal a struct into a feature", t, func() {
+		feat, err := MarshalFeature(testPark{
+			Code:        "CRLA",
+			Name:        "Crater Lake",
+			City:        "Crater Lake",
+			Established: time.Date(1902, 5, 22, 0, 0, 0, 0, time.UTC),
+			Tags:        []string{"lake", "caldera"},
+			Notes:       "private",
+			Region:      "Pacific West",
+		})
+		So(err, ShouldBeNil)
+		So(feat.ID, ShouldEqual, "CRLA")
+		So(feat.Property("name"), ShouldEqual, "Crater Lake")
+		So(feat.Property("address.city"), ShouldEqual, "Crater Lake")
+		So(feat.Property("Region"), ShouldEqual, "Pacific West")
+		So(feat.Properties, ShouldContainKey, "visitors")
+		So(feat.Properties, ShouldNotContainKey, "area")
+		So(feat.Properties, ShouldNotContainKey, "Notes")
+		So(feat.Geometry, ShouldBeNil)
+	})
+
+	Convey("should unmarshal a feature into a struct, coercing values", t, func() {
+		feat := &Feature{ID: "YELL", Properties: map[string]interface{}{
+			"name":        "Yellowstone",
+			"address":     map[string]interface{}{"city": "Mammoth"},
+			"established": "1872-03-01",
+			"visitors":    "4860242",
+			"area":        float64(8983),
+			"tags":        []interface{}{"geysers"},
+		}}
+
+		var park testPark
+		So(UnmarshalFeature(feat, &park), ShouldBeNil)
+		So(park.Code, ShouldEqual, "YELL")
+		So(park.City, ShouldEqual, "Mammoth")
+		So(park.Established.Year(), ShouldEqual, 1872)
+		So(park.Visitors, ShouldEqual, 4860242)
+		So(*park.Area, ShouldEqual, 8983)
+		So(park.Tags, ShouldResemble, []string{"geysers"})
+	})
+
+	Convey("should report properties of the wrong type", t, func() {
+		feat := &Feature{ID: "ZION", Properties: map[string]interface{}{"visitors": "many"}}
+		var park testPark
+		err := UnmarshalFeature(feat, &park)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "visitors")
+		So(UnmarshalFeature(feat, park), ShouldNotBeNil)
+	})
+
+	Convey("should convert slices with generic helpers", t, func() {
+		coll, err := MarshalFeatures([]testPark{{Code: "ACAD", Name: "Acadia"}, {Name: "Unnamed"}})
+		So(err, ShouldBeNil)
+		So(len(coll), ShouldEqual, 2)
+		So(coll[1].ID, ShouldNotBeEmpty)
+
+		parks, err := As[testPark](coll, nil)
+		So(err, ShouldBeNil)
+		So(parks[0].Name, ShouldEqual, "Acadia")
+		So(parks[1].Code, ShouldEqual, coll[1].ID)
+	})
+
+	Convey("should reject malformed tags", t, func() {
+		type badID struct {
+			ID float64 `terra:",id"`
+		}
+		_, err := MarshalFeature(badID{})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,169 @@
+package terra
+
+import (
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// PropertyRule decides the properties of a feature produced by an operation
+// from those of the receiver and, for binary operations, the other feature,
+// which is nil otherwise.
+type PropertyRule func(feat, other *Feature) map[string]interface{}
+
+// KeepProperties copies the properties of the receiver. It is the rule
+// operations apply when none is given.
+func KeepProperties(feat, other *Feature) map[string]interface{} {
+	return copyProperties(feat.Properties)
+}
+
+// MergeProperties copies the properties of the receiver, adding those of the
+// other feature that the receiver lacks.
+func MergeProperties(feat, other *Feature) map[string]interface{} {
+	properties := copyProperties(feat.Properties)
+	if other == nil {
+		return properties
+	}
+	for key, value := range other.Properties {
+		if _, ok := properties[key]; ok {
+			continue
+		}
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		properties[key] = value
+	}
+	return properties
+}
+
+// DropProperties leaves the result without properties.
+func DropProperties(feat, other *Feature) map[string]interface{} {
+	return nil
+}
+
+func copyProperties(properties map[string]interface{}) map[string]interface{} {
+	if properties == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		copied[key] = value
+	}
+	return copied
+}
+
+// derive creates a new feature around the geometry an operation produced.
+func (feat *Feature) derive(geometry *geos.Geometry, other *Feature, rules []PropertyRule) (*Feature, error) {
+
+	typer, err := geometryTypeName(geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := PropertyRule(KeepProperties)
+	if len(rules) > 0 && rules[0] != nil {
+		rule = rules[0]
+	}
+
+	derived := NewFeature()
+	derived.Properties = rule(feat, other)
+	derived.CRS, derived.inheritedCRS = feat.CRS, feat.inheritedCRS
+	if err := derived.SetGeometry(typer, geometry); err != nil {
+		return nil, err
+	}
+
+	return derived, nil
+}
+
+func (feat *Feature) unary(operation string, fn func(*geos.Geometry) (*geos.Geometry, error), rules []PropertyRule) (*Feature, error) {
+
+	if feat.Geometry == nil {
+		return nil, errors.Newf("Unable to compute the %s of a feature without geometry.", operation)
+	}
+
+	geometry, err := fn(feat.Geometry)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not compute %s", operation)
+	}
+
+	return feat.derive(geometry, nil, rules)
+}
+
+func (feat *Feature) binary(operation string, other *Feature, fn func(*geos.Geometry, *geos.Geometry) (*geos.Geometry, error), rules []PropertyRule) (*Feature, error) {
+
+	if feat.Geometry == nil || other == nil || other.Geometry == nil {
+		return nil, errors.Newf("Unable to compute the %s of features without geometry.", operation)
+	}
+
+	geometry, err := fn(feat.Geometry, other.Geometry)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not compute %s", operation)
+	}
+
+	return feat.derive(geometry, other, rules)
+}
+
+// Buffer returns the area within width of the feature, in the units of its
+// coordinates.
+func (feat *Feature) Buffer(width float64, rules ...PropertyRule) (*Feature, error) {
+	return feat.unary("buffer", func(g *geos.Geometry) (*geos.Geometry, error) {
+		return g.Buffer(width)
+	}, rules)
+}
+
+// Union returns the area covered by either feature.
+func (feat *Feature) Union(other *Feature, rules ...PropertyRule) (*Feature, error) {
+	return feat.binary("union", other, (*geos.Geometry).Union, rules)
+}
+
+// Intersection returns the area covered by both features.
+func (feat *Feature) Intersection(other *Feature, rules ...PropertyRule) (*Feature, error) {
+	return feat.binary("intersection", other, (*geos.Geometry).Intersection, rules)
+}
+
+// Difference returns the area of the feature not covered by the other.
+func (feat *Feature) Difference(other *Feature, rules ...PropertyRule) (*Feature, error) {
+	return feat.binary("difference", other, (*geos.Geometry).Difference, rules)
+}
+
+// SymDifference returns the area covered by exactly one of the features.
+func (feat *Feature) SymDifference(other *Feature, rules ...PropertyRule) (*Feature, error) {
+	return feat.binary("symmetric difference", other, (*geos.Geometry).SymDifference, rules)
+}
+
+// ConvexHull returns the smallest convex polygon containing the feature.
+func (feat *Feature) ConvexHull(rules ...PropertyRule) (*Feature, error) {
+	return feat.unary("convex hull", (*geos.Geometry).ConvexHull, rules)
+}
+
+// Centroid returns the center of mass of the feature as a Point, which may
+// lie outside of it.
+func (feat *Feature) Centroid(rules ...PropertyRule) (*Feature, error) {
+	return feat.unary("centroid", (*geos.Geometry).Centroid, rules)
+}
+
+// PointOnSurface returns a Point guaranteed to lie on the feature.
+func (feat *Feature) PointOnSurface(rules ...PropertyRule) (*Feature, error) {
+	return feat.unary("point on surface", (*geos.Geometry).PointOnSurface, rules)
+}
+
+// Envelope returns the bounding rectangle of the feature as a Polygon, or a
+// Point for a point.
+func (feat *Feature) Envelope(rules ...PropertyRule) (*Feature, error) {
+	return feat.unary("envelope", (*geos.Geometry).Envelope, rules)
+}
+
+// Simplify returns the feature simplified with the Douglas-Peucker algorithm.
+// The result may not be valid; use TopologyPreservingSimplify to keep it so.
+func (feat *Feature) Simplify(tolerance float64, rules ...PropertyRule) (*Feature, error) {
+	return feat.unary("simplification", func(g *geos.Geometry) (*geos.Geometry, error) {
+		return g.Simplify(tolerance)
+	}, rules)
+}
+
+// TopologyPreservingSimplify returns the feature simplified without letting
+// rings collapse or cross.
+func (feat *Feature) TopologyPreservingSimplify(tolerance float64, rules ...PropertyRule) (*Feature, error) {
+	return feat.unary("simplification", func(g *geos.Geometry) (*geos.Geometry, error) {
+		return g.SimplifyP(tolerance)
+	}, rules)
+}
@@ -0,0 +1,321 @@
+package terra
+
+import (
+	"math"
+
+	"github.com/paulsmith/gogeos/geos"
+)
+
+// Ellipsoid is a reference ellipsoid, given by its semi-major axis in meters
+// and its flattening.
+type Ellipsoid struct {
+	A, F float64
+}
+
+var (
+	WGS84Ellipsoid    = Ellipsoid{A: wgs84A, F: wgs84F}
+	GRS80Ellipsoid    = Ellipsoid{A: 6378137, F: 1 / 298.257222101}
+	Airy1830Ellipsoid = Ellipsoid{A: 6377563.396, F: 1 / 299.3249646}
+)
+
+// eccentricity is the first eccentricity of the ellipsoid.
+func (e Ellipsoid) eccentricity() float64 {
+	return math.Sqrt(e.F * (2 - e.F))
+}
+
+// Datum is a geodetic datum: an ellipsoid, and the seven parameter Helmert
+// transformation taking its geocentric coordinates to WGS84, in the position
+// vector convention of EPSG method 9606. The translations are in meters, the
+// rotations in arc seconds and the scale in parts per million.
+type Datum struct {
+	Name      string
+	Ellipsoid Ellipsoid
+	ToWGS84   [7]float64
+}
+
+var (
+	WGS84Datum  = Datum{Name: "WGS84", Ellipsoid: WGS84Ellipsoid}
+	ETRS89Datum = Datum{Name: "ETRS89", Ellipsoid: GRS80Ellipsoid}
+	NAD83Datum  = Datum{Name: "NAD83", Ellipsoid: GRS80Ellipsoid}
+	// NZGD2000Datum is the New Zealand Geodetic Datum 2000.
+	NZGD2000Datum = Datum{Name: "NZGD2000", Ellipsoid: GRS80Ellipsoid}
+	// OSGB36Datum is the datum of the British National Grid, shifted to WGS84
+	// to within a few meters.
+	OSGB36Datum = Datum{
+		Name:      "OSGB36",
+		Ellipsoid: Airy1830Ellipsoid,
+		ToWGS84:   [7]float64{446.448, -125.157, 542.06, 0.15, 0.247, 0.842, -20.489},
+	}
+)
+
+// shiftDatum converts longitude and latitude in degrees between datums through
+// geocentric coordinates, ignoring height.
+func shiftDatum(from, to Datum, lon, lat float64) (float64, float64) {
+	x, y, z := geodeticToGeocentric(from.Ellipsoid, lon, lat)
+	x, y, z = helmert(from.ToWGS84, x, y, z, 1)
+	x, y, z = helmert(to.ToWGS84, x, y, z, -1)
+	return geocentricToGeodetic(to.Ellipsoid, x, y, z)
+}
+
+// helmert applies the transformation, or with a sign of -1 its inverse, which
+// for parameters this small is the transformation with each negated.
+func helmert(p [7]float64, x, y, z, sign float64) (float64, float64, float64) {
+
+	if p == [7]float64{} {
+		return x, y, z
+	}
+
+	arcsec := math.Pi / 180 / 3600
+	rx, ry, rz := sign*p[3]*arcsec, sign*p[4]*arcsec, sign*p[5]*arcsec
+	s := 1 + sign*p[6]*1e-6
+
+	return sign*p[0] + s*(x-rz*y+ry*z),
+		sign*p[1] + s*(rz*x+y-rx*z),
+		sign*p[2] + s*(-ry*x+rx*y+z)
+}
+
+func geodeticToGeocentric(e Ellipsoid, lon, lat float64) (float64, float64, float64) {
+	e2 := e.F * (2 - e.F)
+	sinPhi, cosPhi := math.Sincos(radians(lat))
+	sinLambda, cosLambda := math.Sincos(radians(lon))
+	n := e.A / math.Sqrt(1-e2*sinPhi*sinPhi)
+	return n * cosPhi * cosLambda, n * cosPhi * sinLambda, n * (1 - e2) * sinPhi
+}
+
+func geocentricToGeodetic(e Ellipsoid, x, y, z float64) (float64, float64) {
+
+	e2 := e.F * (2 - e.F)
+	p := math.Hypot(x, y)
+
+	phi := math.Atan2(z, p*(1-e2))
+	for i := 0; i < 10; i++ {
+		sinPhi := math.Sin(phi)
+		n := e.A / math.Sqrt(1-e2*sinPhi*sinPhi)
+		previous := phi
+		phi = math.Atan2(z+e2*n*sinPhi, p)
+		if math.Abs(phi-previous) < 1e-14 {
+			break
+		}
+	}
+
+	return degrees(math.Atan2(y, x)), degrees(phi)
+}
+
+// Projection maps longitude and latitude in degrees on an ellipsoid to easting
+// and northing in meters, and back.
+type Projection interface {
+	Forward(e Ellipsoid, lon, lat float64) (x, y float64)
+	Inverse(e Ellipsoid, x, y float64) (lon, lat float64)
+}
+
+// webMercator is the popular visualisation pseudo-Mercator, which treats
+// ellipsoidal coordinates as though they were on a sphere.
+type webMercator struct{}
+
+// webMercatorMaxLatitude is where Web Mercator maps are cut to make them
+// square.
+const webMercatorMaxLatitude = 85.0511287798066
+
+func (webMercator) Forward(e Ellipsoid, lon, lat float64) (float64, float64) {
+	lat = math.Max(-webMercatorMaxLatitude, math.Min(webMercatorMaxLatitude, lat))
+	return e.A * radians(lon), e.A * math.Log(math.Tan(math.Pi/4+radians(lat)/2))
+}
+
+func (webMercator) Inverse(e Ellipsoid, x, y float64) (float64, float64) {
+	return degrees(x / e.A), degrees(math.Pi/2 - 2*math.Atan(math.Exp(-y/e.A)))
+}
+
+// TransverseMercator is the transverse Mercator projection, computed with
+// Krüger's series to fourth order in the third flattening, which holds to
+// within a millimeter several thousand kilometers from the central meridian.
+// A zero ScaleFactor is taken as 1.
+type TransverseMercator struct {
+	CentralMeridian  float64
+	LatitudeOfOrigin float64
+	ScaleFactor      float64
+	FalseEasting     float64
+	FalseNorthing    float64
+}
+
+// krueger holds the series coefficients of an ellipsoid.
+type krueger struct {
+	e, a               float64
+	alpha, beta, delta [4]float64
+}
+
+func newKrueger(e Ellipsoid) krueger {
+
+	n := e.F / (2 - e.F)
+	n2, n3, n4 := n*n, n*n*n, n*n*n*n
+
+	return krueger{
+		e: e.eccentricity(),
+		a: e.A / (1 + n) * (1 + n2/4 + n4/64),
+		alpha: [4]float64{
+			n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180,
+			13*n2/48 - 3*n3/5 + 557*n4/1440,
+			61*n3/240 - 103*n4/140,
+			49561 * n4 / 161280,
+		},
+		beta: [4]float64{
+			n/2 - 2*n2/3 + 37*n3/96 - n4/360,
+			n2/48 + n3/15 - 437*n4/1440,
+			17*n3/480 - 37*n4/840,
+			4397 * n4 / 161280,
+		},
+		delta: [4]float64{
+			2*n - 2*n2/3 - 2*n3 + 116*n4/45,
+			7*n2/3 - 8*n3/5 - 227*n4/45,
+			56*n3/15 - 136*n4/35,
+			4279 * n4 / 630,
+		},
+	}
+}
+
+// forward returns the series coordinates, in units of the rectifying radius,
+// of a position lambda radians from the central meridian.
+func (k krueger) forward(phi, lambda float64) (float64, float64) {
+
+	t := math.Sinh(math.Atanh(math.Sin(phi)) - k.e*math.Atanh(k.e*math.Sin(phi)))
+	xiPrime := math.Atan2(t, math.Cos(lambda))
+	etaPrime := math.Atanh(math.Sin(lambda) / math.Sqrt(1+t*t))
+
+	xi, eta := xiPrime, etaPrime
+	for j, alpha := range k.alpha {
+		m := float64(2 * (j + 1))
+		xi += alpha * math.Sin(m*xiPrime) * math.Cosh(m*etaPrime)
+		eta += alpha * math.Cos(m*xiPrime) * math.Sinh(m*etaPrime)
+	}
+
+	return xi, eta
+}
+
+func (k krueger) inverse(xi, eta float64) (float64, float64) {
+
+	xiPrime, etaPrime := xi, eta
+	for j, beta := range k.beta {
+		m := float64(2 * (j + 1))
+		xiPrime -= beta * math.Sin(m*xi) * math.Cosh(m*eta)
+		etaPrime -= beta * math.Cos(m*xi) * math.Sinh(m*eta)
+	}
+
+	chi := math.Asin(math.Sin(xiPrime) / math.Cosh(etaPrime))
+	phi := chi
+	for j, delta := range k.delta {
+		phi += delta * math.Sin(float64(2*(j+1))*chi)
+	}
+
+	return phi, math.Atan2(math.Sinh(etaPrime), math.Cos(xiPrime))
+}
+
+func (p TransverseMercator) scale() float64 {
+	if p.ScaleFactor == 0 {
+		return 1
+	}
+	return p.ScaleFactor
+}
+
+func (p TransverseMercator) Forward(e Ellipsoid, lon, lat float64) (float64, float64) {
+
+	k := newKrueger(e)
+	xi0, _ := k.forward(radians(p.LatitudeOfOrigin), 0)
+	xi, eta := k.forward(radians(lat), radians(wrapLongitude(lon-p.CentralMeridian)))
+
+	return p.FalseEasting + p.scale()*k.a*eta, p.FalseNorthing + p.scale()*k.a*(xi-xi0)
+}
+
+func (p TransverseMercator) Inverse(e Ellipsoid, x, y float64) (float64, float64) {
+
+	k := newKrueger(e)
+	xi0, _ := k.forward(radians(p.LatitudeOfOrigin), 0)
+	phi, lambda := k.inverse(
+		(y-p.FalseNorthing)/(p.scale()*k.a)+xi0,
+		(x-p.FalseEasting)/(p.scale()*k.a),
+	)
+
+	return wrapLongitude(p.CentralMeridian + degrees(lambda)), degrees(phi)
+}
+
+// LambertConformalConic is the Lambert conformal conic projection with two
+// standard parallels, or with one when both are equal, in which case a
+// ScaleFactor may be given for it. A zero ScaleFactor is taken as 1.
+type LambertConformalConic struct {
+	CentralMeridian   float64
+	LatitudeOfOrigin  float64
+	StandardParallel1 float64
+	StandardParallel2 float64
+	ScaleFactor       float64
+	FalseEasting      float64
+	FalseNorthing     float64
+}
+
+// cone returns the cone constant, the radius scale and the radius at the
+// latitude of origin.
+func (p LambertConformalConic) cone(e Ellipsoid) (float64, float64, float64) {
+
+	ecc := e.eccentricity()
+	m := func(phi float64) float64 {
+		return math.Cos(phi) / math.Sqrt(1-ecc*ecc*math.Sin(phi)*math.Sin(phi))
+	}
+
+	phi1, phi2 := radians(p.StandardParallel1), radians(p.StandardParallel2)
+	n := math.Sin(phi1)
+	if phi1 != phi2 {
+		n = (math.Log(m(phi1)) - math.Log(m(phi2))) / (math.Log(conformalT(ecc, phi1)) - math.Log(conformalT(ecc, phi2)))
+	}
+
+	k := p.ScaleFactor
+	if k == 0 {
+		k = 1
+	}
+	f := e.A * k * m(phi1) / (n * math.Pow(conformalT(ecc, phi1), n))
+
+	return n, f, f * math.Pow(conformalT(ecc, radians(p.LatitudeOfOrigin)), n)
+}
+
+// conformalT is the function t of Snyder's formulae for conformal projections.
+func conformalT(ecc, phi float64) float64 {
+	sinPhi := math.Sin(phi)
+	return math.Tan(math.Pi/4-phi/2) / math.Pow((1-ecc*sinPhi)/(1+ecc*sinPhi), ecc/2)
+}
+
+func (p LambertConformalConic) Forward(e Ellipsoid, lon, lat float64) (float64, float64) {
+
+	n, f, rho0 := p.cone(e)
+	rho := f * math.Pow(conformalT(e.eccentricity(), radians(lat)), n)
+	sinTheta, cosTheta := math.Sincos(n * radians(wrapLongitude(lon-p.CentralMeridian)))
+
+	return p.FalseEasting + rho*sinTheta, p.FalseNorthing + rho0 - rho*cosTheta
+}
+
+func (p LambertConformalConic) Inverse(e Ellipsoid, x, y float64) (float64, float64) {
+
+	n, f, rho0 := p.cone(e)
+	ecc := e.eccentricity()
+
+	dx, dy := x-p.FalseEasting, rho0-(y-p.FalseNorthing)
+	sign := math.Copysign(1, n)
+	rho := sign * math.Hypot(dx, dy)
+	theta := math.Atan2(sign*dx, sign*dy)
+	t := math.Pow(rho/f, 1/n)
+
+	phi := math.Pi/2 - 2*math.Atan(t)
+	for i := 0; i < 15; i++ {
+		sinPhi := math.Sin(phi)
+		previous := phi
+		phi = math.Pi/2 - 2*math.Atan(t*math.Pow((1-ecc*sinPhi)/(1+ecc*sinPhi), ecc/2))
+		if math.Abs(phi-previous) < 1e-14 {
+			break
+		}
+	}
+
+	return wrapLongitude(p.CentralMeridian + degrees(theta/n)), degrees(phi)
+}
+
+// wrapLongitude brings a longitude into [-180, 180].
+func wrapLongitude(lon float64) float64 {
+	if lon >= -180 && lon <= 180 {
+		return lon
+	}
+	return normalizeCoord(geos.NewCoord(lon, 0)).X
+}
@@ -0,0 +1,423 @@
+package terra
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saleswise/errors/errors"
+)
+
+// PropertyError reports a property that is missing or cannot be read as the
+// requested type.
+type PropertyError struct {
+	ID   string
+	Path string
+	// Value is the property found, and nil if it is missing.
+	Value interface{}
+	// Want names the type requested, if the value could not be coerced to it.
+	Want   string
+	Reason string
+}
+
+func (e *PropertyError) Error() string {
+	if e.Want != "" {
+		return fmt.Sprintf("Property %s of feature %s is %#v, which is not a %s.", e.Path, e.ID, e.Value, e.Want)
+	}
+	return fmt.Sprintf("Property %s of feature %s %s.", e.Path, e.ID, e.Reason)
+}
+
+// Missing reports whether the error is for a property that does not exist.
+func (e *PropertyError) Missing() bool {
+	return e.Want == "" && e.Value == nil
+}
+
+// pathSegment is an object key, or an array index when index is not negative.
+type pathSegment struct {
+	key   string
+	index int
+}
+
+// parsePropertyPath splits a path such as "address.city" or "tags[2]" into
+// keys and indexes.
+func parsePropertyPath(path string) ([]pathSegment, error) {
+
+	var segments []pathSegment
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key = part[:i]
+		}
+		if key == "" && (len(segments) == 0 || !strings.HasPrefix(part, "[")) {
+			return nil, errors.Newf("The property path %s has an empty name.", path)
+		}
+		if key != "" {
+			segments = append(segments, pathSegment{key: key, index: -1})
+		}
+		for rest := part[len(key):]; rest != ""; {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, errors.Newf("The property path %s has a malformed index.", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, errors.Newf("The property path %s has an index that is not a whole number.", path)
+			}
+			segments = append(segments, pathSegment{index: index})
+			rest = rest[end+1:]
+		}
+	}
+
+	return segments, nil
+}
+
+// LookupProperty returns the property at a path of object keys separated by
+// dots and array indexes in brackets, such as "address.city" or "tags[2]". A
+// top level property whose name contains those characters is found as well.
+func (feat *Feature) LookupProperty(path string) (interface{}, error) {
+
+	if value, ok := feat.Properties[path]; ok {
+		return value, nil
+	}
+
+	segments, err := parsePropertyPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var current interface{} = feat.Properties
+	for i, segment := range segments {
+		walked := pathString(segments[:i+1])
+		if segment.index < 0 {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, &PropertyError{ID: feat.ID, Path: path, Reason: fmt.Sprintf("is missing, as %s is not an object", pathString(segments[:i]))}
+			}
+			if current, ok = object[segment.key]; !ok {
+				return nil, &PropertyError{ID: feat.ID, Path: path, Reason: "is missing"}
+			}
+			continue
+		}
+		array := reflect.ValueOf(current)
+		if current == nil || array.Kind() != reflect.Slice {
+			return nil, &PropertyError{ID: feat.ID, Path: path, Reason: fmt.Sprintf("is missing, as %s is not an array", pathString(segments[:i]))}
+		}
+		if segment.index >= array.Len() {
+			return nil, &PropertyError{ID: feat.ID, Path: path, Reason: fmt.Sprintf("is missing, as %s is beyond the %d elements of the array", walked, array.Len())}
+		}
+		current = array.Index(segment.index).Interface()
+	}
+
+	return current, nil
+}
+
+func pathString(segments []pathSegment) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		switch {
+		case segment.index >= 0:
+			fmt.Fprintf(&b, "[%d]", segment.index)
+		case i > 0:
+			b.WriteString("." + segment.key)
+		default:
+			b.WriteString(segment.key)
+		}
+	}
+	return b.String()
+}
+
+// Property returns the property at the path, as LookupProperty finds it, or
+// nil if there is none.
+func (feat *Feature) Property(path string) interface{} {
+	value, err := feat.LookupProperty(path)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// PropertyString returns the property as a string, writing numbers and
+// booleans as text.
+func (feat *Feature) PropertyString(path string) (string, error) {
+
+	value, err := feat.LookupProperty(path)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	}
+	if number, ok := propertyNumber(value); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	}
+
+	return "", &PropertyError{ID: feat.ID, Path: path, Value: value, Want: "string"}
+}
+
+// PropertyFloat returns the property as a float64, parsing numeric strings.
+func (feat *Feature) PropertyFloat(path string) (float64, error) {
+
+	value, err := feat.LookupProperty(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if number, ok := coerceNumber(value); ok {
+		return number, nil
+	}
+
+	return 0, &PropertyError{ID: feat.ID, Path: path, Value: value, Want: "number"}
+}
+
+// PropertyInt returns the property as an int, parsing numeric strings. Numbers
+// with a fractional part are not accepted.
+func (feat *Feature) PropertyInt(path string) (int, error) {
+
+	value, err := feat.LookupProperty(path)
+	if err != nil {
+		return 0, err
+	}
+
+	number, ok := coerceNumber(value)
+	if !ok || number != math.Trunc(number) || math.Abs(number) >= 1<<63 {
+		return 0, &PropertyError{ID: feat.ID, Path: path, Value: value, Want: "whole number"}
+	}
+
+	return int(number), nil
+}
+
+// coerceNumber reads numbers of any Go type, and strings holding one.
+func coerceNumber(value interface{}) (float64, bool) {
+
+	switch v := value.(type) {
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number, err == nil
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	}
+
+	return propertyNumber(value)
+}
+
+// PropertyBool returns the property as a bool, accepting the strings
+// strconv.ParseBool does and the numbers 0 and 1.
+func (feat *Feature) PropertyBool(path string) (bool, error) {
+
+	value, err := feat.LookupProperty(path)
+	if err != nil {
+		return false, err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b, nil
+		}
+	default:
+		if number, ok := propertyNumber(value); ok && (number == 0 || number == 1) {
+			return number == 1, nil
+		}
+	}
+
+	return false, &PropertyError{ID: feat.ID, Path: path, Value: value, Want: "boolean"}
+}
+
+// propertyTimeLayouts are the forms of time PropertyTime parses.
+var propertyTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// PropertyTime returns the property as a time, parsing RFC 3339 timestamps,
+// dates, and numbers as seconds since the Unix epoch.
+func (feat *Feature) PropertyTime(path string) (time.Time, error) {
+
+	value, err := feat.LookupProperty(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if t, ok := coerceTime(value); ok {
+		return t, nil
+	}
+
+	return time.Time{}, &PropertyError{ID: feat.ID, Path: path, Value: value, Want: "time"}
+}
+
+// coerceTime reads times, strings holding one and Unix seconds.
+func coerceTime(value interface{}) (time.Time, bool) {
+
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range propertyTimeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, true
+			}
+		}
+	default:
+		if seconds, ok := propertyNumber(value); ok {
+			whole, fraction := math.Modf(seconds)
+			return time.Unix(int64(whole), int64(fraction*1e9)).UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// SetProperty sets the property at a path such as "address.city" or
+// "tags[2]", creating objects and arrays along it as needed. An index may
+// extend an array by one element.
+func (feat *Feature) SetProperty(path string, value interface{}) error {
+
+	if _, ok := feat.Properties[path]; ok {
+		feat.Properties[path] = value
+		return nil
+	}
+
+	segments, err := parsePropertyPath(path)
+	if err != nil {
+		return err
+	}
+	properties, err := setPropertyPath(feat.Properties, segments, 0, value)
+	if err != nil {
+		return errors.Wrapf(err, "could not set property %s of feature %s", path, feat.ID)
+	}
+	feat.Properties = properties.(map[string]interface{})
+
+	return nil
+}
+
+// setPropertyPath returns the container with the value set at the path from
+// segment i, which may be a new container where there was none.
+func setPropertyPath(current interface{}, segments []pathSegment, i int, value interface{}) (interface{}, error) {
+
+	if i == len(segments) {
+		return value, nil
+	}
+	segment := segments[i]
+
+	if segment.index < 0 {
+		object, ok := current.(map[string]interface{})
+		if !ok && current != nil {
+			return nil, errors.Newf("The property %s is not an object.", pathString(segments[:i]))
+		}
+		if object == nil {
+			object = make(map[string]interface{})
+		}
+		child, err := setPropertyPath(object[segment.key], segments, i+1, value)
+		if err != nil {
+			return nil, err
+		}
+		object[segment.key] = child
+		return object, nil
+	}
+
+	array, ok := current.([]interface{})
+	if !ok && current != nil {
+		return nil, errors.Newf("The property %s is not an array.", pathString(segments[:i]))
+	}
+	switch {
+	case segment.index > len(array):
+		return nil, errors.Newf("The index %d is beyond the end of the %d elements of %s.", segment.index, len(array), pathString(segments[:i]))
+	case segment.index == len(array):
+		array = append(array, nil)
+	}
+	child, err := setPropertyPath(array[segment.index], segments, i+1, value)
+	if err != nil {
+		return nil, err
+	}
+	array[segment.index] = child
+
+	return array, nil
+}
+
+// DeleteProperty removes the property at the path, removing an array element
+// by shifting those after it. Deleting a missing property does nothing.
+func (feat *Feature) DeleteProperty(path string) error {
+
+	if _, ok := feat.Properties[path]; ok {
+		delete(feat.Properties, path)
+		return nil
+	}
+
+	segments, err := parsePropertyPath(path)
+	if err != nil {
+		return err
+	}
+
+	properties, err := deletePropertyPath(feat.Properties, segments, 0)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete property %s of feature %s", path, feat.ID)
+	}
+	if properties != nil {
+		feat.Properties = properties.(map[string]interface{})
+	}
+
+	return nil
+}
+
+func deletePropertyPath(current interface{}, segments []pathSegment, i int) (interface{}, error) {
+
+	if current == nil {
+		return nil, nil
+	}
+	segment := segments[i]
+	last := i == len(segments)-1
+
+	if segment.index < 0 {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, errors.Newf("The property %s is not an object.", pathString(segments[:i]))
+		}
+		if last {
+			delete(object, segment.key)
+			return object, nil
+		}
+		child, ok := object[segment.key]
+		if !ok {
+			return object, nil
+		}
+		child, err := deletePropertyPath(child, segments, i+1)
+		if err != nil {
+			return nil, err
+		}
+		object[segment.key] = child
+		return object, nil
+	}
+
+	array, ok := current.([]interface{})
+	if !ok {
+		return nil, errors.Newf("The property %s is not an array.", pathString(segments[:i]))
+	}
+	if segment.index >= len(array) {
+		return array, nil
+	}
+	if last {
+		return append(array[:segment.index], array[segment.index+1:]...), nil
+	}
+	child, err := deletePropertyPath(array[segment.index], segments, i+1)
+	if err != nil {
+		return nil, err
+	}
+	array[segment.index] = child
+
+	return array, nil
+}
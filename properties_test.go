@@ -0,0 +1,117 @@
+package terra
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProperties(t *testing.T) {
+
+	t.Parallel()
+
+	newFeature := func() *Feature {
+		feat := &Feature{ID: "store"}
+		So(json.Unmarshal([]byte(`{
+			"name": "Powell's",
+			"rooms": 9,
+			"rating": "4.5",
+			"open": "true",
+			"opened": "1971-05-01",
+			"address": { "city": "Portland", "zip": 97209 },
+			"tags": [ "books", "used", { "floor": 3 } ],
+			"a.b": "literal"
+		}`), &feat.Properties), ShouldBeNil)
+		return feat
+	}
+
+	Convey("should read nested properties by path", t, func() {
+		feat := newFeature()
+		So(feat.Property("address.city"), ShouldEqual, "Portland")
+		So(feat.Property("tags[1]"), ShouldEqual, "used")
+		So(feat.Property("tags[2].floor"), ShouldEqual, 3)
+		So(feat.Property("a.b"), ShouldEqual, "literal")
+		So(feat.Property("address.country"), ShouldBeNil)
+	})
+
+	Convey("should coerce properties to the requested type", t, func() {
+		feat := newFeature()
+
+		s, err := feat.PropertyString("address.zip")
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "97209")
+
+		f, err := feat.PropertyFloat("rating")
+		So(err, ShouldBeNil)
+		So(f, ShouldEqual, 4.5)
+
+		i, err := feat.PropertyInt("tags[2].floor")
+		So(err, ShouldBeNil)
+		So(i, ShouldEqual, 3)
+
+		b, err := feat.PropertyBool("open")
+		So(err, ShouldBeNil)
+		So(b, ShouldBeTrue)
+
+		opened, err := feat.PropertyTime("opened")
+		So(err, ShouldBeNil)
+		So(opened, ShouldResemble, time.Date(1971, 5, 1, 0, 0, 0, 0, time.UTC))
+	})
+
+	Convey("should explain missing and mistyped properties", t, func() {
+		feat := newFeature()
+
+		_, err := feat.PropertyString("tags[5]")
+		So(err, ShouldNotBeNil)
+		So(err.(*PropertyError).Missing(), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "beyond the 3 elements")
+
+		_, err = feat.PropertyInt("rating")
+		So(err, ShouldNotBeNil)
+		So(err.(*PropertyError).Missing(), ShouldBeFalse)
+		So(err.Error(), ShouldEqual, `Property rating of feature store is "4.5", which is not a whole number.`)
+
+		_, err = feat.PropertyFloat("address")
+		So(err, ShouldNotBeNil)
+
+		_, err = feat.LookupProperty("name.first")
+		So(err.Error(), ShouldContainSubstring, "name is not an object")
+
+		_, err = feat.LookupProperty("tags[x]")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("should set properties by path", t, func() {
+		feat := newFeature()
+		So(feat.SetProperty("address.city", "Beaverton"), ShouldBeNil)
+		So(feat.SetProperty("owner.name.first", "Emily"), ShouldBeNil)
+		So(feat.SetProperty("tags[3]", "new"), ShouldBeNil)
+		So(feat.SetProperty("tags[2].floor", 2), ShouldBeNil)
+		So(feat.Property("address.city"), ShouldEqual, "Beaverton")
+		So(feat.Property("owner.name.first"), ShouldEqual, "Emily")
+		So(feat.Property("tags[3]"), ShouldEqual, "new")
+		So(feat.Property("tags[2].floor"), ShouldEqual, 2)
+
+		So(feat.SetProperty("tags[9]", "far"), ShouldNotBeNil)
+		So(feat.SetProperty("name.first", "x"), ShouldNotBeNil)
+
+		empty := &Feature{}
+		So(empty.SetProperty("list[1]", "x"), ShouldNotBeNil)
+		So(empty.SetProperty("list[0]", "x"), ShouldBeNil)
+		So(empty.Property("list"), ShouldResemble, []interface{}{"x"})
+	})
+
+	Convey("should delete properties by path", t, func() {
+		feat := newFeature()
+		So(feat.DeleteProperty("address.zip"), ShouldBeNil)
+		So(feat.DeleteProperty("tags[0]"), ShouldBeNil)
+		So(feat.DeleteProperty("a.b"), ShouldBeNil)
+		So(feat.DeleteProperty("missing.entirely"), ShouldBeNil)
+		So(feat.Properties["address"], ShouldResemble, map[string]interface{}{"city": "Portland"})
+		So(feat.Property("tags[0]"), ShouldEqual, "used")
+		So(feat.Properties, ShouldNotContainKey, "a.b")
+		So(feat.DeleteProperty("name[0]"), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,247 @@
+package terra
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// Violation is a single departure from RFC 7946 found by Validate.
+type Violation struct {
+	// Path locates the offending member, such as "geometry.coordinates[0][3]".
+	Path   string
+	Reason string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Reason)
+}
+
+// ValidationError lists every violation found in a feature.
+type ValidationError struct {
+	ID         string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, len(e.Violations))
+	for i := range e.Violations {
+		reasons[i] = e.Violations[i].String()
+	}
+	return fmt.Sprintf("Feature %s does not conform to RFC 7946: %s.", e.ID, strings.Join(reasons, "; "))
+}
+
+type validator struct {
+	strict     bool
+	violations []Violation
+}
+
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{Path: path, Reason: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the feature against RFC 7946 and returns a *ValidationError
+// reporting every violation found. Rings must have at least four positions,
+// and positions must fall within longitude and latitude range; unclosed rings
+// never get this far, as decoding rejects them.
+// Strict validation also requires the right-hand winding rule and that a
+// declared bbox covers the geometry, which the RFC asks parsers to tolerate.
+// A feature in any other coordinate reference system than WGS84 is reported
+// as such without checking its positions.
+func (feat *Feature) Validate(strict bool) error {
+
+	if feat == nil {
+		return errors.New("Unable to validate a nil feature.")
+	}
+
+	v := &validator{strict: strict}
+
+	if feat.Geometry == nil {
+		v.add("geometry", "is missing")
+		return v.result(feat)
+	}
+
+	if crs := feat.crs(); !isWGS84(crs) {
+		v.add("crs", "coordinates must be WGS84 longitude and latitude, not %s", crs)
+		return v.result(feat)
+	}
+
+	if err := v.geometry("geometry.coordinates", feat.Type, feat.Geometry); err != nil {
+		return err
+	}
+
+	if len(feat.BBox) > 0 {
+		if err := v.bbox(feat); err != nil {
+			return err
+		}
+	}
+
+	return v.result(feat)
+}
+
+func (v *validator) result(feat *Feature) error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &ValidationError{ID: feat.ID, Violations: v.violations}
+}
+
+func (v *validator) geometry(path, typer string, geometry *geos.Geometry) error {
+
+	switch typer {
+	case "Point":
+		coords, err := geometry.Coords()
+		if err != nil {
+			return errors.Wrap(err, "could not get geometry coords")
+		}
+		if len(coords) == 0 {
+			v.add(path, "a Point requires a position")
+			return nil
+		}
+		v.position(path, coords[0])
+	case "LineString":
+		coords, err := geometry.Coords()
+		if err != nil {
+			return errors.Wrap(err, "could not get geometry coords")
+		}
+		if len(coords) < 2 {
+			v.add(path, "a LineString requires at least two positions, found %d", len(coords))
+		}
+		for i := range coords {
+			v.position(fmt.Sprintf("%s[%d]", path, i), coords[i])
+		}
+		if v.strict && crossesAntimeridian(coords) {
+			v.add(path, "the line crosses the antimeridian and should be cut in two")
+		}
+	case "Polygon":
+		rings, err := polygonRings(geometry)
+		if err != nil {
+			return err
+		}
+		for i := range rings {
+			v.ring(fmt.Sprintf("%s[%d]", path, i), rings[i], i == 0)
+		}
+	case "MultiPoint", "MultiLineString", "MultiPolygon":
+		count, err := geometry.NGeometry()
+		if err != nil {
+			return errors.Wrap(err, "could not get geometry count")
+		}
+		for i := 0; i < count; i++ {
+			member, err := geometry.Geometry(i)
+			if err != nil {
+				return errors.Wrap(err, "could not get collection geometry")
+			}
+			if err := v.geometry(fmt.Sprintf("%s[%d]", path, i), strings.TrimPrefix(typer, "Multi"), member); err != nil {
+				return err
+			}
+		}
+	case "GeometryCollection":
+		parts, err := geometryParts(geometry)
+		if err != nil {
+			return err
+		}
+		for i, part := range parts {
+			member, err := geometryTypeName(part)
+			if err != nil {
+				return err
+			}
+			if err := v.geometry(fmt.Sprintf("%s.geometries[%d].coordinates", strings.TrimSuffix(path, ".coordinates"), i), member, part); err != nil {
+				return err
+			}
+		}
+	default:
+		v.add("geometry.type", "unsupported geometry type %q", typer)
+	}
+
+	return nil
+}
+
+func (v *validator) ring(path string, ring []geos.Coord, exterior bool) {
+
+	if len(ring) < 4 {
+		v.add(path, "a linear ring requires at least four positions, found %d", len(ring))
+	}
+
+	for i := range ring {
+		v.position(fmt.Sprintf("%s[%d]", path, i), ring[i])
+	}
+
+	if !v.strict {
+		return
+	}
+
+	if crossesAntimeridian(ring) {
+		v.add(path, "the linear ring crosses the antimeridian and the polygon should be cut in two")
+		return
+	}
+
+	area := ringArea(ring)
+	switch {
+	case area == 0:
+		v.add(path, "the linear ring encloses no area")
+	case exterior && area < 0:
+		v.add(path, "the exterior ring winds clockwise, where the right-hand rule requires counterclockwise")
+	case !exterior && area > 0:
+		v.add(path, "the interior ring winds counterclockwise, where the right-hand rule requires clockwise")
+	}
+}
+
+func (v *validator) position(path string, coord geos.Coord) {
+
+	if math.IsNaN(coord.X) || math.IsInf(coord.X, 0) || math.IsNaN(coord.Y) || math.IsInf(coord.Y, 0) {
+		v.add(path, "the position [%f, %f] is not a finite number", coord.X, coord.Y)
+		return
+	}
+
+	if coord.X < -180 || coord.X > 180 {
+		v.add(path, "longitude %f is outside [-180, 180]", coord.X)
+	}
+
+	if coord.Y < -90 || coord.Y > 90 {
+		v.add(path, "latitude %f is outside [-90, 90]", coord.Y)
+	}
+}
+
+func (v *validator) bbox(feat *Feature) error {
+
+	dimensions := len(feat.BBox) / 2
+	south, north := feat.BBox[1], feat.BBox[dimensions+1]
+	if south < -90 || north > 90 {
+		v.add("bbox", "latitudes [%f, %f] are outside [-90, 90]", south, north)
+	}
+
+	if !v.strict {
+		return nil
+	}
+
+	extent, err := geometryBBox(feat.Geometry)
+	if err != nil {
+		return err
+	}
+
+	if !bboxCovers(feat.BBox, extent) {
+		v.add("bbox", "the declared bbox %v does not cover the geometry extent %v", feat.BBox, extent)
+	}
+
+	return nil
+}
+
+// normalizeCoord wraps the longitude into [-180, 180] and clamps the latitude
+// to [-90, 90].
+func normalizeCoord(coord geos.Coord) geos.Coord {
+
+	if coord.X < -180 || coord.X > 180 {
+		coord.X = math.Mod(coord.X+180, 360)
+		if coord.X < 0 {
+			coord.X += 360
+		}
+		coord.X -= 180
+	}
+
+	coord.Y = math.Max(-90, math.Min(90, coord.Y))
+
+	return coord
+}
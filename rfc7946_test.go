@@ -0,0 +1,113 @@
+package terra
+
+import (
+	"testing"
+
+	"github.com/paulsmith/gogeos/geos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidate(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("given a clockwise polygon", t, func() {
+
+		// Broadly represents North America, wound clockwise.
+		polygon, err := NewPolygon([][][]float64{
+			[][]float64{
+				{-139.75, 55.03},
+				{-51.28, 55.03},
+				{-51.28, 23.73},
+				{-139.75, 23.73},
+				{-139.75, 55.03},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		Convey("should pass lenient validation", func() {
+			So(polygon.Validate(false), ShouldBeNil)
+		})
+
+		Convey("should report the winding in strict validation", func() {
+			err := polygon.Validate(true)
+			So(err, ShouldNotBeNil)
+			violations := err.(*ValidationError).Violations
+			So(len(violations), ShouldEqual, 1)
+			So(violations[0].Path, ShouldEqual, "geometry.coordinates[0]")
+		})
+
+		Convey("should rewind on output", func() {
+			encoded, err := polygon.ToJSON(WithRFC7946())
+			So(err, ShouldBeNil)
+			rewound, err := NewFeatureFromJSON(encoded)
+			So(err, ShouldBeNil)
+			So(rewound.Validate(true), ShouldBeNil)
+		})
+	})
+
+	Convey("should cut a polygon written beyond the antimeridian on output", t, func() {
+
+		polygon, err := NewPolygon([][][]float64{
+			[][]float64{
+				{179.7, -17.0},
+				{180.2, -17.0},
+				{180.2, -16.6},
+				{179.7, -16.6},
+				{179.7, -17.0},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		encoded, err := polygon.ToJSON(WithRFC7946())
+		So(err, ShouldBeNil)
+		cut, err := NewFeatureFromJSON(encoded)
+		So(err, ShouldBeNil)
+		So(cut.Type, ShouldEqual, "MultiPolygon")
+		So(cut.Validate(true), ShouldBeNil)
+
+		area, err := cut.Geometry.Area()
+		So(err, ShouldBeNil)
+		So(area, ShouldAlmostEqual, 0.2, 0.0000001)
+	})
+
+	Convey("should report every out of range position", t, func() {
+
+		polygon, err := NewPolygon([][][]float64{
+			[][]float64{
+				{170, 10},
+				{190, 10},
+				{190, 95},
+				{170, 10},
+			},
+		})
+		So(err, ShouldBeNil)
+
+		err = polygon.Validate(false)
+		So(err, ShouldNotBeNil)
+		So(len(err.(*ValidationError).Violations), ShouldEqual, 3)
+	})
+
+	Convey("should reject unclosed rings on decode", t, func() {
+		_, err := NewPolygon([][][]float64{
+			[][]float64{
+				{170, 10},
+				{175, 10},
+				{175, 15},
+				{170, 15},
+			},
+		})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestNormalizeCoord(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should wrap longitudes and clamp latitudes", t, func() {
+		So(normalizeCoord(geos.NewCoord(190, 10)), ShouldResemble, geos.NewCoord(-170, 10))
+		So(normalizeCoord(geos.NewCoord(-540, -95)), ShouldResemble, geos.NewCoord(-180, -90))
+		So(normalizeCoord(geos.NewCoord(180, 90)), ShouldResemble, geos.NewCoord(180, 90))
+	})
+}
@@ -0,0 +1,327 @@
+package terra
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/saleswise/errors/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// PropertyType is the kind of value a schema field holds.
+type PropertyType int
+
+const (
+	// AnyType accepts every value.
+	AnyType PropertyType = iota
+	StringType
+	NumberType
+	// IntegerType accepts numbers without a fractional part.
+	IntegerType
+	BooleanType
+	// TimeType accepts times and strings PropertyTime can parse.
+	TimeType
+	ObjectType
+	ArrayType
+)
+
+func (t PropertyType) String() string {
+	switch t {
+	case StringType:
+		return "string"
+	case NumberType:
+		return "number"
+	case IntegerType:
+		return "integer"
+	case BooleanType:
+		return "boolean"
+	case TimeType:
+		return "time"
+	case ObjectType:
+		return "object"
+	case ArrayType:
+		return "array"
+	}
+	return "any"
+}
+
+// Field constrains the property at a path, such as "unit_name" or
+// "address.city".
+type Field struct {
+	Name     string
+	Type     PropertyType
+	Required bool
+	// Enum lists the values allowed, if not empty. Numbers compare by value.
+	Enum []interface{}
+	// Min and Max bound numbers, when set.
+	Min, Max *float64
+}
+
+// Schema describes the properties and geometry of the features a store holds.
+type Schema struct {
+	Fields []Field
+	// GeometryTypes lists the GeoJSON geometry types allowed, if not empty.
+	GeometryTypes []string
+	// AllowUnknown accepts properties no field names, which are otherwise
+	// rejected to catch misspellings.
+	AllowUnknown bool
+}
+
+// SchemaError reports every way a feature fails to match a schema.
+type SchemaError struct {
+	ID         string
+	Violations []Violation
+}
+
+func (e *SchemaError) Error() string {
+	reasons := make([]string, len(e.Violations))
+	for i := range e.Violations {
+		reasons[i] = e.Violations[i].String()
+	}
+	return fmt.Sprintf("Feature %s does not match the schema: %s.", e.ID, strings.Join(reasons, "; "))
+}
+
+// Validate checks the feature against the schema and returns a *SchemaError
+// reporting every violation found.
+func (s *Schema) Validate(feat *Feature) error {
+
+	var violations []Violation
+	add := func(path, format string, args ...interface{}) {
+		violations = append(violations, Violation{Path: path, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.GeometryTypes) > 0 && !isReservedMember(feat.Type, s.GeometryTypes) {
+		add("geometry", "is a %s, not one of %s", feat.Type, strings.Join(s.GeometryTypes, ", "))
+	}
+
+	known := make(map[string]bool, len(s.Fields))
+	for _, field := range s.Fields {
+		segments, err := parsePropertyPath(field.Name)
+		if err != nil {
+			return err
+		}
+		known[field.Name], known[segments[0].key] = true, true
+
+		value, err := feat.LookupProperty(field.Name)
+		if err != nil || value == nil {
+			if field.Required {
+				add(field.Name, "is required")
+			}
+			continue
+		}
+		if !field.Type.accepts(value) {
+			add(field.Name, "is %#v, which is not of type %s", value, field.Type)
+			continue
+		}
+		if len(field.Enum) > 0 && !containsProperty(field.Enum, value) {
+			add(field.Name, "is %#v, which is not one of %v", value, field.Enum)
+		}
+		if number, ok := propertyNumber(value); ok {
+			if field.Min != nil && number < *field.Min {
+				add(field.Name, "is %v, below the minimum of %v", number, *field.Min)
+			}
+			if field.Max != nil && number > *field.Max {
+				add(field.Name, "is %v, above the maximum of %v", number, *field.Max)
+			}
+		}
+	}
+
+	if !s.AllowUnknown {
+		var unknown []string
+		for name := range feat.Properties {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		for _, name := range unknown {
+			add(name, "is not in the schema")
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return &SchemaError{ID: feat.ID, Violations: violations}
+}
+
+func containsProperty(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if propertiesEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts reports whether a non-null value is of the type, without the
+// coercion the typed accessors apply.
+func (t PropertyType) accepts(value interface{}) bool {
+
+	switch t {
+	case AnyType:
+		return true
+	case StringType:
+		_, ok := value.(string)
+		return ok
+	case NumberType:
+		_, ok := propertyNumber(value)
+		return ok
+	case IntegerType:
+		number, ok := propertyNumber(value)
+		return ok && number == math.Trunc(number)
+	case BooleanType:
+		_, ok := value.(bool)
+		return ok
+	case TimeType:
+		switch value.(type) {
+		case time.Time, string:
+			_, ok := coerceTime(value)
+			return ok
+		}
+		return false
+	case ObjectType:
+		return reflect.ValueOf(value).Kind() == reflect.Map
+	case ArrayType:
+		return reflect.ValueOf(value).Kind() == reflect.Slice
+	}
+
+	return false
+}
+
+// typeOf returns the most specific type of a non-null value.
+func typeOf(value interface{}) PropertyType {
+	for _, t := range []PropertyType{IntegerType, NumberType, BooleanType, TimeType, StringType, ObjectType, ArrayType} {
+		if t.accepts(value) {
+			return t
+		}
+	}
+	return AnyType
+}
+
+// unifyTypes returns the most specific type accepting values of both.
+func unifyTypes(a, b PropertyType) PropertyType {
+	switch {
+	case a == b:
+		return a
+	case a == IntegerType && b == NumberType, a == NumberType && b == IntegerType:
+		return NumberType
+	case a == TimeType && b == StringType, a == StringType && b == TimeType:
+		return StringType
+	}
+	return AnyType
+}
+
+// InferSchema derives a schema from the top level properties and geometry
+// types of a collection. Each property gets the most specific type its values
+// share, any if it is only ever null, and is required if no feature lacks it
+// or holds null. Enumerations and ranges are left for the caller to add.
+func InferSchema(coll FeatureCollection) (*Schema, error) {
+
+	if len(coll) == 0 {
+		return nil, errors.New("Unable to infer a schema from an empty feature collection.")
+	}
+
+	var (
+		names         []string
+		seen          = make(map[string]bool)
+		types         = make(map[string]PropertyType)
+		counts        = make(map[string]int)
+		geometryTypes = make(map[string]bool)
+	)
+
+	for _, feat := range coll {
+		if feat.Type != "" {
+			geometryTypes[feat.Type] = true
+		}
+		for name, value := range feat.Properties {
+			if _, ok := seen[name]; !ok {
+				names = append(names, name)
+				seen[name] = true
+			}
+			if value == nil {
+				continue
+			}
+			t := typeOf(value)
+			if existing, ok := types[name]; ok {
+				t = unifyTypes(existing, t)
+			}
+			types[name] = t
+			counts[name]++
+		}
+	}
+
+	sort.Strings(names)
+
+	schema := &Schema{}
+	for _, name := range names {
+		schema.Fields = append(schema.Fields, Field{
+			Name:     name,
+			Type:     types[name],
+			Required: counts[name] == len(coll),
+		})
+	}
+	for typer := range geometryTypes {
+		schema.GeometryTypes = append(schema.GeometryTypes, typer)
+	}
+	sort.Strings(schema.GeometryTypes)
+
+	return schema, nil
+}
+
+// schemaDeclaration is the key of the schema in the index database.
+var schemaDeclaration = []byte("s\x00")
+
+// SetSchema sets the schema Add and Update enforce, or with nil removes it,
+// saving it with the store. Features already stored are not checked.
+func (g *Geostore) SetSchema(schema *Schema) error {
+
+	if schema == nil {
+		if err := g.indexDB.Delete(schemaDeclaration, nil); err != nil {
+			return errors.Wrap(err, "could not remove schema")
+		}
+		g.schema = nil
+		return nil
+	}
+
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal schema")
+	}
+	if err := g.indexDB.Put(schemaDeclaration, encoded, nil); err != nil {
+		return errors.Wrap(err, "could not save schema")
+	}
+	g.schema = schema
+
+	return nil
+}
+
+// loadSchema reads the schema saved with the store, if any.
+func (g *Geostore) loadSchema() error {
+
+	encoded, err := g.indexDB.Get(schemaDeclaration, nil)
+	if err == leveldb.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not read schema")
+	}
+
+	schema := &Schema{}
+	if err := json.Unmarshal(encoded, schema); err != nil {
+		return errors.Wrap(err, "could not unmarshal schema")
+	}
+	g.schema = schema
+
+	return nil
+}
+
+// Schema returns the schema the store enforces, if any.
+func (g *Geostore) Schema() *Schema {
+	return g.schema
+}
@@ -0,0 +1,129 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchema(t *testing.T) {
+
+	t.Parallel()
+
+	minimum, maximum := 0.0, 5.0
+	schema := &Schema{
+		Fields: []Field{
+			{Name: "unit_name", Type: StringType, Required: true},
+			{Name: "unit_code", Type: StringType, Required: true},
+			{Name: "region", Type: StringType, Enum: []interface{}{"Pacific West", "Intermountain"}},
+			{Name: "rating", Type: NumberType, Min: &minimum, Max: &maximum},
+			{Name: "address.zip", Type: IntegerType},
+		},
+		GeometryTypes: []string{"Polygon", "MultiPolygon"},
+	}
+
+	Convey("should accept a matching feature", t, func() {
+		feat := &Feature{ID: "CRLA", Type: "Polygon", Properties: map[string]interface{}{
+			"unit_name": "Crater Lake",
+			"unit_code": "CRLA",
+			"region":    "Pacific West",
+			"rating":    4.8,
+			"address":   map[string]interface{}{"zip": float64(97604)},
+		}}
+		So(schema.Validate(feat), ShouldBeNil)
+	})
+
+	Convey("should report every violation", t, func() {
+		feat := &Feature{ID: "BAD", Type: "Point", Properties: map[string]interface{}{
+			"unit_nmae": "Crater Lake",
+			"unit_code": "CRLA",
+			"region":    "Alaska",
+			"rating":    "4.8",
+			"address":   map[string]interface{}{"zip": 97604.5},
+		}}
+		err := schema.Validate(feat)
+		So(err, ShouldNotBeNil)
+		violations := err.(*SchemaError).Violations
+		So(violations, ShouldResemble, []Violation{
+			{Path: "geometry", Reason: "is a Point, not one of Polygon, MultiPolygon"},
+			{Path: "unit_name", Reason: "is required"},
+			{Path: "region", Reason: `is "Alaska", which is not one of [Pacific West Intermountain]`},
+			{Path: "rating", Reason: `is "4.8", which is not of type number`},
+			{Path: "address.zip", Reason: "is 97604.5, which is not of type integer"},
+			{Path: "unit_nmae", Reason: "is not in the schema"},
+		})
+	})
+
+	Convey("should check ranges", t, func() {
+		feat := &Feature{ID: "HIGH", Type: "Polygon", Properties: map[string]interface{}{
+			"unit_name": "Zion", "unit_code": "ZION", "rating": 7,
+		}}
+		So(schema.Validate(feat).Error(), ShouldEqual, "Feature HIGH does not match the schema: rating: is 7, above the maximum of 5.")
+	})
+
+	Convey("should infer a schema from a collection", t, func() {
+		inferred, err := InferSchema(FeatureCollection{
+			{Type: "Polygon", Properties: map[string]interface{}{"name": "a", "visitors": float64(10), "opened": "1916-08-25", "note": nil}},
+			{Type: "MultiPolygon", Properties: map[string]interface{}{"name": "b", "visitors": 2.5, "opened": "unknown"}},
+		})
+		So(err, ShouldBeNil)
+		So(inferred.GeometryTypes, ShouldResemble, []string{"MultiPolygon", "Polygon"})
+		So(inferred.Fields, ShouldResemble, []Field{
+			{Name: "name", Type: StringType, Required: true},
+			{Name: "note", Type: AnyType},
+			{Name: "opened", Type: StringType, Required: true},
+			{Name: "visitors", Type: NumberType, Required: true},
+		})
+
+		_, err = InferSchema(FeatureCollection{})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("should be enforced by the store", t, func() {
+		store, err := OpenGeostore("./geostore-schema")
+		So(err, ShouldBeNil)
+		So(store.SetSchema(&Schema{Fields: []Field{{Name: "name", Type: StringType, Required: true}}}), ShouldBeNil)
+
+		point, err := NewPoint(45.52, -122.68)
+		So(err, ShouldBeNil)
+		_, err = store.Add(point)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "name: is required")
+
+		point.SetProperty("name", "Portland")
+		_, err = store.Add(point)
+		So(err, ShouldBeNil)
+
+		point.SetProperty("name", 1)
+		So(store.Update([]byte(point.ID), point), ShouldNotBeNil)
+
+		Convey("should check the feature as stored", func() {
+			So(store.SetSchema(&Schema{GeometryTypes: []string{"Polygon"}, AllowUnknown: true}), ShouldBeNil)
+			taveuni, err := NewPolygon([][][]float64{{
+				{179.7, -17.0}, {-179.8, -17.0}, {-179.8, -16.6}, {179.7, -16.6}, {179.7, -17.0},
+			}})
+			So(err, ShouldBeNil)
+			_, err = store.Add(taveuni)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "MultiPolygon")
+		})
+
+		Convey("should be saved with the store", func() {
+			So(store.Close(), ShouldBeNil)
+			store, err = OpenGeostore("./geostore-schema")
+			So(err, ShouldBeNil)
+			So(store.Schema(), ShouldResemble, &Schema{Fields: []Field{{Name: "name", Type: StringType, Required: true}}})
+
+			So(store.SetSchema(nil), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+			store, err = OpenGeostore("./geostore-schema")
+			So(err, ShouldBeNil)
+			So(store.Schema(), ShouldBeNil)
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
@@ -3,6 +3,7 @@ package terra
 import (
 	"fmt"
 	"path"
+	"sort"
 
 	"github.com/dhconnelly/rtreego"
 	"github.com/syndtr/goleveldb/leveldb"
@@ -13,9 +14,31 @@ import (
 
 // Geostore represents ...
 type Geostore struct {
-	directory string
-	cache *leveldb.DB
-	tree *rtreego.Rtree
+	directory  string
+	cache      *leveldb.DB
+	tree       *rtreego.Rtree
+	entries    map[string][]*treeEntry
+	policy     IngestPolicy
+	schema     *Schema
+	indexDB    *leveldb.DB
+	indexed    map[string]bool
+	textFields map[string]bool
+	hierarchy  bool
+	prepared   preparedCache
+	contexts   geosContexts
+	cells      *cellIndex
+}
+
+// treeEntry is a single rectangle of a feature in the rtree. Features crossing
+// the antimeridian are indexed under one entry either side of it.
+type treeEntry struct {
+	key     string
+	feature *Feature
+	rect    *rtreego.Rect
+}
+
+func (e *treeEntry) Bounds() *rtreego.Rect {
+	return e.rect
 }
 
 // OpenGeostore creates ...
@@ -35,20 +58,31 @@ func OpenGeostore(directory string) (*Geostore, error) {
 		return nil, errors.Wrapf(err, "Unable to open the directory: %s.", store.directory)
 	}
 
+	if err := store.openIndexes(path.Join(path.Dir(store.directory), "indexes")); err != nil {
+		store.cache.Close()
+		return nil, err
+	}
+
 	store.tree = rtreego.NewTree(2, 25, 50)
+	store.entries = make(map[string][]*treeEntry)
 
 	iter := store.cache.NewIterator(nil, nil)
 	defer iter.Release()
 	for iter.Next() {
-		feature, err := NewFeatureFromJSON(iter.Value())
+		feature, err := decodeStored(iter.Key(), iter.Value())
 		if err != nil {
 			return nil, err
 		}
-		store.tree.Insert(feature)
+		if err := store.index(string(iter.Key()), feature); err != nil {
+			return nil, err
+		}
 	}
 	if iter.Error() != nil {
 		return nil, errors.Wrapf(err, "error iterating through store")
 	}
+	if err := store.loadCells(); err != nil {
+		return nil, err
+	}
 	return &store, nil
 }
 
@@ -56,6 +90,11 @@ func (g *Geostore) Close() error {
 	//if err := g.cache.Close(); err != nil && err != leveldb.ErrClosed {
 	//	return errors.Wrap(err, "could not close geostore")
 	//}
+	g.contexts.destroy()
+	if err := g.indexDB.Close(); err != nil {
+		g.cache.Close()
+		return err
+	}
 	return g.cache.Close()
 }
 
@@ -78,18 +117,35 @@ func (g *Geostore) Add(features ...*Feature) ([]string, error) {
 			continue
 		}
 
-		value, err := features[i].ToJSON()
+		feature, err := g.prepare(features[i])
 		if err != nil {
 			return nil, err
 		}
 
-		if err := g.cache.Put([]byte(features[i].ID), value, nil); err != nil {
+		value, err := feature.ToJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := g.cache.Put([]byte(feature.ID), value, nil); err != nil {
 			return nil, errors.Wrap(err, "could not put feature in cache")
 		}
 
-		g.tree.Insert(features[i])
+		g.unindex(feature.ID)
+		if err := g.index(feature.ID, feature); err != nil {
+			return nil, err
+		}
+		if err := g.indexProperties(feature.ID, feature); err != nil {
+			return nil, err
+		}
+		if err := g.updateHierarchy(feature.ID, feature); err != nil {
+			return nil, err
+		}
+		if err := g.updateCells(feature.ID, feature); err != nil {
+			return nil, err
+		}
 
-		keys = append(keys, features[i].ID)
+		keys = append(keys, feature.ID)
 
 	}
 
@@ -99,46 +155,118 @@ func (g *Geostore) Add(features ...*Feature) ([]string, error) {
 // Update ...
 func (g *Geostore) Update(key []byte, feature *Feature) error {
 
-	value, err := feature.ToJSON()
+	feature, err := g.prepare(feature)
 	if err != nil {
 		return err
 	}
 
-	feat, _ := g.Get(key)
-	empty, err := feat.IsEmpty()
+	value, err := feature.ToJSON()
 	if err != nil {
 		return err
 	}
-	if !empty && !g.tree.Delete(feat) {
-		return errors.Newf("Feature %s not found in rtree, and cannot be deleted.", feat)
-	}
 
 	if err := g.cache.Put(key, value, nil); err != nil {
 		return errors.Wrap(err, "could not add feature to cache")
 	}
-	g.tree.Insert(feature)
 
-	return nil
+	g.unindex(string(key))
+	if err := g.index(string(key), feature); err != nil {
+		return err
+	}
+
+	if err := g.indexProperties(string(key), feature); err != nil {
+		return err
+	}
+
+	if err := g.updateHierarchy(string(key), feature); err != nil {
+		return err
+	}
+
+	return g.updateCells(string(key), feature)
 }
 
 // Remove ...
 func (g *Geostore) Remove(key []byte) error {
 
-	feat, _ := g.Get(key)
-	empty, err := feat.IsEmpty()
-	if err != nil {
+	g.unindex(string(key))
+
+	if err := g.cache.Delete(key, nil); err != nil {
+		return errors.Wrap(err, "could not delete key")
+	}
+
+	if err := g.indexProperties(string(key), nil); err != nil {
 		return err
 	}
-	if !empty && !g.tree.Delete(feat) {
-		return errors.Newf("Feature %s not found in rtree, and cannot be deleted.", feat)
+
+	if err := g.updateHierarchy(string(key), nil); err != nil {
+		return err
 	}
 
-	if err := g.cache.Delete(key, nil); err != nil {
-		return errors.Wrap(err, "could not delete key")
+	return g.updateCells(string(key), nil)
+
+}
+
+// index inserts the feature into the rtree under each of its rectangles,
+// remembering the entries by store key so they can later be deleted.
+func (g *Geostore) index(key string, feature *Feature) error {
+
+	rects, err := feature.Rects()
+	if err != nil {
+		return err
+	}
+
+	for _, rect := range rects {
+		entry := &treeEntry{key: key, feature: feature, rect: rect}
+		g.tree.Insert(entry)
+		g.entries[key] = append(g.entries[key], entry)
 	}
 
 	return nil
+}
+
+// unindex removes every rtree entry of the keyed feature.
+func (g *Geostore) unindex(key string) {
+	for _, entry := range g.entries[key] {
+		g.tree.Delete(entry)
+	}
+	delete(g.entries, key)
+	g.prepared.forget(key)
+	g.contexts.forget(key)
+}
+
+// search returns the features with a rectangle intersecting any of the given
+// rectangles, each once.
+func (g *Geostore) search(rects []*rtreego.Rect) []*Feature {
+
+	seen := make(map[*Feature]bool)
+	features := []*Feature{}
+
+	for _, rect := range rects {
+		for _, spatial := range g.tree.SearchIntersect(rect) {
+			f := spatial.(*treeEntry).feature
+			if seen[f] {
+				continue
+			}
+			seen[f] = true
+			features = append(features, f)
+		}
+	}
+
+	return features
+}
+
+// searchKeys returns the store keys of the features with a rectangle
+// intersecting any of the given rectangles.
+func (g *Geostore) searchKeys(rects []*rtreego.Rect) map[string]bool {
+
+	keys := make(map[string]bool)
+	for _, rect := range rects {
+		for _, spatial := range g.tree.SearchIntersect(rect) {
+			keys[spatial.(*treeEntry).key] = true
+		}
+	}
 
+	return keys
 }
 
 // Get ...
@@ -147,18 +275,35 @@ func (g *Geostore) Get(key []byte) (*Feature, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "could not get data in cache")
 	}
-	return NewFeatureFromJSON(response)
+	return decodeStored(key, response)
+}
+
+// decodeStored decodes the feature stored under the key. One stored without
+// an id takes the key as its ID, and is still written without one.
+func decodeStored(key, value []byte) (*Feature, error) {
+
+	feature, err := NewFeatureFromJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	if feature.generatedID != "" {
+		feature.ID, feature.generatedID = string(key), string(key)
+	}
+
+	return feature, nil
 }
 
 func (g *Geostore) Contains(feat *Feature) ([]*Feature, error) {
-	response := g.tree.SearchIntersect(feat.Bounds())
+	if list, ok, err := g.containsByCells(feat, nil); ok || err != nil {
+		return list, err
+	}
+	rects, err := feat.Rects()
+	if err != nil {
+		return nil, err
+	}
+	response := g.search(rects)
 	list := []*Feature{}
-	for i := range response {
-		// if f, ok = response[i].(Feature); !ok {
-		// 	log.Warning("Response from rtree could not be typed as a feature.")
-		// 	continue
-		// }
-		f := response[i].(*Feature)
+	for _, f := range response {
 		ok, err := f.Contains(feat)
 		if err != nil {
 			return nil, err
@@ -171,6 +316,60 @@ func (g *Geostore) Contains(feat *Feature) ([]*Feature, error) {
 	return list, nil
 }
 
+// WithinDistance returns the stored features within the given number of
+// meters of the feature, measured on the WGS84 ellipsoid, nearest first.
+// Candidates are first tested against a geodesic buffer of the feature, so
+// that only those within it are measured, and then kept by their exact
+// distance, as the buffer only approximates it.
+func (g *Geostore) WithinDistance(feat *Feature, meters float64) ([]*Feature, error) {
+
+	bbox, err := feat.wgs84BoundingBox()
+	if err != nil {
+		return nil, err
+	}
+
+	rects, err := bboxToRects(expandBBox(bbox, meters))
+	if err != nil {
+		return nil, err
+	}
+
+	// Pad the buffer so its approximation never drops a feature within reach.
+	buffer, err := feat.GeodesicBuffer(meters*1.01+1, DropProperties)
+	if err != nil {
+		return nil, err
+	}
+	prepared := buffer.Geometry.Prepare()
+
+	var (
+		list      = []*Feature{}
+		distances = make(map[*Feature]float64)
+	)
+	for _, f := range g.search(rects) {
+		ok, err := prepared.Intersects(f.Geometry)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not test feature against buffer")
+		}
+		if !ok {
+			continue
+		}
+		distance, err := f.Distance(feat)
+		if err != nil {
+			return nil, err
+		}
+		if distance > meters {
+			continue
+		}
+		distances[f] = distance
+		list = append(list, f)
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return distances[list[i]] < distances[list[j]]
+	})
+
+	return list, nil
+}
+
 // Contains ...
 // func (g *Geostore) Contains(feat *Feature) (list []*Feature, er error) {
 //
@@ -223,8 +422,17 @@ func (g *Geostore) Clear() (er error) {
 		er = iter.Error()
 		return
 	}
-	er = g.cache.Write(batch, nil)
+	if er = g.cache.Write(batch, nil); er != nil {
+		return
+	}
+	er = g.clearIndexes()
 	g.tree = rtreego.NewTree(2, 25, 50)
+	g.entries = make(map[string][]*treeEntry)
+	g.prepared.reset()
+	g.contexts.reset()
+	if g.cells != nil {
+		g.cells = &cellIndex{level: g.cells.level, cells: make(map[string]map[string]bool), uncovered: make(map[string]bool)}
+	}
 	return
 }
 
@@ -238,8 +446,7 @@ func (g *Geostore) Length() (int, error) {
 	if iter.Error() != nil {
 		return 0, iter.Error()
 	}
-	treeSize := g.tree.Size()
-	if treeSize != count {
+	if len(g.entries) != count {
 		return 0, fmt.Errorf("Expected both the store and the rtree to have length %d.", count)
 	}
 	return count, nil
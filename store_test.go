@@ -183,6 +183,31 @@ func TestStore(t *testing.T) {
 
 
 
+		Convey("should write stored ids back as they were decoded", func() {
+
+			numbered, err := NewFeatureFromJSON([]byte(`{"type": "Feature", "id": 5, "properties": null, "geometry": {"type": "Point", "coordinates": [-63.05, 18.22]}}`))
+			So(err, ShouldBeNil)
+			anonymous, err := NewFeatureFromJSON([]byte(`{"type": "Feature", "properties": null, "geometry": {"type": "Point", "coordinates": [-63.06, 18.21]}}`))
+			So(err, ShouldBeNil)
+
+			keys, err := store.Add(numbered, anonymous)
+			So(err, ShouldBeNil)
+			So(keys, ShouldResemble, []string{"5", anonymous.ID})
+
+			stored, err := store.Get([]byte("5"))
+			So(err, ShouldBeNil)
+			encoded, err := stored.ToJSON()
+			So(err, ShouldBeNil)
+			So(string(encoded), ShouldContainSubstring, `"id":5,`)
+
+			stored, err = store.Get([]byte(anonymous.ID))
+			So(err, ShouldBeNil)
+			So(stored.ID, ShouldEqual, anonymous.ID)
+			encoded, err = stored.ToJSON()
+			So(err, ShouldBeNil)
+			So(string(encoded), ShouldNotContainSubstring, `"id"`)
+		})
+
 		Reset(func() {
 			So(store.Clear(), ShouldBeNil)
 			So(store.Close(), ShouldBeNil)
@@ -0,0 +1,522 @@
+package terra
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// The text index shares the property index database, under three more kinds
+// of key:
+//
+//	t\x00<path>                  a property the text index covers
+//	w\x00<term>\x00<store key>   a feature using a term, and how often
+//	x\x00<store key>\x00<term>   the posting, for removing it later
+var (
+	textFieldPrefix   = []byte("t\x00")
+	textPostingPrefix = []byte("w\x00")
+	textReversePrefix = []byte("x\x00")
+)
+
+// foldAccents replaces the common accented Latin letters with plain ones, so
+// that "Curaçao" is found by "curacao".
+var foldAccents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "ā", "a",
+	"ç", "c", "ć", "c", "č", "c", "đ", "d",
+	"è", "e", "é", "e", "ê", "e", "ë", "e", "ē", "e", "ě", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ī", "i",
+	"ł", "l", "ñ", "n", "ń", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "ō", "o",
+	"ř", "r", "ś", "s", "š", "s", "ß", "ss",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ū", "u", "ů", "u",
+	"ý", "y", "ÿ", "y", "ž", "z", "ź", "z", "ż", "z",
+	"æ", "ae", "œ", "oe",
+)
+
+// normalizeText lower cases and folds text for comparison.
+func normalizeText(text string) string {
+	return foldAccents.Replace(strings.ToLower(text))
+}
+
+// tokenize splits normalized text into runs of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(normalizeText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// textValues returns the strings held by a property, or by its elements if it
+// is an array.
+func textValues(value interface{}) []string {
+
+	if s, ok := value.(string); ok {
+		return []string{s}
+	}
+
+	var values []string
+	if v := reflect.ValueOf(value); value != nil && v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if s, ok := v.Index(i).Interface().(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// featureText returns the text of the feature's indexed properties.
+func (g *Geostore) featureText(feat *Feature) []string {
+
+	var values []string
+	for path := range g.textFields {
+		values = append(values, textValues(feat.Property(path))...)
+	}
+
+	return values
+}
+
+// CreateTextIndex adds properties, such as "name" or "name_long", to those
+// the text index covers, and rebuilds it from the features already stored.
+func (g *Geostore) CreateTextIndex(paths ...string) error {
+
+	if len(paths) == 0 {
+		return errors.New("A text index requires at least one property.")
+	}
+
+	batch := new(leveldb.Batch)
+	for _, path := range paths {
+		if _, err := parsePropertyPath(path); err != nil {
+			return err
+		}
+		batch.Put(append(append([]byte{}, textFieldPrefix...), path...), nil)
+	}
+	if err := g.deletePrefixes(batch, textPostingPrefix, textReversePrefix); err != nil {
+		return err
+	}
+
+	fields := make(map[string]bool, len(g.textFields)+len(paths))
+	for path := range g.textFields {
+		fields[path] = true
+	}
+	for _, path := range paths {
+		fields[path] = true
+	}
+	previous := g.textFields
+	g.textFields = fields
+
+	for key, entries := range g.entries {
+		g.putTextEntries(batch, key, entries[0].feature)
+	}
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		g.textFields = previous
+		return errors.Wrap(err, "could not build text index")
+	}
+
+	return nil
+}
+
+// DropTextIndex removes the text index.
+func (g *Geostore) DropTextIndex() error {
+
+	batch := new(leveldb.Batch)
+	if err := g.deletePrefixes(batch, textFieldPrefix, textPostingPrefix, textReversePrefix); err != nil {
+		return err
+	}
+	if err := g.indexDB.Write(batch, nil); err != nil {
+		return errors.Wrap(err, "could not drop text index")
+	}
+
+	g.textFields = make(map[string]bool)
+
+	return nil
+}
+
+// TextFields returns the properties the text index covers.
+func (g *Geostore) TextFields() []string {
+
+	paths := make([]string, 0, len(g.textFields))
+	for path := range g.textFields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// deletePrefixes adds the removal of every key with one of the prefixes to the
+// batch.
+func (g *Geostore) deletePrefixes(batch *leveldb.Batch, prefixes ...[]byte) error {
+
+	for _, prefix := range prefixes {
+		iter := g.indexDB.NewIterator(util.BytesPrefix(prefix), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte{}, iter.Key()...))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return errors.Wrap(err, "could not read indexes")
+		}
+	}
+
+	return nil
+}
+
+func textPostingKey(term, key string) []byte {
+	return append(append(append(append([]byte{}, textPostingPrefix...), term...), 0), key...)
+}
+
+func textReverseKeyPrefix(key string) []byte {
+	return append(append(append([]byte{}, textReversePrefix...), key...), 0)
+}
+
+func (g *Geostore) putTextEntries(batch *leveldb.Batch, key string, feat *Feature) {
+
+	counts := make(map[string]uint64)
+	for _, value := range g.featureText(feat) {
+		for _, term := range tokenize(value) {
+			counts[term]++
+		}
+	}
+
+	for term, count := range counts {
+		frequency := make([]byte, binary.MaxVarintLen64)
+		batch.Put(textPostingKey(term, key), frequency[:binary.PutUvarint(frequency, count)])
+		batch.Put(append(textReverseKeyPrefix(key), term...), nil)
+	}
+}
+
+// deleteTextEntries adds the removal of every posting of the keyed feature to
+// the batch.
+func (g *Geostore) deleteTextEntries(batch *leveldb.Batch, key string) error {
+
+	prefix := textReverseKeyPrefix(key)
+	iter := g.indexDB.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		batch.Delete(append([]byte{}, iter.Key()...))
+		batch.Delete(textPostingKey(string(iter.Key()[len(prefix):]), key))
+	}
+	if err := iter.Error(); err != nil {
+		return errors.Wrapf(err, "could not read text index entries of %s", key)
+	}
+
+	return nil
+}
+
+// postings returns how often each feature using the term does so.
+func (g *Geostore) postings(term string) (map[string]float64, error) {
+
+	prefix := textPostingKey(term, "")
+	postings := make(map[string]float64)
+
+	iter := g.indexDB.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		count, _ := binary.Uvarint(iter.Value())
+		postings[string(iter.Key()[len(prefix):])] = float64(count)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, errors.Wrapf(err, "could not read text index for %s", term)
+	}
+
+	return postings, nil
+}
+
+// terms calls fn with each distinct indexed term beginning with the prefix,
+// in order.
+func (g *Geostore) terms(prefix string, fn func(term string)) error {
+
+	start := append(append([]byte{}, textPostingPrefix...), prefix...)
+	iter := g.indexDB.NewIterator(util.BytesPrefix(start), nil)
+	defer iter.Release()
+	for ok := iter.Next(); ok; {
+		rest := iter.Key()[len(textPostingPrefix):]
+		end := 0
+		for end < len(rest) && rest[end] != 0 {
+			end++
+		}
+		term := string(rest[:end])
+		fn(term)
+		// Skip the rest of the term's postings.
+		ok = iter.Seek(append(append(append([]byte{}, textPostingPrefix...), term...), 1))
+	}
+	if err := iter.Error(); err != nil {
+		return errors.Wrap(err, "could not read text index terms")
+	}
+
+	return nil
+}
+
+// editDistance returns the Levenshtein distance between two terms, or max+1 if
+// it exceeds max.
+func editDistance(a, b []rune, max int) int {
+
+	if d := len(a) - len(b); d > max || -d > max {
+		return max + 1
+	}
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		smallest := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+			smallest = minInt(smallest, current[j])
+		}
+		if smallest > max {
+			return max + 1
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// SearchResult is a feature found by text, with its relevance.
+type SearchResult struct {
+	Feature *Feature
+	Score   float64
+}
+
+type searchOptions struct {
+	prefix, fuzzy bool
+	limit         int
+	bbox          []float64
+	intersecting  *Feature
+}
+
+// SearchOption changes how Search matches and ranks features.
+type SearchOption func(*searchOptions)
+
+// SearchPrefix matches the terms beginning with each word, as for
+// autocompletion, ranking them below exact matches.
+func SearchPrefix() SearchOption {
+	return func(o *searchOptions) {
+		o.prefix = true
+	}
+}
+
+// SearchFuzzy matches terms within one edit of words of four to seven letters,
+// and within two of longer words, ranking them below exact matches. Terms must
+// begin with the same letter as the word.
+func SearchFuzzy() SearchOption {
+	return func(o *searchOptions) {
+		o.fuzzy = true
+	}
+}
+
+// SearchLimit returns at most n results, ten unless set.
+func SearchLimit(n int) SearchOption {
+	return func(o *searchOptions) {
+		o.limit = n
+	}
+}
+
+// SearchBBox restricts results to features whose bounding boxes intersect the
+// bbox.
+func SearchBBox(bbox []float64) SearchOption {
+	return func(o *searchOptions) {
+		o.bbox = bbox
+	}
+}
+
+// SearchIntersecting restricts results to features whose geometries intersect
+// the feature's.
+func SearchIntersecting(feat *Feature) SearchOption {
+	return func(o *searchOptions) {
+		o.intersecting = feat
+	}
+}
+
+// Search finds the stored features whose text indexed properties use the
+// words of the text, most relevant first. Words rare among the features count
+// more than common ones, features using more of the words rank higher, and a
+// feature with a property equal to the whole text ranks highest.
+func (g *Geostore) Search(text string, options ...SearchOption) ([]SearchResult, error) {
+
+	opts := searchOptions{limit: 10}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if len(g.textFields) == 0 {
+		return nil, errors.New("There is no text index to search.")
+	}
+	words := tokenize(text)
+	if len(words) == 0 {
+		return nil, errors.Newf("The text %q has no words to search for.", text)
+	}
+
+	var allowed map[string]bool
+	if opts.bbox != nil {
+		rects, err := bboxToRects(opts.bbox)
+		if err != nil {
+			return nil, err
+		}
+		allowed = g.searchKeys(rects)
+	}
+	var prepared *geos.PGeometry
+	if opts.intersecting != nil {
+		rects, err := opts.intersecting.Rects()
+		if err != nil {
+			return nil, err
+		}
+		keys := g.searchKeys(rects)
+		if allowed != nil {
+			for key := range keys {
+				if !allowed[key] {
+					delete(keys, key)
+				}
+			}
+		}
+		allowed = keys
+		prepared = opts.intersecting.Geometry.Prepare()
+	}
+
+	var (
+		total   = float64(len(g.entries))
+		scores  = make(map[string]float64)
+		matched = make(map[string]int)
+	)
+	for _, word := range words {
+		best := make(map[string]float64)
+		score := func(term string, weight float64) error {
+			postings, err := g.postings(term)
+			if err != nil {
+				return err
+			}
+			idf := 1 + math.Log(total/float64(len(postings)+1))
+			for key, count := range postings {
+				if allowed != nil && !allowed[key] {
+					continue
+				}
+				if s := weight * idf * math.Sqrt(count); s > best[key] {
+					best[key] = s
+				}
+			}
+			return nil
+		}
+
+		if err := score(word, 1); err != nil {
+			return nil, err
+		}
+		var variants []string
+		var weights []float64
+		if opts.prefix {
+			err := g.terms(word, func(term string) {
+				if term != word {
+					variants, weights = append(variants, term), append(weights, 0.8)
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if max := fuzziness(word); opts.fuzzy && max > 0 {
+			target := []rune(word)
+			first, _ := utf8.DecodeRuneInString(word)
+			err := g.terms(string(first), func(term string) {
+				if d := editDistance(target, []rune(term), max); d > 0 && d <= max {
+					variants, weights = append(variants, term), append(weights, 1-0.3*float64(d))
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		for i := range variants {
+			if err := score(variants[i], weights[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		for key, s := range best {
+			scores[key] += s
+			matched[key]++
+		}
+	}
+
+	whole := strings.Join(words, " ")
+	results := []SearchResult{}
+	for key, s := range scores {
+		if len(g.entries[key]) == 0 {
+			continue
+		}
+		feat := g.entries[key][0].feature
+		if prepared != nil {
+			ok, err := prepared.Intersects(feat.Geometry)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not test feature against search extent")
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		length, exact := 0, false
+		for _, value := range g.featureText(feat) {
+			tokens := tokenize(value)
+			length += len(tokens)
+			exact = exact || strings.Join(tokens, " ") == whole
+		}
+		s = s / math.Sqrt(float64(maxInt(length, 1))) * float64(matched[key]) / float64(len(words))
+		if exact {
+			s *= 2
+		}
+		results = append(results, SearchResult{Feature: feat, Score: s})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Feature.ID < results[j].Feature.ID
+	})
+	if opts.limit > 0 && len(results) > opts.limit {
+		results = results[:opts.limit]
+	}
+
+	return results, nil
+}
+
+// fuzziness returns the edits allowed in matching a word.
+func fuzziness(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,115 @@
+package terra
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTextSearch(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should tokenize and fold text", t, func() {
+		So(tokenize("Curaçao, Kingdom of the Netherlands"), ShouldResemble, []string{"curacao", "kingdom", "of", "the", "netherlands"})
+		So(tokenize("São Tomé & Príncipe"), ShouldResemble, []string{"sao", "tome", "principe"})
+		So(tokenize(" -- "), ShouldBeEmpty)
+	})
+
+	Convey("should measure edit distances", t, func() {
+		So(editDistance([]rune("anguilla"), []rune("angilla"), 2), ShouldEqual, 1)
+		So(editDistance([]rune("yosemite"), []rune("yosmeite"), 2), ShouldEqual, 2)
+		So(editDistance([]rune("aruba"), []rune("cuba"), 1), ShouldEqual, 2)
+		So(editDistance([]rune("a"), []rune("abcd"), 1), ShouldEqual, 2)
+	})
+
+	Convey("given a store with a text index", t, func() {
+
+		store, err := OpenGeostore("./geostore-text")
+		So(err, ShouldBeNil)
+
+		places := []struct {
+			id, name, long string
+			lat, lng       float64
+		}{
+			{"AIA", "Anguilla", "Anguilla", 18.22, -63.05},
+			{"ABW", "Aruba", "Aruba", 12.52, -70.03},
+			{"CUW", "Curaçao", "Curaçao", 12.17, -68.98},
+			{"YOSE", "Yosemite", "Yosemite National Park", 37.86, -119.54},
+			{"YOSEV", "Yosemite Valley", "Yosemite Valley Lodge", 37.74, -119.59},
+		}
+		for _, place := range places {
+			point, err := NewPoint(place.lat, place.lng)
+			So(err, ShouldBeNil)
+			point.ID = place.id
+			point.Properties = map[string]interface{}{"name": place.name, "name_long": place.long}
+			_, err = store.Add(point)
+			So(err, ShouldBeNil)
+		}
+		So(store.CreateTextIndex("name", "name_long"), ShouldBeNil)
+
+		ids := func(results []SearchResult) []string {
+			list := []string{}
+			for _, result := range results {
+				list = append(list, result.Feature.ID)
+			}
+			return list
+		}
+
+		Convey("should rank exact names first", func() {
+			results, err := store.Search("Yosemite")
+			So(err, ShouldBeNil)
+			So(ids(results), ShouldResemble, []string{"YOSE", "YOSEV"})
+
+			results, err = store.Search("yosemite valley")
+			So(err, ShouldBeNil)
+			So(ids(results)[0], ShouldEqual, "YOSEV")
+
+			results, err = store.Search("curacao")
+			So(err, ShouldBeNil)
+			So(ids(results), ShouldResemble, []string{"CUW"})
+		})
+
+		Convey("should match prefixes and misspellings when asked", func() {
+			results, err := store.Search("ang")
+			So(err, ShouldBeNil)
+			So(results, ShouldBeEmpty)
+
+			results, err = store.Search("ang", SearchPrefix())
+			So(err, ShouldBeNil)
+			So(ids(results), ShouldResemble, []string{"AIA"})
+
+			results, err = store.Search("Angilla", SearchFuzzy())
+			So(err, ShouldBeNil)
+			So(ids(results), ShouldResemble, []string{"AIA"})
+		})
+
+		Convey("should restrict results to an extent", func() {
+			results, err := store.Search("yosemite", SearchBBox([]float64{-119.6, 37.7, -119.5, 37.8}))
+			So(err, ShouldBeNil)
+			So(ids(results), ShouldResemble, []string{"YOSEV"})
+		})
+
+		Convey("should follow updates and removals", func() {
+			point, err := NewPoint(18.22, -63.05)
+			So(err, ShouldBeNil)
+			point.ID = "AIA"
+			point.Properties = map[string]interface{}{"name": "Anguilla Island"}
+			So(store.Update([]byte("AIA"), point), ShouldBeNil)
+			results, err := store.Search("island")
+			So(err, ShouldBeNil)
+			So(ids(results), ShouldResemble, []string{"AIA"})
+
+			So(store.Remove([]byte("AIA")), ShouldBeNil)
+			results, err = store.Search("anguilla")
+			So(err, ShouldBeNil)
+			So(results, ShouldBeEmpty)
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.DropTextIndex(), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
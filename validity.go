@@ -0,0 +1,442 @@
+package terra
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// Invalidity describes why a geometry is not valid in the OGC Simple Features
+// sense, using the same reasons GEOS reports.
+type Invalidity struct {
+	Reason   string
+	Location geos.Coord
+}
+
+func (i *Invalidity) String() string {
+	return fmt.Sprintf("%s at or near point %f %f", i.Reason, i.Location.X, i.Location.Y)
+}
+
+// IsValid reports whether GEOS finds the feature geometry valid, and if not,
+// the problem and where it is. The problem is described from a scan of
+// polygons for self-intersecting or touching rings, holes outside their
+// shell, nested holes and, in a MultiPolygon, overlapping or nested shells,
+// or else as GEOS gives it.
+func (feat *Feature) IsValid() (bool, *Invalidity, error) {
+
+	if feat.Geometry == nil {
+		return false, nil, errors.New("The feature has no geometry to validate.")
+	}
+
+	invalid, err := findInvalidity(feat.Geometry)
+	if err != nil {
+		return false, nil, err
+	}
+	if invalid != nil && invalid.Reason == "Invalid Coordinate" {
+		// GEOS could not read such coordinates back, and rejects them anyway.
+		return false, invalid, nil
+	}
+
+	valid, reason, err := geosIsValid(feat.Geometry)
+	if err != nil {
+		return false, nil, err
+	}
+	if valid {
+		return true, nil, nil
+	}
+	if invalid == nil {
+		invalid = parseInvalidity(reason)
+	}
+
+	return false, invalid, nil
+}
+
+// parseInvalidity reads a reason as GEOS writes it, such as
+// "Self-intersection[1 2]".
+func parseInvalidity(reason string) *Invalidity {
+
+	invalid := &Invalidity{Reason: reason}
+
+	open := strings.LastIndex(reason, "[")
+	if open < 0 || !strings.HasSuffix(reason, "]") {
+		return invalid
+	}
+	if _, err := fmt.Sscanf(reason[open+1:len(reason)-1], "%g %g", &invalid.Location.X, &invalid.Location.Y); err == nil {
+		invalid.Reason = reason[:open]
+	}
+
+	return invalid
+}
+
+// findInvalidity scans the geometry for the first problem making it invalid,
+// returning nil if it finds none.
+func findInvalidity(geometry *geos.Geometry) (*Invalidity, error) {
+
+	parts, err := geometryParts(geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	var polygons [][][]geos.Coord
+	for _, part := range parts {
+		typer, err := part.Type()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get geometry type")
+		}
+		switch typer {
+		case geos.POLYGON:
+			rings, err := polygonRings(part)
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, rings)
+		default:
+			coords, err := geometryCoords(part)
+			if err != nil {
+				return nil, err
+			}
+			if invalid := checkCoordinates(coords); invalid != nil {
+				return invalid, nil
+			}
+			if typer == geos.LINESTRING && len(coords) == 1 {
+				return &Invalidity{Reason: "Too few points", Location: coords[0]}, nil
+			}
+		}
+	}
+
+	if invalid := checkPolygons(polygons); invalid != nil {
+		return invalid, nil
+	}
+
+	return nil, nil
+}
+
+func checkCoordinates(coords []geos.Coord) *Invalidity {
+	for _, c := range coords {
+		if math.IsNaN(c.X) || math.IsInf(c.X, 0) || math.IsNaN(c.Y) || math.IsInf(c.Y, 0) {
+			return &Invalidity{Reason: "Invalid Coordinate", Location: c}
+		}
+	}
+	return nil
+}
+
+type ringSegment struct {
+	a, b              geos.Coord
+	polygon, ring, at int
+	count             int
+}
+
+// checkPolygons validates the rings of one or more polygons.
+func checkPolygons(polygons [][][]geos.Coord) *Invalidity {
+
+	var segments []ringSegment
+	for p, rings := range polygons {
+		for r, ring := range rings {
+			if invalid := checkCoordinates(ring); invalid != nil {
+				return invalid
+			}
+			if len(ring) < 4 {
+				return &Invalidity{Reason: "Too few points", Location: ring[0]}
+			}
+			for i := 0; i+1 < len(ring); i++ {
+				segments = append(segments, ringSegment{
+					a: ring[i], b: ring[i+1],
+					polygon: p, ring: r, at: i,
+					count: len(ring) - 1,
+				})
+			}
+		}
+	}
+
+	if invalid := checkSegments(segments); invalid != nil {
+		return invalid
+	}
+
+	for p, rings := range polygons {
+		shell := rings[0]
+		for h, hole := range rings[1:] {
+			if point, ok := interiorVertex(hole, shell); ok && !pointInRing(point, shell) {
+				return &Invalidity{Reason: "Hole lies outside shell", Location: point}
+			}
+			for _, other := range rings[h+2:] {
+				if point, ok := interiorVertex(hole, other); ok && pointInRing(point, other) {
+					return &Invalidity{Reason: "Nested holes", Location: point}
+				}
+				if point, ok := interiorVertex(other, hole); ok && pointInRing(point, hole) {
+					return &Invalidity{Reason: "Nested holes", Location: point}
+				}
+			}
+		}
+		for _, other := range polygons[p+1:] {
+			if point, ok := interiorVertex(shell, other[0]); ok && pointInRing(point, other[0]) {
+				return &Invalidity{Reason: "Nested shells", Location: point}
+			}
+			if point, ok := interiorVertex(other[0], shell); ok && pointInRing(point, shell) {
+				return &Invalidity{Reason: "Nested shells", Location: point}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkSegments sweeps the segments from west to east, testing those whose
+// longitude ranges overlap for intersections rings may not have.
+func checkSegments(segments []ringSegment) *Invalidity {
+
+	sort.Slice(segments, func(i, j int) bool {
+		return math.Min(segments[i].a.X, segments[i].b.X) < math.Min(segments[j].a.X, segments[j].b.X)
+	})
+
+	for i := range segments {
+		s := segments[i]
+		east := math.Max(s.a.X, s.b.X)
+		for j := i + 1; j < len(segments); j++ {
+			t := segments[j]
+			if math.Min(t.a.X, t.b.X) > east {
+				break
+			}
+			if invalid := checkSegmentPair(s, t); invalid != nil {
+				return invalid
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkSegmentPair(s, t ringSegment) *Invalidity {
+
+	sameRing := s.polygon == t.polygon && s.ring == t.ring
+	adjacent := sameRing && (absInt(s.at-t.at) == 1 || absInt(s.at-t.at) == s.count-1)
+
+	point, kind := intersectSegments(s.a, s.b, t.a, t.b)
+	switch {
+	case kind == noIntersection:
+		return nil
+	case kind == overlappingSegments:
+		return &Invalidity{Reason: "Self-intersection", Location: point}
+	case adjacent:
+		// Adjacent segments share a vertex and may meet nowhere else.
+		return nil
+	case kind == crossingSegments:
+		return &Invalidity{Reason: "Self-intersection", Location: point}
+	case sameRing:
+		return &Invalidity{Reason: "Ring Self-intersection", Location: point}
+	}
+
+	// Different rings may touch at a point.
+	return nil
+}
+
+const (
+	noIntersection = iota
+	touchingSegments
+	crossingSegments
+	overlappingSegments
+)
+
+// intersectSegments classifies how segments p1p2 and q1q2 meet, returning a
+// point where they do.
+func intersectSegments(p1, p2, q1, q2 geos.Coord) (geos.Coord, int) {
+
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+
+	if d1 == 0 && d2 == 0 {
+		// Collinear, so find how much of each lies on the other.
+		var shared []geos.Coord
+		for _, c := range []geos.Coord{p1, p2} {
+			if onSegment(q1, q2, c) {
+				shared = append(shared, c)
+			}
+		}
+		for _, c := range []geos.Coord{q1, q2} {
+			if onSegment(p1, p2, c) {
+				shared = append(shared, c)
+			}
+		}
+		if len(shared) == 0 {
+			return geos.Coord{}, noIntersection
+		}
+		for _, c := range shared[1:] {
+			if c.X != shared[0].X || c.Y != shared[0].Y {
+				return shared[0], overlappingSegments
+			}
+		}
+		return shared[0], touchingSegments
+	}
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		t := d1 / (d1 - d2)
+		return geos.NewCoord(p1.X+t*(p2.X-p1.X), p1.Y+t*(p2.Y-p1.Y)), crossingSegments
+	}
+
+	switch {
+	case d1 == 0 && onSegment(q1, q2, p1):
+		return p1, touchingSegments
+	case d2 == 0 && onSegment(q1, q2, p2):
+		return p2, touchingSegments
+	case d3 == 0 && onSegment(p1, p2, q1):
+		return q1, touchingSegments
+	case d4 == 0 && onSegment(p1, p2, q2):
+		return q2, touchingSegments
+	}
+
+	return geos.Coord{}, noIntersection
+}
+
+func orientation(a, b, c geos.Coord) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// onSegment reports whether c, known to be collinear with ab, lies on it.
+func onSegment(a, b, c geos.Coord) bool {
+	return math.Min(a.X, b.X) <= c.X && c.X <= math.Max(a.X, b.X) &&
+		math.Min(a.Y, b.Y) <= c.Y && c.Y <= math.Max(a.Y, b.Y)
+}
+
+// pointInRing reports whether the point lies strictly inside the ring.
+func pointInRing(point geos.Coord, ring []geos.Coord) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Y > point.Y) != (b.Y > point.Y) &&
+			point.X < (b.X-a.X)*(point.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// interiorVertex finds a vertex of ring that is not on the boundary of other,
+// so that its position decides whether ring lies inside other.
+func interiorVertex(ring, other []geos.Coord) (geos.Coord, bool) {
+	for _, c := range ring {
+		onBoundary := false
+		for i := 0; i+1 < len(other); i++ {
+			if orientation(other[i], other[i+1], c) == 0 && onSegment(other[i], other[i+1], c) {
+				onBoundary = true
+				break
+			}
+		}
+		if !onBoundary {
+			return c, true
+		}
+	}
+	return geos.Coord{}, false
+}
+
+func absInt(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// MakeValid returns a copy of the feature with an invalid polygonal geometry
+// repaired, in the manner of a zero-width buffer. Because a zero buffer keeps
+// only the lobes of a bow-tie that wind one way, each ring is buffered in both
+// orientations and the results unioned before the holes are removed. Valid
+// features and non-polygonal ones are returned unchanged.
+func (feat *Feature) MakeValid() (*Feature, error) {
+
+	valid, invalid, err := feat.IsValid()
+	if err != nil {
+		return nil, err
+	}
+	if valid {
+		return feat, nil
+	}
+
+	if feat.Type != "Polygon" && feat.Type != "MultiPolygon" {
+		return nil, errors.Newf("Unable to repair a %s: %s.", feat.Type, invalid)
+	}
+
+	parts, err := geometryParts(feat.Geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	var repaired *geos.Geometry
+	for _, part := range parts {
+		rings, err := polygonRings(part)
+		if err != nil {
+			return nil, err
+		}
+		polygon, err := repairRing(rings[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, ring := range rings[1:] {
+			hole, err := repairRing(ring)
+			if err != nil {
+				return nil, err
+			}
+			if polygon, err = polygon.Difference(hole); err != nil {
+				return nil, errors.Wrap(err, "could not remove hole")
+			}
+		}
+		if repaired == nil {
+			repaired = polygon
+			continue
+		}
+		if repaired, err = repaired.Union(polygon); err != nil {
+			return nil, errors.Wrap(err, "could not union polygons")
+		}
+	}
+
+	typer, err := geometryTypeName(repaired)
+	if err != nil {
+		return nil, err
+	}
+	if typer != "Polygon" && typer != "MultiPolygon" {
+		return nil, errors.Newf("Repairing the feature collapsed it to a %s.", typer)
+	}
+
+	feature := &Feature{
+		ID:             feat.ID,
+		Properties:     feat.Properties,
+		ForeignMembers: feat.ForeignMembers,
+		CRS:            feat.CRS,
+		inheritedCRS:   feat.inheritedCRS,
+		sourceID:       feat.sourceID,
+		generatedID:    feat.generatedID,
+	}
+	if err := feature.SetGeometry(typer, repaired); err != nil {
+		return nil, err
+	}
+
+	return feature, nil
+}
+
+// repairRing returns the area enclosed by a possibly self-intersecting ring.
+func repairRing(ring []geos.Coord) (*geos.Geometry, error) {
+
+	var repaired *geos.Geometry
+	for _, coords := range [][]geos.Coord{ring, reverseCoords(ring)} {
+		polygon, err := geos.NewPolygon(coords)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create polygon from ring")
+		}
+		buffered, err := polygon.Buffer(0)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not buffer polygon")
+		}
+		if repaired == nil {
+			repaired = buffered
+			continue
+		}
+		if repaired, err = repaired.Union(buffered); err != nil {
+			return nil, errors.Wrap(err, "could not union buffered polygons")
+		}
+	}
+
+	return repaired, nil
+}
@@ -0,0 +1,126 @@
+package terra
+
+import (
+	"testing"
+
+	"github.com/paulsmith/gogeos/geos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func ring(coords ...float64) []geos.Coord {
+	var r []geos.Coord
+	for i := 0; i+1 < len(coords); i += 2 {
+		r = append(r, geos.NewCoord(coords[i], coords[i+1]))
+	}
+	return r
+}
+
+func TestCheckPolygons(t *testing.T) {
+
+	t.Parallel()
+
+	Convey("should accept a square with a hole", t, func() {
+		So(checkPolygons([][][]geos.Coord{{
+			ring(0, 0, 10, 0, 10, 10, 0, 10, 0, 0),
+			ring(2, 2, 2, 4, 4, 4, 4, 2, 2, 2),
+		}}), ShouldBeNil)
+	})
+
+	Convey("should locate the crossing of a bow-tie", t, func() {
+		invalid := checkPolygons([][][]geos.Coord{{
+			ring(0, 0, 10, 10, 10, 0, 0, 10, 0, 0),
+		}})
+		So(invalid, ShouldNotBeNil)
+		So(invalid.Reason, ShouldEqual, "Self-intersection")
+		So(invalid.Location, ShouldResemble, geos.NewCoord(5, 5))
+	})
+
+	Convey("should find a ring touching itself", t, func() {
+		invalid := checkPolygons([][][]geos.Coord{{
+			ring(0, 0, 10, 0, 5, 5, 10, 10, 0, 10, 5, 5, 0, 0),
+		}})
+		So(invalid, ShouldNotBeNil)
+		So(invalid.Reason, ShouldEqual, "Ring Self-intersection")
+	})
+
+	Convey("should find a hole outside its shell", t, func() {
+		invalid := checkPolygons([][][]geos.Coord{{
+			ring(0, 0, 10, 0, 10, 10, 0, 10, 0, 0),
+			ring(20, 20, 20, 24, 24, 24, 24, 20, 20, 20),
+		}})
+		So(invalid, ShouldNotBeNil)
+		So(invalid.Reason, ShouldEqual, "Hole lies outside shell")
+	})
+
+	Convey("should find nested shells", t, func() {
+		invalid := checkPolygons([][][]geos.Coord{
+			{ring(0, 0, 10, 0, 10, 10, 0, 10, 0, 0)},
+			{ring(2, 2, 4, 2, 4, 4, 2, 4, 2, 2)},
+		})
+		So(invalid, ShouldNotBeNil)
+		So(invalid.Reason, ShouldEqual, "Nested shells")
+	})
+
+	Convey("should read the reasons GEOS gives", t, func() {
+		invalid := parseInvalidity("Ring Self-intersection[2.5 -1]")
+		So(invalid.Reason, ShouldEqual, "Ring Self-intersection")
+		So(invalid.Location, ShouldResemble, geos.NewCoord(2.5, -1))
+
+		invalid = parseInvalidity("Valid Geometry")
+		So(invalid.Reason, ShouldEqual, "Valid Geometry")
+	})
+}
+
+func TestIngestPolicy(t *testing.T) {
+
+	Convey("given a bow-tie polygon", t, func() {
+
+		store, err := OpenGeostore("./geostore-ingest")
+		So(err, ShouldBeNil)
+
+		bowtie, err := NewPolygon([][][]float64{
+			[][]float64{{0, 0}, {10, 10}, {10, 0}, {0, 10}, {0, 0}},
+		})
+		So(err, ShouldBeNil)
+
+		valid, invalid, err := bowtie.IsValid()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeFalse)
+		So(invalid.Reason, ShouldEqual, "Self-intersection")
+
+		Convey("should reject it", func() {
+			store.SetIngestPolicy(RejectInvalid)
+			_, err := store.Add(bowtie)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("should repair both lobes", func() {
+			store.SetIngestPolicy(RepairInvalid)
+			_, err := store.Add(bowtie)
+			So(err, ShouldBeNil)
+
+			for _, coords := range [][2]float64{{5, 2}, {5, 8}} {
+				point, err := NewPoint(coords[1], coords[0])
+				So(err, ShouldBeNil)
+				contains, err := store.Contains(point)
+				So(err, ShouldBeNil)
+				So(len(contains), ShouldEqual, 1)
+			}
+		})
+
+		Convey("should accept and flag it", func() {
+			store.SetIngestPolicy(FlagInvalid)
+			_, err := store.Add(bowtie)
+			So(err, ShouldBeNil)
+
+			flagged := store.Flagged()
+			So(len(flagged), ShouldEqual, 1)
+			So(flagged[0].ID, ShouldEqual, bowtie.ID)
+		})
+
+		Reset(func() {
+			So(store.Clear(), ShouldBeNil)
+			So(store.Close(), ShouldBeNil)
+		})
+	})
+}
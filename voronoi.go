@@ -0,0 +1,535 @@
+package terra
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/paulsmith/gogeos/geos"
+	"github.com/saleswise/errors/errors"
+)
+
+// TrianglePointsProperty holds the IDs of the three points a Delaunay
+// triangle joins.
+const TrianglePointsProperty = "points"
+
+type diagramOptions struct {
+	bbox    []float64
+	polygon *Feature
+}
+
+// DiagramOption changes the extent of a Voronoi diagram or Delaunay
+// triangulation.
+type DiagramOption func(*diagramOptions)
+
+// ClipToBBox clips the diagram to the [west, south, east, north] box.
+func ClipToBBox(bbox []float64) DiagramOption {
+	return func(o *diagramOptions) {
+		o.bbox = bbox
+	}
+}
+
+// ClipToPolygon clips the diagram to the polygon, such as a service area's
+// administrative boundary.
+func ClipToPolygon(polygon *Feature) DiagramOption {
+	return func(o *diagramOptions) {
+		o.polygon = polygon
+	}
+}
+
+// triangle is a triangle of a triangulation, its vertices counterclockwise,
+// with its circumcircle.
+type triangle struct {
+	v      [3]int
+	cx, cy float64
+	r2     float64
+}
+
+func newTriangle(coords []geos.Coord, a, b, c int) triangle {
+
+	t := triangle{v: [3]int{a, b, c}}
+	pa, pb, pc := coords[a], coords[b], coords[c]
+
+	d := 2 * ((pb.X-pa.X)*(pc.Y-pa.Y) - (pb.Y-pa.Y)*(pc.X-pa.X))
+	if d == 0 {
+		// A flat triangle is removed by the next point inserted.
+		t.r2 = math.Inf(1)
+		return t
+	}
+
+	bx, by := pb.X-pa.X, pb.Y-pa.Y
+	cx, cy := pc.X-pa.X, pc.Y-pa.Y
+	b2, c2 := bx*bx+by*by, cx*cx+cy*cy
+	ux, uy := (cy*b2-by*c2)/d, (bx*c2-cx*b2)/d
+	t.cx, t.cy, t.r2 = pa.X+ux, pa.Y+uy, ux*ux+uy*uy
+
+	return t
+}
+
+// triangulate returns the Delaunay triangles of the distinct coordinates,
+// built with the Bowyer-Watson algorithm, along with those joining them to the
+// three vertices of an enclosing triangle, numbered after the coordinates.
+func triangulate(coords []geos.Coord) []triangle {
+
+	west, south, east, north := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range coords {
+		west, south = math.Min(west, c.X), math.Min(south, c.Y)
+		east, north = math.Max(east, c.X), math.Max(north, c.Y)
+	}
+	mx, my := (west+east)/2, (south+north)/2
+	size := math.Max(math.Max(east-west, north-south), 1e-9)
+
+	// Working around the middle of the points keeps coordinates small.
+	n := len(coords)
+	points := make([]geos.Coord, n, n+3)
+	for i, c := range coords {
+		points[i] = geos.NewCoord(c.X-mx, c.Y-my)
+	}
+	points = append(points,
+		geos.NewCoord(-100*size, -100*size),
+		geos.NewCoord(100*size, -100*size),
+		geos.NewCoord(0, 100*size),
+	)
+
+	triangles := []triangle{newTriangle(points, n, n+1, n+2)}
+	for i := 0; i < n; i++ {
+		p := points[i]
+
+		edges := make(map[[2]int]bool)
+		kept := triangles[:0]
+		for _, t := range triangles {
+			dx, dy := p.X-t.cx, p.Y-t.cy
+			if dx*dx+dy*dy < t.r2*(1-1e-12) || math.IsInf(t.r2, 1) {
+				edges[[2]int{t.v[0], t.v[1]}] = true
+				edges[[2]int{t.v[1], t.v[2]}] = true
+				edges[[2]int{t.v[2], t.v[0]}] = true
+				continue
+			}
+			kept = append(kept, t)
+		}
+
+		// The edges of the cavity are those of one removed triangle only,
+		// sorted so that the triangulation does not vary between runs.
+		cavity := [][2]int{}
+		for edge := range edges {
+			if !edges[[2]int{edge[1], edge[0]}] {
+				cavity = append(cavity, edge)
+			}
+		}
+		sort.Slice(cavity, func(a, b int) bool {
+			return cavity[a][0] < cavity[b][0] || cavity[a][0] == cavity[b][0] && cavity[a][1] < cavity[b][1]
+		})
+		triangles = kept
+		for _, edge := range cavity {
+			triangles = append(triangles, newTriangle(points, edge[0], edge[1], i))
+		}
+	}
+
+	return triangles
+}
+
+// diagramPoints reads the points of the source, leaving out any at the
+// position of an earlier one.
+func diagramPoints(source FeatureSource) ([]*Feature, []geos.Coord, error) {
+
+	points, coords, err := sourcePoints(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[geos.Coord]bool, len(coords))
+	distinct, positions := []*Feature{}, []geos.Coord{}
+	for i, c := range coords {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		distinct = append(distinct, points[i])
+		positions = append(positions, c)
+	}
+
+	return distinct, positions, nil
+}
+
+// clip returns the area the diagram is clipped to: the box, the polygon, or
+// where they overlap when both are given. It returns nil for neither.
+func (o *diagramOptions) clip() (*Feature, error) {
+
+	if o.bbox == nil {
+		return o.polygon, nil
+	}
+	box, err := bboxFeature(o.bbox)
+	if err != nil || o.polygon == nil {
+		return box, err
+	}
+
+	return o.polygon.Intersection(box)
+}
+
+// clipFeature intersects the feature with the clipping area, if any, keeping
+// its ID and properties. It returns nil if no area is left.
+func clipFeature(feat, clip *Feature) (*Feature, error) {
+
+	if clip == nil {
+		return feat, nil
+	}
+
+	clipped, err := feat.Intersection(clip)
+	if err != nil {
+		return nil, err
+	}
+	empty, err := clipped.IsEmpty()
+	if err != nil || empty {
+		return nil, err
+	}
+	// A cell only touching the clip leaves a point or line.
+	if !isPolygonal(clipped) {
+		return nil, nil
+	}
+	clipped.ID = feat.ID
+
+	return clipped, nil
+}
+
+// bboxFeature returns the [west, south, east, north] box as a polygon.
+func bboxFeature(bbox []float64) (*Feature, error) {
+
+	if len(bbox) != 4 || bbox[0] >= bbox[2] || bbox[1] >= bbox[3] {
+		return nil, errors.New("A clipping box must be a [west, south, east, north] bounding box not crossing the antimeridian.")
+	}
+
+	return NewPolygon([][][]float64{{
+		{bbox[0], bbox[1]}, {bbox[2], bbox[1]}, {bbox[2], bbox[3]}, {bbox[0], bbox[3]}, {bbox[0], bbox[1]},
+	}})
+}
+
+// ringFeature returns a polygon of the open ring of coordinates.
+func ringFeature(ring []geos.Coord) (*Feature, error) {
+
+	coordinates := make([][]float64, 0, len(ring)+1)
+	for _, c := range ring {
+		coordinates = append(coordinates, []float64{c.X, c.Y})
+	}
+	coordinates = append(coordinates, coordinates[0])
+
+	return NewPolygon([][][]float64{coordinates})
+}
+
+// Delaunay returns the Delaunay triangulation of the points, treating
+// longitude and latitude as plane coordinates. Each triangle is a polygon
+// numbered from "0", with the IDs of its points in TrianglePointsProperty.
+// Given a box or polygon, or both, the triangulation conforms to the area they
+// clip to: its boundary is added, split by further vertices until each piece
+// is an edge of the triangulation, and the triangles inside it are kept. A
+// vertex that is not one of the points has an empty ID. A point at the
+// position of an earlier one is left out.
+func Delaunay(source FeatureSource, opts ...DiagramOption) (FeatureCollection, error) {
+
+	options := &diagramOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	points, coords, err := diagramPoints(source)
+	if err != nil {
+		return nil, err
+	}
+
+	clip, err := options.clip()
+	if err != nil {
+		return nil, err
+	}
+
+	coll := FeatureCollection{}
+	if len(points) < 3 {
+		return coll, nil
+	}
+
+	var rings [][]geos.Coord
+	if clip != nil {
+		if rings, err = clipRings(clip); err != nil {
+			return nil, err
+		}
+		if coords, err = conform(coords, rings); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range triangulate(coords) {
+		if t.v[0] >= len(coords) || t.v[1] >= len(coords) || t.v[2] >= len(coords) || math.IsInf(t.r2, 1) {
+			continue
+		}
+		a, b, c := coords[t.v[0]], coords[t.v[1]], coords[t.v[2]]
+		// The clip's edges are among the triangles', so a triangle lies
+		// wholly on one side of them, as its centroid does.
+		if rings != nil && !insideRings(geos.NewCoord((a.X+b.X+c.X)/3, (a.Y+b.Y+c.Y)/3), rings) {
+			continue
+		}
+		feat, err := ringFeature([]geos.Coord{a, b, c})
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 3)
+		for k, v := range t.v {
+			if v < len(points) {
+				ids[k] = points[v].ID
+			}
+		}
+		feat.ID = strconv.Itoa(len(coll))
+		feat.Properties = map[string]interface{}{TrianglePointsProperty: ids}
+		coll = append(coll, feat)
+	}
+
+	return coll, nil
+}
+
+// clipRings returns the closed rings of every polygon of the clip.
+func clipRings(clip *Feature) ([][]geos.Coord, error) {
+
+	parts, err := geometryParts(clip.Geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	var rings [][]geos.Coord
+	for _, part := range parts {
+		typer, err := part.Type()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get geometry type")
+		}
+		if typer != geos.POLYGON {
+			continue
+		}
+		polygon, err := polygonRings(part)
+		if err != nil {
+			return nil, err
+		}
+		rings = append(rings, polygon...)
+	}
+	if len(rings) == 0 {
+		return nil, errors.New("Unable to triangulate within a clip that has no area.")
+	}
+
+	return rings, nil
+}
+
+// insideRings reports whether the position lies inside an odd number of the
+// rings, and so inside the polygons they bound.
+func insideRings(c geos.Coord, rings [][]geos.Coord) bool {
+	inside := false
+	for _, ring := range rings {
+		if pointInRing(c, ring) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// conform returns the coordinates followed by the vertices of the rings and
+// those added along their edges so that each piece of an edge is an edge of
+// the Delaunay triangulation. A piece missing from it is split at its
+// midpoint, whose circle through the piece's ends is half as wide, until none
+// is missing.
+func conform(coords []geos.Coord, rings [][]geos.Coord) ([]geos.Coord, error) {
+
+	vertices := append([]geos.Coord{}, coords...)
+	index := make(map[geos.Coord]int, len(vertices))
+	for i, c := range vertices {
+		index[c] = i
+	}
+	vertex := func(c geos.Coord) int {
+		i, ok := index[c]
+		if !ok {
+			i = len(vertices)
+			index[c] = i
+			vertices = append(vertices, c)
+		}
+		return i
+	}
+
+	pieces := [][2]int{}
+	for _, ring := range rings {
+		for i := 1; i < len(ring); i++ {
+			if a, b := vertex(ring[i-1]), vertex(ring[i]); a != b {
+				pieces = append(pieces, [2]int{a, b})
+			}
+		}
+	}
+
+	// A point lying on an edge splits it, as the piece across it could never
+	// be an edge of the triangulation.
+	split := make([][2]int, 0, len(pieces))
+	for _, piece := range pieces {
+		a, b := vertices[piece[0]], vertices[piece[1]]
+		on := []int{}
+		for i, c := range vertices {
+			if i != piece[0] && i != piece[1] && orientation(a, b, c) == 0 && onSegment(a, b, c) {
+				on = append(on, i)
+			}
+		}
+		sort.Slice(on, func(i, j int) bool {
+			ci, cj := vertices[on[i]], vertices[on[j]]
+			return math.Abs(ci.X-a.X)+math.Abs(ci.Y-a.Y) < math.Abs(cj.X-a.X)+math.Abs(cj.Y-a.Y)
+		})
+		previous := piece[0]
+		for _, i := range append(on, piece[1]) {
+			split = append(split, [2]int{previous, i})
+			previous = i
+		}
+	}
+	pieces = split
+
+	for {
+		edges := make(map[[2]int]bool)
+		for _, t := range triangulate(vertices) {
+			for k := 0; k < 3; k++ {
+				a, b := t.v[k], t.v[(k+1)%3]
+				edges[[2]int{a, b}], edges[[2]int{b, a}] = true, true
+			}
+		}
+
+		missing := false
+		next := make([][2]int, 0, len(pieces))
+		for _, piece := range pieces {
+			if edges[piece] {
+				next = append(next, piece)
+				continue
+			}
+			missing = true
+			a, b := vertices[piece[0]], vertices[piece[1]]
+			mid := geos.NewCoord((a.X+b.X)/2, (a.Y+b.Y)/2)
+			if mid == a || mid == b {
+				return nil, errors.New("Unable to conform the triangulation to the clip's edges.")
+			}
+			m := vertex(mid)
+			next = append(next, [2]int{piece[0], m}, [2]int{m, piece[1]})
+		}
+		pieces = next
+
+		if !missing {
+			return vertices, nil
+		}
+	}
+}
+
+// clipHalfPlane returns the part of the convex open ring nearer a than b.
+func clipHalfPlane(ring []geos.Coord, a, b geos.Coord) []geos.Coord {
+
+	nx, ny := b.X-a.X, b.Y-a.Y
+	mx, my := (a.X+b.X)/2, (a.Y+b.Y)/2
+	side := func(p geos.Coord) float64 {
+		return (p.X-mx)*nx + (p.Y-my)*ny
+	}
+
+	clipped := make([]geos.Coord, 0, len(ring)+1)
+	for i, current := range ring {
+		previous := ring[(i+len(ring)-1)%len(ring)]
+		sc, sp := side(current), side(previous)
+		if (sc <= 0) != (sp <= 0) {
+			t := sp / (sp - sc)
+			clipped = append(clipped, geos.NewCoord(previous.X+t*(current.X-previous.X), previous.Y+t*(current.Y-previous.Y)))
+		}
+		if sc <= 0 {
+			clipped = append(clipped, current)
+		}
+	}
+
+	return clipped
+}
+
+// Voronoi returns the Voronoi diagram of the points, treating longitude and
+// latitude as plane coordinates: for each point, the polygon of positions
+// nearer it than any other, with the point's ID and a copy of its properties.
+// Cells are clipped to the box or polygon given, or to where they overlap
+// when both are, and otherwise to the extent of the points widened by half on
+// each side. A point at the position of an earlier one, or whose cell is
+// clipped away, has no cell.
+func Voronoi(source FeatureSource, opts ...DiagramOption) (FeatureCollection, error) {
+
+	options := &diagramOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	points, coords, err := diagramPoints(source)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := FeatureCollection{}
+	if len(points) == 0 {
+		return coll, nil
+	}
+
+	// Cells start as the box, which the bisectors with each neighbor cut
+	// down. Clipping to a box then needs no more work, and a polygon is
+	// clipped to after.
+	bbox := options.bbox
+	if bbox != nil {
+		if _, err := bboxFeature(bbox); err != nil {
+			return nil, err
+		}
+	} else if options.polygon != nil {
+		if bbox, err = options.polygon.BoundingBox(); err != nil {
+			return nil, err
+		}
+	} else {
+		bbox = []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+		for _, c := range coords {
+			bbox[0], bbox[1] = math.Min(bbox[0], c.X), math.Min(bbox[1], c.Y)
+			bbox[2], bbox[3] = math.Max(bbox[2], c.X), math.Max(bbox[3], c.Y)
+		}
+		pad := math.Max(math.Max(bbox[2]-bbox[0], bbox[3]-bbox[1])/2, 0.001)
+		bbox = []float64{bbox[0] - pad, bbox[1] - pad, bbox[2] + pad, bbox[3] + pad}
+	}
+
+	neighbors := make([]map[int]bool, len(points))
+	for i := range neighbors {
+		neighbors[i] = make(map[int]bool)
+	}
+	if len(points) > 1 {
+		for _, t := range triangulate(coords) {
+			for k := 0; k < 3; k++ {
+				a, b := t.v[k], t.v[(k+1)%3]
+				if a < len(points) && b < len(points) {
+					neighbors[a][b], neighbors[b][a] = true, true
+				}
+			}
+		}
+	}
+
+	for i, point := range points {
+		ring := []geos.Coord{
+			geos.NewCoord(bbox[0], bbox[1]), geos.NewCoord(bbox[2], bbox[1]),
+			geos.NewCoord(bbox[2], bbox[3]), geos.NewCoord(bbox[0], bbox[3]),
+		}
+		nearby := make([]int, 0, len(neighbors[i]))
+		for j := range neighbors[i] {
+			nearby = append(nearby, j)
+		}
+		sort.Ints(nearby)
+		for _, j := range nearby {
+			if ring = clipHalfPlane(ring, coords[i], coords[j]); len(ring) < 3 {
+				break
+			}
+		}
+		if len(ring) < 3 {
+			continue
+		}
+
+		cell, err := ringFeature(ring)
+		if err != nil {
+			return nil, err
+		}
+		cell.ID = point.ID
+		cell.Properties = copyProperties(point.Properties)
+		if cell, err = clipFeature(cell, options.polygon); err != nil {
+			return nil, err
+		}
+		if cell != nil {
+			coll = append(coll, cell)
+		}
+	}
+
+	return coll, nil
+}
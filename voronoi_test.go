@@ -0,0 +1,179 @@
+package terra
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/paulsmith/gogeos/geos"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVoronoi(t *testing.T) {
+
+	t.Parallel()
+
+	signedArea := func(ring []geos.Coord) float64 {
+		area := 0.0
+		for i, c := range ring {
+			next := ring[(i+1)%len(ring)]
+			area += c.X*next.Y - next.X*c.Y
+		}
+		return area / 2
+	}
+
+	Convey("should triangulate with empty circumcircles", t, func() {
+		coords := []geos.Coord{
+			geos.NewCoord(0, 0), geos.NewCoord(2, 0), geos.NewCoord(2, 2), geos.NewCoord(0, 2), geos.NewCoord(1, 1),
+		}
+		joined := 0
+		for _, tri := range triangulate(coords) {
+			if tri.v[0] < 5 && tri.v[1] < 5 && tri.v[2] < 5 {
+				joined++
+			}
+		}
+		So(joined, ShouldEqual, 4)
+
+		random := rand.New(rand.NewSource(7))
+		coords = nil
+		for i := 0; i < 60; i++ {
+			coords = append(coords, geos.NewCoord(-120+random.Float64(), 37+random.Float64()))
+		}
+		for _, tri := range triangulate(coords) {
+			if tri.v[0] >= len(coords) || tri.v[1] >= len(coords) || tri.v[2] >= len(coords) {
+				continue
+			}
+			So(signedArea([]geos.Coord{coords[tri.v[0]], coords[tri.v[1]], coords[tri.v[2]]}), ShouldBeGreaterThan, 0)
+			circle := newTriangle(coords, tri.v[0], tri.v[1], tri.v[2])
+			for _, c := range coords {
+				dx, dy := c.X-circle.cx, c.Y-circle.cy
+				So(dx*dx+dy*dy, ShouldBeGreaterThanOrEqualTo, circle.r2*(1-1e-9))
+			}
+		}
+	})
+
+	Convey("should clip a ring to the side nearer a point", t, func() {
+		square := []geos.Coord{geos.NewCoord(0, 0), geos.NewCoord(1, 0), geos.NewCoord(1, 1), geos.NewCoord(0, 1)}
+		clipped := clipHalfPlane(square, geos.NewCoord(0, 0.5), geos.NewCoord(1, 0.5))
+		So(len(clipped), ShouldEqual, 4)
+		So(signedArea(clipped), ShouldAlmostEqual, 0.5, 0.0000001)
+
+		So(clipHalfPlane(square, geos.NewCoord(5, 0), geos.NewCoord(3, 0)), ShouldBeEmpty)
+	})
+
+	Convey("given facilities", t, func() {
+
+		facilities := FeatureCollection{}
+		for _, facility := range []struct {
+			id       string
+			lat, lng float64
+		}{
+			{"west", 0, 0},
+			{"east", 0, 2},
+			{"north", 2, 1},
+			{"again", 0, 0},
+		} {
+			point, err := NewPoint(facility.lat, facility.lng)
+			So(err, ShouldBeNil)
+			point.ID = facility.id
+			point.Properties = map[string]interface{}{"name": facility.id}
+			facilities = append(facilities, point)
+		}
+
+		Convey("should build cells carrying their point", func() {
+			cells, err := Voronoi(facilities, ClipToBBox([]float64{-1, -1, 3, 3}))
+			So(err, ShouldBeNil)
+			So(len(cells), ShouldEqual, 3)
+			So(cells[0].ID, ShouldEqual, "west")
+			So(cells[0].Properties["name"], ShouldEqual, "west")
+
+			bbox, err := cells[0].BoundingBox()
+			So(err, ShouldBeNil)
+			So(bbox[0], ShouldAlmostEqual, -1, 0.0000001)
+			So(bbox[1], ShouldAlmostEqual, -1, 0.0000001)
+			So(bbox[2], ShouldAlmostEqual, 1, 0.0000001)
+
+			// The cells tile the box.
+			total := 0.0
+			for _, cell := range cells {
+				area, err := cell.Geometry.Area()
+				So(err, ShouldBeNil)
+				total += area
+			}
+			So(total, ShouldAlmostEqual, 16, 0.0000001)
+		})
+
+		Convey("should clip cells to a polygon", func() {
+			area, err := NewPolygon([][][]float64{{{-1, -1}, {0.4, -1}, {0.4, 1}, {-1, 1}, {-1, -1}}})
+			So(err, ShouldBeNil)
+			cells, err := Voronoi(facilities, ClipToPolygon(area))
+			So(err, ShouldBeNil)
+			So(len(cells), ShouldEqual, 1)
+			So(cells[0].ID, ShouldEqual, "west")
+		})
+
+		Convey("should triangulate the points", func() {
+			triangles, err := Delaunay(facilities)
+			So(err, ShouldBeNil)
+			So(len(triangles), ShouldEqual, 1)
+			So(triangles[0].Properties[TrianglePointsProperty], ShouldResemble, []string{"west", "east", "north"})
+		})
+
+		Convey("should conform the triangulation to the clip", func() {
+			tiles := func(triangles FeatureCollection, east float64) (float64, int) {
+				total, joined := 0.0, 0
+				for _, triangle := range triangles {
+					bbox, err := triangle.BoundingBox()
+					So(err, ShouldBeNil)
+					So(bbox[0], ShouldBeGreaterThanOrEqualTo, -1)
+					So(bbox[1], ShouldBeGreaterThanOrEqualTo, -1)
+					So(bbox[2], ShouldBeLessThanOrEqualTo, east)
+					So(bbox[3], ShouldBeLessThanOrEqualTo, 3)
+					area, err := triangle.Geometry.Area()
+					So(err, ShouldBeNil)
+					total += area
+					ids := triangle.Properties[TrianglePointsProperty].([]string)
+					if ids[0] != "" && ids[1] != "" && ids[2] != "" {
+						joined++
+					}
+				}
+				return total, joined
+			}
+
+			// The triangles tile the box, keeping the one joining the points.
+			triangles, err := Delaunay(facilities, ClipToBBox([]float64{-1, -1, 3, 3}))
+			So(err, ShouldBeNil)
+			total, joined := tiles(triangles, 3)
+			So(total, ShouldAlmostEqual, 16, 0.0000001)
+			So(joined, ShouldEqual, 1)
+
+			// The polygon cuts across that triangle, which gives way to
+			// smaller ones meeting its edge.
+			area, err := NewPolygon([][][]float64{{{-1, -1}, {1.5, -1}, {1.5, 3}, {-1, 3}, {-1, -1}}})
+			So(err, ShouldBeNil)
+			triangles, err = Delaunay(facilities, ClipToBBox([]float64{-1, -1, 3, 3}), ClipToPolygon(area))
+			So(err, ShouldBeNil)
+			total, joined = tiles(triangles, 1.5)
+			So(total, ShouldAlmostEqual, 10, 0.0000001)
+			So(joined, ShouldEqual, 0)
+		})
+
+		Convey("should clip cells to both a box and a polygon", func() {
+			area, err := NewPolygon([][][]float64{{{-1, -1}, {0.4, -1}, {0.4, 1}, {-1, 1}, {-1, -1}}})
+			So(err, ShouldBeNil)
+			cells, err := Voronoi(facilities, ClipToBBox([]float64{-1, -1, 3, 0.5}), ClipToPolygon(area))
+			So(err, ShouldBeNil)
+			So(len(cells), ShouldEqual, 1)
+			So(cells[0].ID, ShouldEqual, "west")
+			size, err := cells[0].Geometry.Area()
+			So(err, ShouldBeNil)
+			So(size, ShouldAlmostEqual, 2.1, 0.0000001)
+		})
+
+		Convey("should reject other geometries", func() {
+			line, err := NewPolygon([][][]float64{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}})
+			So(err, ShouldBeNil)
+			_, err = Voronoi(FeatureCollection{line})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}